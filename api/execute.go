@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/serverless"
+)
+
+// Execute 对应 /api/execute 端点。
+func Execute(w http.ResponseWriter, r *http.Request) {
+	serverless.HandleExecute(w, r)
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/serverless"
+)
+
+// Plan 对应 /api/plan 端点。
+func Plan(w http.ResponseWriter, r *http.Request) {
+	serverless.HandlePlan(w, r)
+}
@@ -0,0 +1,14 @@
+// package handler 是 Vercel Serverless Functions 的入口。
+// Vercel 会将本目录下每个文件导出的处理函数部署为一个独立的 HTTP 端点。
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/serverless"
+)
+
+// Handler 对应 /api/sync 端点。
+func Handler(w http.ResponseWriter, r *http.Request) {
+	serverless.HandleSync(w, r)
+}
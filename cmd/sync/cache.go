@@ -0,0 +1,33 @@
+package main
+
+import (
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+)
+
+// loadWebdavCache 从 WEBDAV_CACHE_FILE 恢复上次运行保存的 WebDAV 文件列表缓存，
+// 使由 cron 触发的每次增量同步都无需重新执行耗时的 PROPFIND。
+func loadWebdavCache() {
+	if appConfig.WebdavCacheFile == "" {
+		return
+	}
+	count, err := sync_lib.LoadWebdavCache(appConfig.WebdavCacheFile, time.Duration(appConfig.WebdavCacheTTL)*time.Minute)
+	if err != nil {
+		log.Warn("恢复 WebDAV 缓存失败，将重新获取文件列表: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Debug("已从 %s 恢复 %d 个同步目标的 WebDAV 文件列表缓存", appConfig.WebdavCacheFile, count)
+	}
+}
+
+// saveWebdavCache 将 WebDAV 文件列表缓存保存到 WEBDAV_CACHE_FILE。
+func saveWebdavCache() {
+	if appConfig.WebdavCacheFile == "" {
+		return
+	}
+	if _, err := sync_lib.SaveWebdavCache(appConfig.WebdavCacheFile); err != nil {
+		log.Warn("保存 WebDAV 缓存失败: %v", err)
+	}
+}
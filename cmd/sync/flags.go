@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/lockfile"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// commonFlags 是 run 和 watch 子命令共享的命令行参数。
+type commonFlags struct {
+	full        *bool
+	concurrency *int
+	deleteConc  *int
+	autoConc    *bool
+	timeout     *time.Duration
+	opTimeout   *time.Duration
+	quiet       *bool
+	verbose     *bool
+	veryVerbose *bool
+	lockFile    *string
+	pushgateway *string
+	pushJob     *string
+	hcURL       *string
+	kumaURL     *string
+	scope       *string
+}
+
+// registerCommonFlags 在给定的 FlagSet 上注册共享参数。
+func registerCommonFlags(fs *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		full:        fs.Bool("full", false, "执行全量同步 (Cookie)，默认为增量同步 (API Key)"),
+		concurrency: fs.Int("concurrency", 0, "上传的并发数，0 表示使用 SYNC_CONCURRENCY 的配置"),
+		deleteConc:  fs.Int("delete-concurrency", 0, "删除、清理旧版本和重命名的并发数，0 表示使用 SYNC_DELETE_CONCURRENCY 的配置"),
+		autoConc:    fs.Bool("auto-concurrency", appConfig.AutoConcurrency, "遇到限流或超时时自动降低并发，恢复后再逐步提高到 --concurrency"),
+		timeout:     fs.Duration("timeout", 30*time.Second, "单个 HTTP 操作（列表、下载、上传、删除）的超时时间，0 表示不限制"),
+		opTimeout:   fs.Duration("op-timeout", time.Duration(appConfig.OpTimeout)*time.Second, "单个文件的下载+上传或删除（含所有分页/重定向请求）的总超时时间，0 表示不限制"),
+		quiet:       fs.Bool("q", false, "只输出错误日志，适合 cron 邮件"),
+		verbose:     fs.Bool("v", false, "输出调试日志"),
+		veryVerbose: fs.Bool("vv", false, "输出调试日志，并记录每一个 HTTP 请求"),
+		lockFile:    fs.String("lock-file", appConfig.LockFile, "锁文件路径，防止多个进程同时同步；设为空字符串则禁用"),
+		pushgateway: fs.String("pushgateway", appConfig.PushgatewayURL, "每次同步结束后将指标推送到该 Prometheus Pushgateway 地址"),
+		pushJob:     fs.String("pushgateway-job", appConfig.PushgatewayJob, "推送指标时使用的 job 名称"),
+		hcURL:       fs.String("healthchecks", appConfig.HealthchecksURL, "同步开始和结束时向该 Healthchecks.io 地址发送 ping"),
+		kumaURL:     fs.String("uptime-kuma", appConfig.UptimeKumaURL, "每次同步结束后向该 Uptime Kuma Push 地址推送状态和耗时"),
+		scope:       fs.String("scope", appConfig.Scope, "只同步该文件或 URL（链接列表、文章导出或站点地图）中引用的图片"),
+	}
+}
+
+// apply 将命令行参数覆盖到全局配置、logger 和 HTTP 客户端上。
+// 日志级别参数优先于 LOG_LEVEL 环境变量。
+func (f *commonFlags) apply() {
+	// 同步日志由任务管理器按 appConfig.LogLevel 输出，因此参数需要写回配置，而不只是替换本地的 logger
+	switch {
+	case *f.veryVerbose, *f.verbose:
+		appConfig.LogLevel = "debug"
+	case *f.quiet:
+		appConfig.LogLevel = "error"
+	}
+	log = logger.New(logger.StringToLogLevel(appConfig.LogLevel), logOutput)
+
+	if dotenvErr != nil {
+		log.Warn("未找到 .env 文件，将依赖系统环境变量")
+	}
+
+	appConfig.PushgatewayURL = *f.pushgateway
+	appConfig.PushgatewayJob = *f.pushJob
+	appConfig.HealthchecksURL = *f.hcURL
+	appConfig.UptimeKumaURL = *f.kumaURL
+	appConfig.Scope = *f.scope
+	appConfig.AutoConcurrency = *f.autoConc
+	if *f.concurrency > 0 {
+		appConfig.SyncConcurrency = *f.concurrency
+	}
+	if *f.deleteConc > 0 {
+		appConfig.DeleteWorkers = *f.deleteConc
+	}
+	httpClient.Timeout = *f.timeout
+	appConfig.OpTimeout = int(f.opTimeout.Seconds())
+	if *f.veryVerbose {
+		httpClient.Transport = &tracingTransport{next: httpClient.Transport, log: log}
+	}
+}
+
+// acquireLock 获取 --lock-file 指定的锁文件。未配置锁文件时返回 nil 锁。
+func (f *commonFlags) acquireLock() (*lockfile.Lock, error) {
+	if *f.lockFile == "" {
+		return nil, nil
+	}
+	return lockfile.Acquire(*f.lockFile)
+}
+
+// tracingTransport 在 -vv 模式下记录每一个 HTTP 请求的方法、地址、状态码和耗时。
+type tracingTransport struct {
+	next http.RoundTripper
+	log  logger.Logger
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.log.Debug("HTTP %s %s 失败 (%s): %v", req.Method, req.URL.Redacted(), time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	t.log.Debug("HTTP %s %s -> %d (%s)", req.Method, req.URL.Redacted(), resp.StatusCode, time.Since(start).Round(time.Millisecond))
+	return resp, nil
+}
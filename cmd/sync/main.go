@@ -0,0 +1,218 @@
+// cmd/sync 是一个不依赖 Web UI 的命令行同步工具，适用于无需管理面板的无头服务器。
+//
+// 用法:
+//
+//	sync [run] [flags]                     已弃用，等同于 nodeimage-sync --once：执行一次同步后退出
+//	sync watch [--interval 30m] [flags]    常驻运行，按固定间隔执行同步
+//	sync duplicates [--json] [flags]       输出 WebDAV 同步目录中的重复文件报告
+//	sync verify [--json] [flags]           校验 WebDAV 上的备份图片是否损坏，发现损坏时退出码为 1
+//	sync mapping [--format csv] [-o file]  导出 NodeImage 直链到 WebDAV 路径的映射
+//	sync state export [-o file] [flags]    将同步状态导出为 tar.gz 归档，用于迁移到另一台主机
+//	sync state import <file> [flags]       从归档导入同步状态
+//
+// run 和 watch 子命令都支持 --full、--concurrency、--timeout、--lock-file、--pushgateway 以及 -q/-v/-vv 参数。
+// 进程运行期间会持有锁文件，避免多个由 cron 触发的进程同时执行同一份差异。
+//
+// run 子命令只为兼容已有的 cron 配置而保留，它与 Web UI 程序的 --once 共用 jobs.RunOnce，
+// 新的部署应直接使用 nodeimage-sync --once。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/jobs"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/schedule"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/notify"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/sdnotify"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	appConfig  *config.Config
+	log        logger.Logger
+	runner     sync_lib.Runner
+	httpClient *http.Client
+	dotenvErr  error // 加载 .env 的结果，待日志级别确定后再输出警告
+
+	// logOutput 是日志的输出目标。将报告或归档写到标准输出的子命令会把它改为标准错误，避免日志混入数据。
+	logOutput io.Writer = os.Stdout
+)
+
+func main() {
+	dotenvErr = godotenv.Load()
+
+	appConfig = config.LoadConfig()
+	log = logger.New(logger.StringToLogLevel(appConfig.LogLevel), os.Stdout)
+
+	httpClient = sync_lib.NewHTTPClient(30 * time.Second)
+
+	// 收到 SIGINT/SIGTERM 时取消 context，让正在进行的同步尽快结束
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := os.Args[1:]
+	command := "run"
+	if len(args) > 0 {
+		switch args[0] {
+		case "run", "watch", "duplicates", "verify", "mapping", "state":
+			command = args[0]
+			args = args[1:]
+		}
+	}
+
+	switch command {
+	case "watch":
+		os.Exit(watchCommand(ctx, args))
+	case "duplicates":
+		os.Exit(duplicatesCommand(ctx, args))
+	case "verify":
+		os.Exit(verifyCommand(ctx, args))
+	case "mapping":
+		os.Exit(mappingCommand(ctx, args))
+	case "state":
+		os.Exit(stateCommand(ctx, args))
+	default:
+		os.Exit(runCommand(ctx, args))
+	}
+}
+
+// runCommand 是已弃用的 run 子命令：将命令行参数覆盖到配置上，然后与 nodeimage-sync --once 一样通过 jobs.RunOnce 执行一次同步。
+func runCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	common.apply()
+	log.Warn("sync run 已弃用，请改用 nodeimage-sync --once（全量同步为 --once full）")
+
+	appConfig.LockFile = *common.lockFile
+	hub := websocket.NewHub()
+	go hub.Run()
+	return jobs.RunOnce(ctx, appConfig, hub, log, httpClient, "", *common.full)
+}
+
+// watchCommand 常驻运行，启动时立即同步一次，之后每隔 interval 执行一次（均会加上 --jitter 的随机推迟）。
+// 设置了 --window 时，落在时间段之外的同步会推迟到下一个时间段开始。
+// 下一次同步在上一次结束后才开始计时；连续失败达到 --failure-threshold 次后，间隔按指数延长并发送一次降级告警。
+func watchCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 30*time.Minute, "两次同步之间的间隔，例如 30m、1h")
+	jitter := fs.Duration("jitter", time.Duration(appConfig.SyncJitter)*time.Second, "每次同步额外推迟的最大随机时间，避免多个实例同时请求 NodeImage")
+	window := fs.String("window", appConfig.SyncWindow, "只在这些时间段内同步，例如 01:00-06:00，多个时间段以逗号分隔")
+	timezone := fs.String("timezone", appConfig.SyncTimezone, "解释 --window 所用的时区，例如 Asia/Shanghai，默认为系统本地时区")
+	threshold := fs.Int("failure-threshold", appConfig.FailThreshold, "连续失败多少次后延长同步间隔并发送降级告警，0 表示不退避")
+	fullEvery := fs.Int("full-every", appConfig.FullSyncEvery, "每进行多少次增量同步后自动执行一次全量同步，0 表示不自动全量同步")
+	maxBackoff := fs.Duration("max-backoff", time.Duration(appConfig.MaxBackoff)*time.Minute, "连续失败后同步间隔的上限")
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	common.apply()
+
+	lock, err := common.acquireLock()
+	if err != nil {
+		log.Error("无法获取锁文件，可能已有另一个同步进程在运行: %v", err)
+		return 1
+	}
+	defer lock.Release()
+
+	if *interval <= 0 {
+		log.Error("无效的同步间隔: %s", *interval)
+		return 2
+	}
+	sched, err := schedule.New(*interval, *jitter, *window, *timezone)
+	if err != nil {
+		log.Error("同步时间窗口配置无效: %v", err)
+		return 2
+	}
+
+	loadWebdavCache()
+	defer saveWebdavCache()
+
+	log.Info("进入常驻模式，每 %s 执行一次同步", *interval)
+
+	backoff := &schedule.Backoff{Threshold: *threshold, Max: *maxBackoff}
+	sched.Backoff = backoff
+	notifier := notify.New(appConfig.NotifyWebhook, appConfig.TelegramToken, appConfig.TelegramChatID, httpClient)
+
+	// 在 systemd 下以 Type=notify 运行时报告就绪，并在每次同步前后更新 systemctl status 中的状态文本
+	if ok, err := sdnotify.Ready(); err != nil {
+		log.Warn("通知 systemd 失败: %v", err)
+	} else if ok {
+		log.Debug("已通知 systemd 服务就绪")
+	}
+	defer sdnotify.Stopping()
+	go sdnotify.Watchdog(ctx.Done(), nil)
+
+	sinceFull := 0 // 上次成功的全量同步之后进行的增量同步次数
+	schedule.Run(ctx.Done(), sched, func() {
+		isFullSync := *common.full
+		if !isFullSync && *fullEvery > 0 && sinceFull >= *fullEvery {
+			if appConfig.NodeImageCookie == "" {
+				log.Warn("未设置 NodeImage Cookie，无法执行自动全量同步，本次仍为增量同步")
+			} else {
+				log.Info("已进行 %d 次增量同步，本次改为全量同步", sinceFull)
+				isFullSync = true
+			}
+		}
+		mode := "增量同步"
+		if isFullSync {
+			mode = "全量同步"
+		}
+		sdnotify.Status("正在" + mode)
+		result, ok := runSync(ctx, isFullSync)
+		if !ok || ctx.Err() != nil {
+			return
+		}
+		outcome := "成功"
+		if !result.Success {
+			outcome = "失败"
+		}
+		sdnotify.Status(fmt.Sprintf("空闲，上次%s于 %s %s: %s", mode, time.Now().Format("01-02 15:04"), outcome, result.Message))
+		switch {
+		case !isFullSync:
+			sinceFull++
+		case result.Success:
+			sinceFull = 0
+		}
+		degraded, recovered := backoff.Record(result.Success)
+		var event notify.Event
+		switch {
+		case degraded:
+			event = notify.Degraded(appConfig.PushgatewayJob, backoff.Failures(), backoff.Interval(*interval), result.Message)
+			log.Error("%s", event.Message)
+		case recovered:
+			event = notify.Recovered(appConfig.PushgatewayJob)
+			log.Info("%s", event.Message)
+		default:
+			return
+		}
+		if err := notifier.Notify(ctx, event); err != nil {
+			log.Error("发送告警失败: %v", err)
+		}
+	})
+	log.Info("收到退出信号，已停止常驻模式")
+	return 0
+}
+
+// runSync 通过共享的 Runner 执行一次同步。
+// 如果已有同步在运行，则直接跳过并返回 false。
+func runSync(ctx context.Context, isFullSync bool) (sync_lib.Result, bool) {
+	result, ran := runner.TryRun(ctx, log, sync_lib.ConfigFromApp(*appConfig), isFullSync, httpClient)
+	if !ran {
+		log.Warn("同步任务已在运行中，本次请求被跳过")
+		return result, false
+	}
+	pushMetrics(isFullSync, result)
+	return result, true
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+)
+
+// pushMetrics 将一次同步的结果推送到 Pushgateway。
+// 推送失败只记录日志，不影响同步本身的退出码。
+func pushMetrics(isFullSync bool, result sync_lib.Result) {
+	if appConfig.PushgatewayURL == "" {
+		return
+	}
+
+	// 使用独立的 context，确保收到退出信号后仍能推送最后一次的结果
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := sync_lib.PushMetrics(ctx, httpClient, appConfig.PushgatewayURL, appConfig.PushgatewayJob, isFullSync, result); err != nil {
+		log.Error("推送指标到 Pushgateway 失败: %v", err)
+		return
+	}
+	log.Debug("已推送同步指标到 Pushgateway: %s", appConfig.PushgatewayURL)
+}
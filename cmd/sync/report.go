@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+)
+
+// duplicatesCommand 扫描 WebDAV 同步目录，输出内容相同的文件及其浪费的空间。
+// 使用 --json 时日志改为输出到标准错误，标准输出中只包含报告。
+func duplicatesCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出报告")
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	if *asJSON {
+		logOutput = os.Stderr
+	}
+	common.apply()
+
+	report, err := sync_lib.FindDuplicates(ctx, log, sync_lib.ConfigFromApp(*appConfig), httpClient)
+	if err != nil {
+		log.Error("生成重复文件报告失败: %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		return printJSON(report)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, group := range report.Groups {
+		fmt.Fprintf(tw, "# 第 %d 组  大小: %d B  浪费: %d B  SHA-256: %s\n", i+1, group.Size, group.WastedBytes, group.Hash)
+		for _, file := range group.Files {
+			fmt.Fprintf(tw, "  %s\t%v\n", file.Path, file.ImageIDs)
+		}
+	}
+	fmt.Fprintf(tw, "共扫描 %d 个文件，%d 组重复，%d 个多余副本，浪费 %d B\n",
+		report.ScannedFiles, len(report.Groups), report.DuplicateFiles, report.WastedBytes)
+	if err := tw.Flush(); err != nil {
+		log.Error("输出报告失败: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// verifyCommand 校验 WebDAV 同步目录中的所有图片，发现损坏文件时返回退出码 1。
+func verifyCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出报告")
+	olderThan := fs.Int("older-than", appConfig.VerifyOlderDays, "只校验超过该天数未上传或校验过的文件（需要配置 SYNC_INDEX_FILE），0 表示校验全部文件")
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	if *asJSON {
+		logOutput = os.Stderr
+	}
+	common.apply()
+	appConfig.VerifyOlderDays = *olderThan
+
+	report, err := sync_lib.Verify(ctx, log, sync_lib.ConfigFromApp(*appConfig), httpClient)
+	if err != nil {
+		log.Error("校验失败: %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		if code := printJSON(report); code != 0 {
+			return code
+		}
+	} else {
+		for _, file := range report.Corrupted {
+			fmt.Printf("损坏: %s (%s)\n", file.Path, file.Error)
+		}
+		fmt.Printf("共扫描 %d 个文件，校验 %d 个，跳过 %d 个（其中最近已校验 %d 个），损坏 %d 个\n",
+			report.ScannedFiles, report.CheckedFiles, report.SkippedFiles+report.RecentFiles, report.RecentFiles, len(report.Corrupted))
+	}
+	if len(report.Corrupted) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// mappingCommand 导出 NodeImage 直链到 WebDAV 路径的映射，默认输出到标准输出。
+func mappingCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("mapping", flag.ExitOnError)
+	format := fs.String("format", "json", "输出格式：json 或 csv")
+	output := fs.String("o", "", "输出文件路径，默认输出到标准输出")
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	if *output == "" {
+		logOutput = os.Stderr
+	}
+	common.apply()
+
+	if *format != "json" && *format != "csv" {
+		log.Error("无效的输出格式: %s，可选值为 json 或 csv", *format)
+		return 2
+	}
+
+	mappings, err := sync_lib.BuildURLMapping(ctx, log, sync_lib.ConfigFromApp(*appConfig), httpClient)
+	if err != nil {
+		log.Error("生成链接映射失败: %v", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Error("无法创建输出文件: %v", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "csv" {
+		err = sync_lib.WriteURLMappingCSV(out, mappings)
+	} else {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(mappings)
+	}
+	if err != nil {
+		log.Error("写入链接映射失败: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// printJSON 将报告以缩进的 JSON 格式输出到标准输出，并返回退出码。
+func printJSON(v interface{}) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Error("输出报告失败: %v", err)
+		return 1
+	}
+	return 0
+}
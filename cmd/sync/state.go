@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+)
+
+// stateCommand 导出或导入同步状态归档。
+func stateCommand(ctx context.Context, args []string) int {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		log.Error("用法: sync state export [-o file] | sync state import <file>")
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("state "+action, flag.ExitOnError)
+	output := fs.String("o", "", "导出时的输出文件路径，默认输出到标准输出")
+	common := registerCommonFlags(fs)
+	fs.Parse(args)
+	if action == "export" && *output == "" {
+		logOutput = os.Stderr
+	}
+	common.apply()
+	syncConfig := sync_lib.ConfigFromApp(*appConfig)
+
+	if action == "export" {
+		var out io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				log.Error("无法创建输出文件: %v", err)
+				return 1
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := sync_lib.ExportState(ctx, log, syncConfig, httpClient, out); err != nil {
+			log.Error("导出状态失败: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if fs.NArg() != 1 {
+		log.Error("用法: sync state import <file>")
+		return 2
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Error("无法打开状态归档: %v", err)
+		return 1
+	}
+	defer f.Close()
+
+	// 导入会改写分批游标，必须与同步进程互斥
+	lock, err := common.acquireLock()
+	if err != nil {
+		log.Error("无法获取锁文件，可能已有另一个同步进程在运行: %v", err)
+		return 1
+	}
+	defer lock.Release()
+
+	if _, err := sync_lib.ImportState(ctx, log, syncConfig, httpClient, f); err != nil {
+		log.Error("导入状态失败: %v", err)
+		return 1
+	}
+	return 0
+}
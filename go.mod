@@ -1,4 +1,4 @@
-module nodeimage_webdav_webui
+module github.com/zouzonghao/nodeimage_webdav_vercel
 
 go 1.23.0
 
@@ -9,9 +9,11 @@ require (
 	github.com/joho/godotenv v1.5.1
 )
 
-require github.com/klauspost/compress v1.18.0
-
 require (
-	github.com/gorilla/securecookie v1.1.2 // indirect
-	github.com/gorilla/sessions v1.4.0 // indirect
+	github.com/gorilla/sessions v1.4.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/sys v0.26.0
+	golang.org/x/text v0.19.0
 )
+
+require github.com/gorilla/securecookie v1.1.2 // indirect
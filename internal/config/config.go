@@ -3,6 +3,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 )
 
@@ -15,11 +17,59 @@ type Config struct {
 	WebdavUsername  string
 	WebdavPassword  string
 	WebdavBasePath  string // WebDAV 上的同步根目录
-	SyncConcurrency int    // 同步操作的并发数
+	SyncConcurrency int    // 同步操作的并发数（启用自适应并发时为上限）
+	DeleteWorkers   int    // 删除、清理旧版本和重命名的并发数，独立于上传，0 表示与 SyncConcurrency 相同
+	AutoConcurrency bool   // 是否根据限流、超时和响应时间自动调整并发数
+	SyncRetries     int    // 单个上传/删除失败后的重试次数
+	OpTimeout       int    // 单个下载/上传/删除操作的超时时间（秒），0 表示不限制
+	VerifyUploads   bool   // 上传后确认文件已以预期大小存在于 WebDAV 上，才计为成功
+	QuotaAction     string // WebDAV 剩余空间不足以容纳计划上传的文件时的处理方式: "abort"、"trim" 或 "off"
+	MinFreeMB       int    // 上传后 WebDAV 上至少需要保留的剩余空间 (MB)
+	DeleteMode      string // 删除模式：delete 直接删除，version 重命名为带时间戳的旧版本
+	DeleteScope     string // 删除范围：all 删除所有多余的文件，managed 只删除本工具管理的文件
+	MatchBy         string // 文件对应方式：name 按文件名，id 优先按图片 ID（记录在 WebDAV 状态目录中）
+	UnicodeNorm     string // 对比前文件名的 Unicode 规范化方式：nfc 或 off
+	KeepVersions    int    // 每个文件最多保留的旧版本数，0 表示不限
+	VersionMaxAge   int    // 旧版本的最长保留天数，0 表示不限
+	Snapshots       bool   // 快照模式：只增不删，每次全量同步后写入一份清单
+	Scope           string // 同步范围来源（文件路径或 URL），只同步其中引用的图片，为空则同步全部
+	JobsFile        string // Web UI 多任务配置文件 (JSON) 的路径，文件不存在时只运行一个由环境变量定义的默认任务
 	SyncInterval    int    // 定时增量同步的间隔（分钟）
+	FullSyncEvery   int    // 每进行多少次增量同步后，将下一次定时同步改为全量同步，0 表示不自动全量同步
+	SyncJitter      int    // 每次定时同步额外推迟的最大随机时间（秒），避免多个实例同时请求
+	SyncWindow      string // 允许定时同步的时间段，例如 "01:00-06:00"，为空表示不限制
+	SyncTimezone    string // 解释 SyncWindow 所用的时区，例如 "Asia/Shanghai"，为空表示系统本地时区
+	FailThreshold   int    // 定时同步连续失败多少次后延长间隔并发送降级告警，0 表示不退避
+	MaxBackoff      int    // 连续失败后定时同步间隔的上限（分钟）
+	MaxParallelJobs int    // Web UI 中同时运行的同步任务数上限，0 表示不限
+	QueueFullFirst  bool   // 同步队列中全量同步是否优先于增量同步
 	LogLevel        string // 日志级别 (e.g., "info", "debug")
 	Port            string // Web 服务器监听的端口
 	Password        string // 用于访问 Web 界面的密码
+	LockFile        string // 命令行工具使用的锁文件路径，防止多个进程同时同步
+	NotifyWebhook   string // 接收告警的 Webhook 地址，告警以 JSON 形式 POST，为空则不发送
+	TelegramToken   string // 发送告警的 Telegram 机器人令牌
+	TelegramChatID  string // 接收告警的 Telegram 会话 ID
+	HealthchecksURL string // Healthchecks.io 的 ping 地址，同步开始和结束时发送 ping，为空则不发送
+	UptimeKumaURL   string // Uptime Kuma Push 监控的地址，每次同步结束后推送状态，为空则不推送
+	PushgatewayURL  string // Prometheus Pushgateway 地址，为空则不推送指标
+	PushgatewayJob  string // 推送指标时使用的 job 名称
+	CronSecret      string // Serverless 端点的访问令牌，Vercel Cron 会以 Bearer Token 形式携带
+	BatchSize       int    // Serverless 分批同步时每次调用处理的文件数，0 表示不分批
+	KVRestAPIURL    string // Vercel KV 的 REST API 地址，用于在 Serverless 调用之间缓存 WebDAV 文件列表
+	KVRestAPIToken  string // Vercel KV 的访问令牌
+	WebdavCacheTTL  int    // WebDAV 文件列表在 Vercel KV 或缓存文件中的有效时间（分钟）
+	WebdavCacheFile string // 进程退出时保存 WebDAV 文件列表缓存、启动时恢复的文件路径，为空则不保存
+	SyncIndexFile   string // 本地索引文件的路径，记录每个文件的同步时间和大小，为空则不使用
+	VerifyOlderDays int    // 配置了本地索引时，校验只检查超过该天数未上传或校验过的文件，0 表示检查全部文件
+	SyncRetryFile   string // 重试队列文件的路径，记录重试耗尽后仍上传失败的文件，为空则不记录
+	HistoryFile     string // 同步运行记录文件的路径，用于统计接口，为空则只保存在内存中
+	HistoryLimit    int    // 最多保留的运行记录条数，0 表示不限
+	StatsToken      string // 统计接口的访问令牌，Grafana 等工具以 Bearer Token 形式携带，无需登录 Web 界面
+	SessionHours    int    // 普通登录会话的有效时间（小时）
+	RememberDays    int    // 勾选“记住我”时登录会话的有效时间（天），0 表示不提供该选项
+	SessionSecret   string // 登录会话 Cookie 的签名密钥，为空则使用 SessionKeyFile 中保存的随机密钥
+	SessionKeyFile  string // 保存随机生成的会话签名密钥的文件路径
 }
 
 // LoadConfig 从环境变量加载配置，并应用默认值。
@@ -33,14 +83,75 @@ func LoadConfig() *Config {
 		WebdavPassword:  os.Getenv("WEBDAV_PASSWORD"),
 		WebdavBasePath:  os.Getenv("WEBDAV_FOLDER"),
 		SyncConcurrency: getEnvAsInt("SYNC_CONCURRENCY", 5),
+		DeleteWorkers:   getEnvAsInt("SYNC_DELETE_CONCURRENCY", 10),
+		AutoConcurrency: getEnvAsBool("SYNC_AUTO_CONCURRENCY", true),
+		SyncRetries:     getEnvAsInt("SYNC_RETRIES", 2),
+		OpTimeout:       getEnvAsInt("SYNC_OP_TIMEOUT", 300),
+		VerifyUploads:   getEnvAsBool("SYNC_VERIFY_UPLOADS", true),
+		QuotaAction:     getEnv("SYNC_QUOTA_ACTION", "abort"),
+		MinFreeMB:       getEnvAsInt("SYNC_MIN_FREE_MB", 0),
+		DeleteMode:      getEnv("SYNC_DELETE_MODE", "delete"),
+		DeleteScope:     getEnv("SYNC_DELETE_SCOPE", "all"),
+		MatchBy:         getEnv("SYNC_MATCH_BY", "name"),
+		UnicodeNorm:     getEnv("SYNC_UNICODE_NORMALIZATION", "nfc"),
+		KeepVersions:    getEnvAsInt("SYNC_KEEP_VERSIONS", 0),
+		VersionMaxAge:   getEnvAsInt("SYNC_VERSION_MAX_AGE_DAYS", 0),
+		Snapshots:       getEnvAsBool("SYNC_SNAPSHOTS", false),
+		Scope:           os.Getenv("SYNC_SCOPE"),
+		JobsFile:        getEnv("JOBS_FILE", "jobs.json"),
 		SyncInterval:    getEnvAsInt("SYNC_INTERVAL", 0), // 0 表示禁用定时同步
+		FullSyncEvery:   getEnvAsInt("FULL_SYNC_EVERY", 0),
+		SyncJitter:      getEnvAsInt("SYNC_JITTER", 0),
+		SyncWindow:      os.Getenv("SYNC_WINDOW"),
+		SyncTimezone:    os.Getenv("SYNC_TIMEZONE"),
+		FailThreshold:   getEnvAsInt("SYNC_FAILURE_THRESHOLD", 3),
+		MaxBackoff:      getEnvAsInt("SYNC_MAX_BACKOFF", 1440),
+		MaxParallelJobs: getEnvAsInt("JOBS_MAX_PARALLEL", 1),
+		QueueFullFirst:  getEnvAsBool("SYNC_QUEUE_FULL_FIRST", true),
 		LogLevel:        getEnv("LOG_LEVEL", "info"),
 		Port:            getEnv("PORT", "37372"),
 		Password:        os.Getenv("PASSWORD"),
+		LockFile:        getEnv("SYNC_LOCK_FILE", filepath.Join(os.TempDir(), "nodeimage-sync.lock")),
+		NotifyWebhook:   os.Getenv("NOTIFY_WEBHOOK_URL"),
+		TelegramToken:   os.Getenv("NOTIFY_TELEGRAM_TOKEN"),
+		TelegramChatID:  os.Getenv("NOTIFY_TELEGRAM_CHAT_ID"),
+		HealthchecksURL: os.Getenv("HEALTHCHECKS_URL"),
+		UptimeKumaURL:   os.Getenv("UPTIME_KUMA_URL"),
+		PushgatewayURL:  os.Getenv("PUSHGATEWAY_URL"),
+		PushgatewayJob:  getEnv("PUSHGATEWAY_JOB", "nodeimage_sync"),
+		CronSecret:      os.Getenv("CRON_SECRET"),
+		BatchSize:       getEnvAsInt("SYNC_BATCH_SIZE", 0),
+		KVRestAPIURL:    os.Getenv("KV_REST_API_URL"),
+		KVRestAPIToken:  os.Getenv("KV_REST_API_TOKEN"),
+		WebdavCacheTTL:  getEnvAsInt("WEBDAV_CACHE_TTL", 60),
+		WebdavCacheFile: getEnv("WEBDAV_CACHE_FILE", "webdav-cache.json"),
+		SyncIndexFile:   os.Getenv("SYNC_INDEX_FILE"),
+		VerifyOlderDays: getEnvAsInt("VERIFY_OLDER_THAN_DAYS", 0),
+		SyncRetryFile:   getEnv("SYNC_RETRY_FILE", "sync-retries.json"),
+		HistoryFile:     getEnv("SYNC_HISTORY_FILE", "sync-history.json"),
+		HistoryLimit:    getEnvAsInt("SYNC_HISTORY_LIMIT", 1000),
+		StatsToken:      os.Getenv("STATS_TOKEN"),
+		SessionHours:    getEnvAsInt("SESSION_HOURS", 12),
+		RememberDays:    getEnvAsInt("REMEMBER_ME_DAYS", 30),
+		SessionSecret:   os.Getenv("SESSION_SECRET"),
+		SessionKeyFile:  getEnv("SESSION_KEY_FILE", "session.key"),
 	}
 	return cfg
 }
 
+// Changed 返回 old 和 updated 中取值不同的配置项名称，按字段顺序排列。
+// 只返回名称而不包含取值，以免在日志或接口中泄露凭据。
+func Changed(old, updated Config) []string {
+	changed := []string{}
+	a, b := reflect.ValueOf(old), reflect.ValueOf(updated)
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, a.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
 // getEnv 是一个辅助函数，用于读取环境变量，如果为空则返回默认值。
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -57,3 +168,12 @@ func getEnvAsInt(name string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsBool 是一个辅助函数，用于将环境变量解析为布尔值，如果失败或未设置则返回默认值。
+func getEnvAsBool(name string, fallback bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ErrNotFound 表示任务不存在。
+var ErrNotFound = errors.New("任务不存在")
+
+// ErrExists 表示任务 ID 已被占用。
+var ErrExists = errors.New("任务 ID 已存在")
+
+// redacted 是 API 返回凭据时使用的占位符。更新任务时传入该值表示保留原有凭据。
+const redacted = "******"
+
+// Create 添加一个新任务并持久化。启用且设置了间隔的任务会立即开始定时同步。
+func (m *Manager) Create(spec Spec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.jobs[spec.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, spec.ID)
+	}
+
+	j := &job{spec: spec}
+	m.jobs[spec.ID] = j
+	m.order = append(m.order, spec.ID)
+	if err := m.save(); err != nil {
+		delete(m.jobs, spec.ID)
+		m.order = m.order[:len(m.order)-1]
+		return err
+	}
+	m.schedule(j)
+	m.log.Info("已添加同步任务: %s", spec.ID)
+	return nil
+}
+
+// Update 替换任务的配置并持久化，定时计划随之更新。
+// 凭据字段为占位符 "******" 时保留原值，因此可以直接提交从 List 得到的配置。
+// 正在进行的同步不受影响，新配置从下一次同步开始生效。
+func (m *Manager) Update(spec Spec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[spec.ID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, spec.ID)
+	}
+
+	j.mutex.Lock()
+	old := j.spec
+	spec.NodeImageCookie = keepRedacted(spec.NodeImageCookie, old.NodeImageCookie)
+	spec.NodeImageAPIKey = keepRedacted(spec.NodeImageAPIKey, old.NodeImageAPIKey)
+	spec.WebdavPassword = keepRedacted(spec.WebdavPassword, old.WebdavPassword)
+	spec.HealthchecksURL = keepRedacted(spec.HealthchecksURL, old.HealthchecksURL)
+	spec.UptimeKumaURL = keepRedacted(spec.UptimeKumaURL, old.UptimeKumaURL)
+	j.spec = spec
+	j.mutex.Unlock()
+
+	if err := m.save(); err != nil {
+		j.mutex.Lock()
+		j.spec = old
+		j.mutex.Unlock()
+		return err
+	}
+	m.unschedule(j)
+	m.schedule(j)
+	m.log.Info("已更新同步任务: %s", spec.ID)
+	return nil
+}
+
+// SetEnabled 启用或停用任务并持久化。停用的任务不再定时同步，但仍可手动触发。
+func (m *Manager) SetEnabled(id string, enabled bool) error {
+	j, err := m.get(id)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	j.mutex.Lock()
+	spec := j.spec
+	j.mutex.Unlock()
+	spec.Enabled = enabled
+	return m.Update(spec)
+}
+
+// Delete 删除任务并持久化，同时移除它在队列中的请求。正在进行的同步会继续完成。
+func (m *Manager) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	delete(m.jobs, id)
+	index := slices.Index(m.order, id)
+	m.order = slices.Delete(m.order, index, index+1)
+	if err := m.save(); err != nil {
+		m.jobs[id] = j
+		m.order = slices.Insert(m.order, index, id)
+		return err
+	}
+	m.unschedule(j)
+	m.dropQueued(j)
+	m.log.Info("已删除同步任务: %s", id)
+	return nil
+}
+
+// dropQueued 移除任务 j 在队列中的请求。调用方必须持有 m.mutex。
+func (m *Manager) dropQueued(j *job) {
+	m.queue = slices.DeleteFunc(m.queue, func(req *Request) bool {
+		if req.job != j {
+			return false
+		}
+		close(req.done)
+		return true
+	})
+}
+
+// ReloadReport 描述重新加载任务文件后任务的变化。
+type ReloadReport struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Updated []string `json:"updated"`
+}
+
+// Reload 用重新读取的任务文件 file 中的任务 specs 替换当前的任务，不写回文件。
+// 新增的任务开始定时同步，被移除的任务停止定时器并移出队列；所有保留的任务都会重新设置定时计划，
+// 使基础配置中的时间窗口、抖动和退避设置同时生效。正在进行的同步不受影响。
+func (m *Manager) Reload(file string, specs []Spec) ReloadReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	report := ReloadReport{Added: []string{}, Removed: []string{}, Updated: []string{}}
+	m.file = file
+
+	kept := make(map[string]bool, len(specs))
+	order := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		kept[spec.ID] = true
+		order = append(order, spec.ID)
+		j, ok := m.jobs[spec.ID]
+		if !ok {
+			j = &job{spec: spec}
+			m.jobs[spec.ID] = j
+			m.schedule(j)
+			report.Added = append(report.Added, spec.ID)
+			continue
+		}
+		j.mutex.Lock()
+		if j.spec != spec {
+			j.spec = spec
+			report.Updated = append(report.Updated, spec.ID)
+		}
+		j.mutex.Unlock()
+		m.unschedule(j)
+		m.schedule(j)
+	}
+
+	for _, id := range m.order {
+		if kept[id] {
+			continue
+		}
+		j := m.jobs[id]
+		delete(m.jobs, id)
+		m.unschedule(j)
+		m.dropQueued(j)
+		report.Removed = append(report.Removed, id)
+	}
+	m.order = order
+	m.log.Info("已重新加载同步任务: 新增 %d 个, 移除 %d 个, 修改 %d 个", len(report.Added), len(report.Removed), len(report.Updated))
+	return report
+}
+
+// save 将所有任务写回任务文件。先写入临时文件再重命名，避免写入中断导致文件损坏。
+// 任务文件中包含凭据，因此权限为 0600。调用方必须持有 m.mutex。
+func (m *Manager) save() error {
+	if m.file == "" {
+		return fmt.Errorf("未设置 JOBS_FILE，无法保存任务")
+	}
+	specs := make([]Spec, 0, len(m.order))
+	for _, id := range m.order {
+		j := m.jobs[id]
+		j.mutex.Lock()
+		specs = append(specs, j.spec)
+		j.mutex.Unlock()
+	}
+	data, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.file), ".jobs-*.json")
+	if err != nil {
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.file); err != nil {
+		return fmt.Errorf("保存任务文件失败: %w", err)
+	}
+	return nil
+}
+
+// keepRedacted 在 value 为占位符时返回 old。
+func keepRedacted(value, old string) string {
+	if value == redacted {
+		return old
+	}
+	return value
+}
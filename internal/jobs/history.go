@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+)
+
+// Run 是一次同步运行的记录。
+type Run struct {
+	Job         string    `json:"job"`
+	Mode        string    `json:"mode"` // full 或 incremental
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	Success     bool      `json:"success"`
+	Uploaded    int       `json:"uploaded"`
+	Deleted     int       `json:"deleted"`
+	Renamed     int       `json:"renamed"`
+	Failed      int       `json:"failed"`
+	UploadBytes int64     `json:"uploadBytes"`
+	Duration    float64   `json:"durationSeconds"`
+	Message     string    `json:"message"`
+}
+
+// newRun 根据同步结果生成运行记录。
+func newRun(job string, isFullSync bool, startedAt time.Time, result sync_lib.Result) Run {
+	mode := "incremental"
+	if isFullSync {
+		mode = "full"
+	}
+	return Run{
+		Job:         job,
+		Mode:        mode,
+		StartedAt:   startedAt,
+		FinishedAt:  time.Now(),
+		Success:     result.Success,
+		Uploaded:    result.Uploaded,
+		Deleted:     result.Deleted,
+		Renamed:     result.Renamed,
+		Failed:      result.Failed,
+		UploadBytes: result.UploadSize,
+		Duration:    result.Duration.Seconds(),
+		Message:     result.Message,
+	}
+}
+
+// Throughput 返回本次运行的平均上传速度（字节/秒），没有上传时为 0。
+func (r Run) Throughput() float64 {
+	if r.Uploaded == 0 || r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.UploadBytes) / r.Duration
+}
+
+// History 保存最近的同步运行记录，用于统计和图表。
+// 设置了文件路径时，每次记录后写回文件，重启后可以恢复。
+type History struct {
+	mutex sync.Mutex
+	file  string
+	limit int
+	runs  []Run // 按结束时间排列，最早的在前
+}
+
+// LoadHistory 从 file 加载运行记录，最多保留最近的 limit 条（limit <= 0 表示不限）。
+// file 为空时只在内存中保存；文件不存在时返回空记录。
+func LoadHistory(file string, limit int) (*History, error) {
+	h := &History{file: file, limit: limit}
+	if file == "" {
+		return h, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("读取运行记录失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.runs); err != nil {
+		return h, fmt.Errorf("解析运行记录失败: %w", err)
+	}
+	h.trim()
+	return h, nil
+}
+
+// Add 追加一条运行记录并写回文件。
+func (h *History) Add(run Run) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.runs = append(h.runs, run)
+	h.trim()
+	return h.save()
+}
+
+// Runs 返回任务 job（为空表示所有任务）在 [from, to] 内结束的运行记录，按结束时间排列。
+// from 或 to 为零值时不限制对应一侧。
+func (h *History) Runs(job string, from, to time.Time) []Run {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	runs := []Run{}
+	for _, run := range h.runs {
+		if job != "" && run.Job != job {
+			continue
+		}
+		if (!from.IsZero() && run.FinishedAt.Before(from)) || (!to.IsZero() && run.FinishedAt.After(to)) {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// trim 丢弃超出数量上限的最早记录。调用方必须持有 h.mutex。
+func (h *History) trim() {
+	if h.limit > 0 && len(h.runs) > h.limit {
+		h.runs = append([]Run(nil), h.runs[len(h.runs)-h.limit:]...)
+	}
+}
+
+// save 将运行记录写回文件。调用方必须持有 h.mutex。
+func (h *History) save() error {
+	if h.file == "" {
+		return nil
+	}
+	data, err := json.Marshal(h.runs)
+	if err != nil {
+		return fmt.Errorf("序列化运行记录失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.file), ".history-*.json")
+	if err != nil {
+		return fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.file); err != nil {
+		return fmt.Errorf("保存运行记录失败: %w", err)
+	}
+	return nil
+}
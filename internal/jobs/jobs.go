@@ -0,0 +1,132 @@
+// package jobs 管理 Web UI 中的多个同步任务。
+// 每个任务可以有自己的 NodeImage 账号、WebDAV 目标和定时计划，并拥有独立的同步锁、运行统计和 WebSocket 主题，
+// 互不阻塞；未在任务中设置的字段继承自环境变量配置。
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+)
+
+// DefaultJobID 是未配置任务文件时，由环境变量生成的唯一任务的 ID。
+const DefaultJobID = "default"
+
+// idPattern 限制任务 ID 的字符，ID 会出现在 URL 和 WebSocket 主题中。
+var idPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Spec 描述一个同步任务。凭据和目标字段为空时使用环境变量中的对应配置。
+type Spec struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Enabled         bool   `json:"enabled"`
+	Interval        int    `json:"interval"` // 定时同步的间隔（分钟），0 表示不定时
+	FullSync        bool   `json:"fullSync"` // 定时同步是否为全量同步，默认为增量同步
+	NodeImageCookie string `json:"nodeimageCookie,omitempty"`
+	NodeImageAPIKey string `json:"nodeimageApiKey,omitempty"`
+	WebdavURL       string `json:"webdavUrl,omitempty"`
+	WebdavUsername  string `json:"webdavUsername,omitempty"`
+	WebdavPassword  string `json:"webdavPassword,omitempty"`
+	WebdavFolder    string `json:"webdavFolder,omitempty"`
+	Concurrency     int    `json:"concurrency,omitempty"`
+	DeleteWorkers   int    `json:"deleteConcurrency,omitempty"`
+	FullSyncEvery   int    `json:"fullSyncEvery,omitempty"`   // 每进行多少次增量同步后自动执行一次全量同步，0 表示使用 FULL_SYNC_EVERY
+	HealthchecksURL string `json:"healthchecksUrl,omitempty"` // 该任务的 Healthchecks.io ping 地址，多个任务应使用不同的检查
+	UptimeKumaURL   string `json:"uptimeKumaUrl,omitempty"`   // 该任务的 Uptime Kuma Push 地址，多个任务应使用不同的监控
+}
+
+// Validate 检查任务配置是否有效。
+func (s Spec) Validate() error {
+	if !idPattern.MatchString(s.ID) {
+		return fmt.Errorf("无效的任务 ID %q，只能包含字母、数字、- 和 _", s.ID)
+	}
+	if s.Interval < 0 {
+		return fmt.Errorf("任务 %s 的同步间隔不能为负数", s.ID)
+	}
+	if s.FullSyncEvery < 0 {
+		return fmt.Errorf("任务 %s 的自动全量同步次数不能为负数", s.ID)
+	}
+	if s.Concurrency < 0 || s.Concurrency > 64 {
+		return fmt.Errorf("任务 %s 的并发数必须在 0-64 之间", s.ID)
+	}
+	if s.DeleteWorkers < 0 || s.DeleteWorkers > 64 {
+		return fmt.Errorf("任务 %s 的删除并发数必须在 0-64 之间", s.ID)
+	}
+	return nil
+}
+
+// Apply 将任务中设置的字段覆盖到基础配置上，返回该任务实际使用的配置。
+func (s Spec) Apply(base config.Config) config.Config {
+	cfg := base
+	if s.NodeImageCookie != "" {
+		cfg.NodeImageCookie = s.NodeImageCookie
+	}
+	if s.NodeImageAPIKey != "" {
+		cfg.NodeImageAPIKey = s.NodeImageAPIKey
+	}
+	if s.WebdavURL != "" {
+		cfg.WebdavURL = s.WebdavURL
+	}
+	if s.WebdavUsername != "" {
+		cfg.WebdavUsername = s.WebdavUsername
+	}
+	if s.WebdavPassword != "" {
+		cfg.WebdavPassword = s.WebdavPassword
+	}
+	if s.WebdavFolder != "" {
+		cfg.WebdavBasePath = s.WebdavFolder
+	}
+	if s.Concurrency > 0 {
+		cfg.SyncConcurrency = s.Concurrency
+	}
+	if s.DeleteWorkers > 0 {
+		cfg.DeleteWorkers = s.DeleteWorkers
+	}
+	if s.FullSyncEvery > 0 {
+		cfg.FullSyncEvery = s.FullSyncEvery
+	}
+	if s.HealthchecksURL != "" {
+		cfg.HealthchecksURL = s.HealthchecksURL
+	}
+	if s.UptimeKumaURL != "" {
+		cfg.UptimeKumaURL = s.UptimeKumaURL
+	}
+	return cfg
+}
+
+// LoadSpecs 读取任务列表。
+// JOBS_FILE 为空或文件不存在时，返回一个完全继承环境变量配置的默认任务，
+// 其定时计划与原先的 SYNC_INTERVAL 增量同步一致。
+func LoadSpecs(cfg *config.Config) ([]Spec, error) {
+	defaultSpecs := []Spec{{ID: DefaultJobID, Name: "默认任务", Enabled: true, Interval: cfg.SyncInterval}}
+	if cfg.JobsFile == "" {
+		return defaultSpecs, nil
+	}
+
+	data, err := os.ReadFile(cfg.JobsFile)
+	if os.IsNotExist(err) {
+		return defaultSpecs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取任务文件失败: %w", err)
+	}
+
+	var specs []Spec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("解析任务文件失败: %w", err)
+	}
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[spec.ID] {
+			return nil, fmt.Errorf("任务 ID 重复: %s", spec.ID)
+		}
+		seen[spec.ID] = true
+	}
+	return specs, nil
+}
@@ -0,0 +1,371 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/schedule"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/notify"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
+)
+
+// Status 是一个任务的运行状态，用于 API 展示。
+type Status struct {
+	Spec       Spec             `json:"spec"`
+	Running    bool             `json:"running"`
+	Runs       int              `json:"runs"`
+	Failures   int              `json:"failures"`
+	SinceFull  int              `json:"sinceFullSync"` // 上次成功的全量同步之后进行的增量同步次数
+	LastRun    time.Time        `json:"lastRun,omitempty"`
+	LastResult *sync_lib.Result `json:"lastResult,omitempty"`
+}
+
+// job 是一个正在被管理的任务。
+type job struct {
+	spec   Spec
+	runner sync_lib.Runner
+	stop   chan struct{} // 关闭时停止该任务的定时器
+	active *Request      // 正在执行的同步请求，由 Manager.mutex 保护
+
+	mutex      sync.Mutex // 保护 spec 和以下运行统计
+	running    bool
+	runs       int
+	failures   int
+	sinceFull  int // 上次成功的全量同步之后进行的增量同步次数
+	lastRun    time.Time
+	lastResult *sync_lib.Result
+}
+
+// Manager 运行多个同步任务。每个任务都有自己的定时器和同步锁。
+// 所有定时和手动触发都先进入一个优先级队列，再在并行上限内按优先级执行，同一个任务不会同时运行两次。
+// 任务的日志和状态通过以任务 ID 为主题的 WebSocket 消息推送。
+type Manager struct {
+	mutex sync.RWMutex
+	jobs  map[string]*job
+	order []string // 任务的展示顺序
+
+	queue  []*Request // 等待执行的同步请求，按入队顺序排列
+	seq    int64      // 最后一个请求的序号
+	active int        // 正在执行的请求数
+
+	file       string               // 任务文件路径，通过 API 修改任务后写回该文件
+	history    *History             // 所有任务的运行记录
+	base       func() config.Config // 返回当前的基础配置（Web UI 可能在运行时修改 Cookie）
+	hub        *websocket.Hub
+	log        logger.Logger
+	httpClient *http.Client
+}
+
+// NewManager 创建任务管理器。调用 Start 之前不会执行任何定时同步。
+// file 是任务文件路径，通过 Create/Update/Delete 等方法修改任务后会写回该文件。
+// 每次同步的结果都会追加到 history 中。
+func NewManager(specs []Spec, file string, history *History, base func() config.Config, hub *websocket.Hub, log logger.Logger, httpClient *http.Client) *Manager {
+	m := &Manager{
+		jobs:       make(map[string]*job),
+		file:       file,
+		history:    history,
+		base:       base,
+		hub:        hub,
+		log:        log,
+		httpClient: httpClient,
+	}
+	for _, spec := range specs {
+		m.jobs[spec.ID] = &job{spec: spec}
+		m.order = append(m.order, spec.ID)
+	}
+	return m
+}
+
+// Start 为所有启用且设置了间隔的任务启动定时器。
+func (m *Manager) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, id := range m.order {
+		m.schedule(m.jobs[id])
+	}
+}
+
+// schedule 启动任务的定时器。调用方必须持有 m.mutex。
+func (m *Manager) schedule(j *job) {
+	if !j.spec.Enabled || j.spec.Interval <= 0 {
+		return
+	}
+	j.stop = make(chan struct{})
+	base := m.base()
+	interval, jitter := time.Duration(j.spec.Interval)*time.Minute, time.Duration(base.SyncJitter)*time.Second
+	sched, err := schedule.New(interval, jitter, base.SyncWindow, base.SyncTimezone)
+	if err != nil {
+		m.log.Error("同步时间窗口配置无效，任务 %s 将不限制执行时间: %v", j.spec.ID, err)
+		sched = schedule.Schedule{Interval: interval, Jitter: jitter}
+	}
+	if base.SyncWindow != "" && err == nil {
+		m.log.Info("任务 %s 已设置定时同步，每 %d 分钟执行一次，仅在 %s (%s) 内执行", j.spec.ID, j.spec.Interval, base.SyncWindow, sched.Location)
+	} else {
+		m.log.Info("任务 %s 已设置定时同步，每 %d 分钟执行一次", j.spec.ID, j.spec.Interval)
+	}
+
+	backoff := &schedule.Backoff{Threshold: base.FailThreshold, Max: time.Duration(base.MaxBackoff) * time.Minute}
+	sched.Backoff = backoff
+
+	// 定时器等待请求执行完毕后再计算下一次执行时间，使连续失败后的退避立即生效
+	isFullSync, stop := j.spec.FullSync, j.stop
+	go schedule.Run(stop, sched, func() {
+		req := m.enqueue(j, isFullSync || m.fullSyncDue(j), false)
+		if req == nil {
+			return
+		}
+		select {
+		case <-req.done:
+		case <-stop:
+			return
+		}
+		if req.result != nil {
+			m.observe(j, backoff, sched.Interval, *req.result)
+		}
+	})
+}
+
+// fullSyncDue 判断本次定时增量同步是否应改为全量同步：
+// 距上次成功的全量同步已进行了 FULL_SYNC_EVERY 次增量同步时返回 true，使删除和差异能定期被修正。
+// 全量同步失败时计数不会清零，下一次定时同步会继续尝试全量同步。
+func (m *Manager) fullSyncDue(j *job) bool {
+	spec := j.currentSpec()
+	cfg := spec.Apply(m.base())
+	runs := j.incrementalRuns()
+	if cfg.FullSyncEvery <= 0 || runs < cfg.FullSyncEvery {
+		return false
+	}
+	if cfg.NodeImageCookie == "" {
+		m.log.Warn("任务 %s 未设置 NodeImage Cookie，无法执行自动全量同步，本次仍为增量同步", spec.ID)
+		return false
+	}
+	m.log.Info("任务 %s 已进行 %d 次增量同步，本次定时同步改为全量同步", spec.ID, runs)
+	return true
+}
+
+// observe 记录一次定时同步的结果。连续失败达到 SYNC_FAILURE_THRESHOLD 次时发送一次“同步降级”告警，
+// 降级后第一次成功时发送“同步已恢复”告警，而不是每次失败都静默地重试。
+func (m *Manager) observe(j *job, backoff *schedule.Backoff, interval time.Duration, result sync_lib.Result) {
+	degraded, recovered := backoff.Record(result.Success)
+	id := j.currentSpec().ID
+	var event notify.Event
+	switch {
+	case degraded:
+		event = notify.Degraded(id, backoff.Failures(), backoff.Interval(interval), result.Message)
+		m.log.Error("%s", event.Message)
+	case recovered:
+		event = notify.Recovered(id)
+		m.log.Info("%s", event.Message)
+	default:
+		return
+	}
+
+	eventJSON, _ := json.Marshal(event)
+	m.hub.Broadcast(websocket.Message{Type: "alert", Content: string(eventJSON), Topic: id})
+
+	base := m.base()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := notify.New(base.NotifyWebhook, base.TelegramToken, base.TelegramChatID, m.httpClient).Notify(ctx, event); err != nil {
+		m.log.Error("发送告警失败: %v", err)
+	}
+}
+
+// unschedule 停止任务的定时器。调用方必须持有 m.mutex。
+func (m *Manager) unschedule(j *job) {
+	if j.stop != nil {
+		close(j.stop)
+		j.stop = nil
+	}
+}
+
+// Stop 停止所有任务的定时器。正在进行的同步不会被中断。
+func (m *Manager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, j := range m.jobs {
+		m.unschedule(j)
+	}
+}
+
+// Trigger 将一次手动同步加入队列，手动触发优先于定时触发。任务不存在时返回错误。
+func (m *Manager) Trigger(id string, isFullSync bool) error {
+	j, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	m.enqueue(j, isFullSync, true)
+	return nil
+}
+
+// RunOnce 立即在当前 goroutine 中执行一次任务同步，不经过队列，用于无头的单次运行模式。
+// ctx 被取消时同步会尽快结束。任务不存在或同步已在运行时返回错误。
+func (m *Manager) RunOnce(ctx context.Context, id string, isFullSync bool) (sync_lib.Result, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return sync_lib.Result{}, err
+	}
+	result, ran := m.run(ctx, j, isFullSync)
+	if !ran {
+		return result, fmt.Errorf("任务 %s 的同步已在运行中", j.currentSpec().ID)
+	}
+	return result, nil
+}
+
+// Config 返回指定任务实际使用的同步配置。
+func (m *Manager) Config(id string) (sync_lib.Config, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return sync_lib.Config{}, err
+	}
+	return sync_lib.ConfigFromApp(j.currentSpec().Apply(m.base())), nil
+}
+
+// Do 在持有指定任务同步锁的情况下执行 fn（例如导入状态），语义同 sync.Runner.TryDo。
+func (m *Manager) Do(id string, fn func() sync_lib.Result) (sync_lib.Result, bool, error) {
+	j, err := m.get(id)
+	if err != nil {
+		return sync_lib.Result{}, false, err
+	}
+	result, ran := j.runner.TryDo(m.log, fn)
+	return result, ran, nil
+}
+
+// History 返回所有任务的运行记录。
+func (m *Manager) History() *History {
+	return m.history
+}
+
+// List 返回所有任务的状态。
+func (m *Manager) List() []Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	statuses := make([]Status, 0, len(m.order))
+	for _, id := range m.order {
+		statuses = append(statuses, m.jobs[id].status())
+	}
+	return statuses
+}
+
+// get 按 ID 查找任务。ID 为空时返回第一个任务，使未指定任务的旧请求仍然有效。
+func (m *Manager) get(id string) (*job, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if id == "" && len(m.order) > 0 {
+		id = m.order[0]
+	}
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("任务不存在: %s", id)
+	}
+	return j, nil
+}
+
+// run 执行一次任务同步，并通过任务的 WebSocket 主题推送日志、状态和结果。
+// 同步被跳过时 ran 为 false。
+func (m *Manager) run(ctx context.Context, j *job, isFullSync bool) (result sync_lib.Result, ran bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.log.Error("任务 %s 捕获到未处理的 panic: %v", j.currentSpec().ID, r)
+		}
+	}()
+
+	spec := j.currentSpec()
+	base := m.base()
+	wsLogger := logger.NewTopicWebsocketLogger(m.hub, m.log, logger.StringToLogLevel(base.LogLevel), spec.ID)
+	syncConfig := sync_lib.ConfigFromApp(spec.Apply(base))
+
+	startedAt := time.Now()
+	result, ran = j.runner.TryDo(wsLogger, func() sync_lib.Result {
+		j.setRunning(true)
+		defer j.setRunning(false)
+		wsLogger.Info("")
+		m.hub.Broadcast(websocket.Message{Type: "syncStatus", Content: "syncing", Topic: spec.ID})
+		return sync_lib.RunSync(ctx, wsLogger, syncConfig, isFullSync, m.httpClient)
+	})
+	if !ran {
+		wsLogger.Warn("同步任务已在运行中，本次请求被跳过")
+		return result, false
+	}
+	j.record(result, isFullSync)
+	if err := m.history.Add(newRun(spec.ID, isFullSync, startedAt, result)); err != nil {
+		m.log.Warn("记录任务 %s 的运行结果失败: %v", spec.ID, err)
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	m.hub.Broadcast(websocket.Message{Type: "syncResult", Content: string(resultJSON), Topic: spec.ID})
+	m.hub.Broadcast(websocket.Message{Type: "syncStatus", Content: "idle", Topic: spec.ID})
+	return result, true
+}
+
+// currentSpec 返回任务配置的副本。任务配置可能被 Update 并发修改。
+func (j *job) currentSpec() Spec {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.spec
+}
+
+func (j *job) setRunning(running bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.running = running
+}
+
+// incrementalRuns 返回上次成功的全量同步之后进行的增量同步次数。
+func (j *job) incrementalRuns() int {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.sinceFull
+}
+
+// record 记录一次同步的结果。成功的全量同步（包括手动触发的）会将增量同步计数清零。
+func (j *job) record(result sync_lib.Result, isFullSync bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.runs++
+	if !result.Success {
+		j.failures++
+	}
+	switch {
+	case !isFullSync:
+		j.sinceFull++
+	case result.Success:
+		j.sinceFull = 0
+	}
+	j.lastRun = time.Now()
+	j.lastResult = &result
+}
+
+// status 返回任务状态的快照。返回的 Spec 不包含凭据。
+func (j *job) status() Status {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	spec := j.spec
+	spec.NodeImageCookie, spec.NodeImageAPIKey, spec.WebdavPassword = redact(spec.NodeImageCookie), redact(spec.NodeImageAPIKey), redact(spec.WebdavPassword)
+	// 监控地址中包含推送令牌，持有它就能伪造心跳，同样视为凭据
+	spec.HealthchecksURL, spec.UptimeKumaURL = redact(spec.HealthchecksURL), redact(spec.UptimeKumaURL)
+	return Status{
+		Spec:       spec,
+		Running:    j.running,
+		Runs:       j.runs,
+		Failures:   j.failures,
+		SinceFull:  j.sinceFull,
+		LastRun:    j.lastRun,
+		LastResult: j.lastResult,
+	}
+}
+
+// redact 隐藏凭据，只表明是否已设置。
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
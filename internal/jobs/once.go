@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/lockfile"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/notify"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
+)
+
+// RunOnce 不启动 Web UI，使用与 Web UI 相同的配置和同步引擎（任务文件、WebDAV 缓存、运行记录、指标推送和告警）
+// 执行一次任务 id（为空时为第一个任务）的同步，返回进程退出码：成功为 0，失败为 1。适合由 cron 或计划任务调用。
+// 运行期间持有 cfg.LockFile，避免多个进程同时同步；ctx 被取消（例如收到退出信号）时同步会尽快结束。
+// Web UI 程序的 --once 和命令行工具的 run 子命令共用这一实现。
+func RunOnce(ctx context.Context, cfg *config.Config, hub *websocket.Hub, log logger.Logger, httpClient *http.Client, id string, isFullSync bool) int {
+	if cfg.LockFile != "" {
+		lock, err := lockfile.Acquire(cfg.LockFile)
+		if err != nil {
+			log.Error("无法获取锁文件，可能已有另一个同步进程在运行: %v", err)
+			return 1
+		}
+		defer lock.Release()
+	}
+
+	if cfg.WebdavCacheFile != "" {
+		if count, err := sync_lib.LoadWebdavCache(cfg.WebdavCacheFile, time.Duration(cfg.WebdavCacheTTL)*time.Minute); err != nil {
+			log.Warn("恢复 WebDAV 缓存失败，将重新获取文件列表: %v", err)
+		} else if count > 0 {
+			log.Info("已从 %s 恢复 %d 个同步目标的 WebDAV 文件列表缓存", cfg.WebdavCacheFile, count)
+		}
+		defer func() {
+			if _, err := sync_lib.SaveWebdavCache(cfg.WebdavCacheFile); err != nil {
+				log.Warn("保存 WebDAV 缓存失败: %v", err)
+			}
+		}()
+	}
+
+	specs, err := LoadSpecs(cfg)
+	if err != nil {
+		log.Error("加载同步任务失败: %v", err)
+		return 1
+	}
+	history, err := LoadHistory(cfg.HistoryFile, cfg.HistoryLimit)
+	if err != nil {
+		log.Warn("加载运行记录失败，将从空记录开始: %v", err)
+	}
+	// 不调用 Start，因此不会启动任何定时同步
+	manager := NewManager(specs, cfg.JobsFile, history, func() config.Config { return *cfg }, hub, log, httpClient)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Warn("收到退出信号，正在停止当前同步...")
+		case <-done:
+		}
+	}()
+	result, err := manager.RunOnce(ctx, id, isFullSync)
+	close(done)
+	if err != nil {
+		log.Error("%v", err)
+		return 1
+	}
+
+	if id == "" && len(specs) > 0 {
+		id = specs[0].ID
+	}
+	report(cfg, log, httpClient, id, isFullSync, result)
+	if !result.Success {
+		return 1
+	}
+	return 0
+}
+
+// report 将单次运行的结果推送到 Pushgateway，失败时通过 NOTIFY_* 配置的渠道发送告警。
+// 推送和告警失败只记录日志，不影响退出码。
+func report(cfg *config.Config, log logger.Logger, httpClient *http.Client, id string, isFullSync bool, result sync_lib.Result) {
+	// 使用独立的 context，确保收到退出信号后仍能推送最后一次的结果
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if cfg.PushgatewayURL != "" {
+		if err := sync_lib.PushMetrics(ctx, httpClient, cfg.PushgatewayURL, cfg.PushgatewayJob, isFullSync, result); err != nil {
+			log.Error("推送指标到 Pushgateway 失败: %v", err)
+		}
+	}
+	if !result.Success {
+		notifier := notify.New(cfg.NotifyWebhook, cfg.TelegramToken, cfg.TelegramChatID, httpClient)
+		if err := notifier.Notify(ctx, notify.Failed(id, result.Message)); err != nil {
+			log.Error("发送告警失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/sdnotify"
+)
+
+// ErrNotQueued 表示队列中没有指定的请求（可能已经开始执行）。
+var ErrNotQueued = errors.New("队列中没有该请求")
+
+// Request 是同步队列中的一次同步请求。
+type Request struct {
+	Seq        int64      `json:"seq"`
+	JobID      string     `json:"jobId"`
+	FullSync   bool       `json:"fullSync"`
+	Manual     bool       `json:"manual"`   // 是否为手动触发，手动触发优先于定时触发
+	Priority   int        `json:"priority"` // 数值越大越先执行
+	EnqueuedAt time.Time  `json:"enqueuedAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+
+	job    *job
+	done   chan struct{}    // 请求执行完毕或被移出队列时关闭
+	result *sync_lib.Result // 请求的同步结果，done 关闭后可读；被取消或跳过时为 nil
+}
+
+// Queue 是同步队列的快照。
+type Queue struct {
+	Running []Request `json:"running"`
+	Pending []Request `json:"pending"` // 按执行顺序排列
+}
+
+// priority 计算请求的优先级：手动触发总是优先于定时触发；
+// 同为手动或同为定时的请求中，全量与增量的先后由 SYNC_QUEUE_FULL_FIRST 决定。
+func priority(manual, isFullSync, fullFirst bool) int {
+	p := 0
+	if manual {
+		p += 2
+	}
+	if isFullSync == fullFirst {
+		p++
+	}
+	return p
+}
+
+// enqueue 将一次同步请求加入队列。
+// 同一任务相同模式的请求已在排队时不会重复加入（手动触发会把已排队的定时请求提升为手动优先级），
+// 因此无论触发堆积了多少次，每个任务的每种模式最多只会补跑一次。
+// 返回本次触发所在的请求（可能是合并后的已有请求），任务已被删除时返回 nil。
+func (m *Manager) enqueue(j *job, isFullSync, manual bool) *Request {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id := j.currentSpec().ID
+	if m.jobs[id] != j {
+		return nil // 任务已被删除
+	}
+
+	fullFirst := m.base().QueueFullFirst
+	for _, req := range m.queue {
+		if req.job == j && req.FullSync == isFullSync {
+			if manual && !req.Manual {
+				req.Manual = true
+				req.Priority = priority(true, isFullSync, fullFirst)
+			}
+			m.log.Debug("任务 %s 已有相同的同步请求在排队，本次触发已合并", id)
+			return req
+		}
+	}
+
+	m.seq++
+	req := &Request{
+		Seq:        m.seq,
+		JobID:      id,
+		FullSync:   isFullSync,
+		Manual:     manual,
+		Priority:   priority(manual, isFullSync, fullFirst),
+		EnqueuedAt: time.Now(),
+		job:        j,
+		done:       make(chan struct{}),
+	}
+	m.queue = append(m.queue, req)
+	m.dispatch()
+	if req.StartedAt == nil {
+		m.log.Info("任务 %s 的同步请求已加入队列，当前有 %d 个请求在排队", id, len(m.queue))
+	}
+	return req
+}
+
+// dispatch 按优先级启动排队中的请求，直到达到 JOBS_MAX_PARALLEL 的并行上限。
+// 任务正在运行时，它的请求会继续排队，但不会阻塞其他任务的请求。调用方必须持有 m.mutex。
+func (m *Manager) dispatch() {
+	limit := m.base().MaxParallelJobs
+	for limit <= 0 || m.active < limit {
+		index := m.next()
+		if index < 0 {
+			return
+		}
+		req := m.queue[index]
+		m.queue = slices.Delete(m.queue, index, index+1)
+		now := time.Now()
+		req.StartedAt = &now
+		req.job.active = req
+		m.active++
+		go m.execute(req)
+		m.reportStatus()
+	}
+}
+
+// next 返回下一个可以执行的请求的下标：所属任务未在运行，优先级最高，同优先级时最早入队。
+// 没有可执行的请求时返回 -1。调用方必须持有 m.mutex。
+func (m *Manager) next() int {
+	best := -1
+	for i, req := range m.queue {
+		if req.job.active != nil {
+			continue
+		}
+		if best < 0 || req.Priority > m.queue[best].Priority {
+			best = i
+		}
+	}
+	return best
+}
+
+// execute 执行一个已出队的请求，完成后继续调度队列。
+func (m *Manager) execute(req *Request) {
+	defer func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		req.job.active = nil
+		m.active--
+		close(req.done)
+		m.dispatch()
+		m.reportStatus()
+	}()
+	if result, ran := m.run(context.Background(), req.job, req.FullSync); ran {
+		req.result = &result
+	}
+}
+
+// reportStatus 将正在运行和排队中的同步报告给 systemd，显示在 systemctl status 中。
+// 不在 systemd 下运行时不做任何事。调用方必须持有 m.mutex。
+func (m *Manager) reportStatus() {
+	var running []string
+	for _, id := range m.order {
+		if req := m.jobs[id].active; req != nil {
+			mode := "增量"
+			if req.FullSync {
+				mode = "全量"
+			}
+			running = append(running, fmt.Sprintf("%s (%s)", id, mode))
+		}
+	}
+	status := "空闲"
+	if len(running) > 0 {
+		status = "正在同步: " + strings.Join(running, ", ")
+	}
+	if len(m.queue) > 0 {
+		status += fmt.Sprintf("，排队中: %d", len(m.queue))
+	}
+	sdnotify.Status(status)
+}
+
+// Queue 返回正在运行和排队中的同步请求。
+func (m *Manager) Queue() Queue {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	q := Queue{Running: []Request{}, Pending: make([]Request, 0, len(m.queue))}
+	for _, id := range m.order {
+		if req := m.jobs[id].active; req != nil {
+			q.Running = append(q.Running, *req)
+		}
+	}
+	for _, req := range m.queue {
+		q.Pending = append(q.Pending, *req)
+	}
+	sort.SliceStable(q.Pending, func(i, k int) bool {
+		return q.Pending[i].Priority > q.Pending[k].Priority
+	})
+	return q
+}
+
+// Cancel 从队列中移除一个尚未开始的请求。正在运行的同步无法取消。
+func (m *Manager) Cancel(seq int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	index := slices.IndexFunc(m.queue, func(req *Request) bool { return req.Seq == seq })
+	if index < 0 {
+		return fmt.Errorf("%w: %d", ErrNotQueued, seq)
+	}
+	m.log.Info("已取消任务 %s 排队中的同步请求", m.queue[index].JobID)
+	close(m.queue[index].done)
+	m.queue = slices.Delete(m.queue, index, index+1)
+	return nil
+}
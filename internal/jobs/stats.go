@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/grafana"
+)
+
+// runsTarget 是以表格形式返回运行记录的查询目标。
+const runsTarget = "runs"
+
+// runSeries 是运行记录中可以绘制为时间序列的指标，每次运行在其结束时间处产生一个数据点。
+var runSeries = []struct {
+	name  string
+	label string
+	value func(r Run) float64
+}{
+	{"uploaded", "上传文件数", func(r Run) float64 { return float64(r.Uploaded) }},
+	{"deleted", "删除文件数", func(r Run) float64 { return float64(r.Deleted) }},
+	{"renamed", "重命名文件数", func(r Run) float64 { return float64(r.Renamed) }},
+	{"failed", "失败操作数", func(r Run) float64 { return float64(r.Failed) }},
+	{"upload_bytes", "上传字节数", func(r Run) float64 { return float64(r.UploadBytes) }},
+	{"duration_seconds", "耗时（秒）", func(r Run) float64 { return r.Duration }},
+	{"throughput_bytes_per_second", "平均上传速度（字节/秒）", func(r Run) float64 { return r.Throughput() }},
+	{"success", "是否成功 (1 为成功，0 为失败)", func(r Run) float64 {
+		if r.Success {
+			return 1
+		}
+		return 0
+	}},
+}
+
+// queryPayload 是查询编辑器中可以填写的附加参数，用于筛选运行记录。
+type queryPayload struct {
+	Job  string `json:"job"`  // 只查询该任务，为空表示所有任务
+	Mode string `json:"mode"` // 只查询该模式（full 或 incremental），为空表示两种模式
+}
+
+// Metrics 实现 grafana.Source，返回所有时间序列指标和运行记录表格。
+func (h *History) Metrics() []grafana.Metric {
+	metrics := make([]grafana.Metric, 0, len(runSeries)+1)
+	for _, s := range runSeries {
+		metrics = append(metrics, grafana.Metric{Label: s.label, Value: s.name})
+	}
+	return append(metrics, grafana.Metric{Label: "运行记录（表格）", Value: runsTarget})
+}
+
+// Query 实现 grafana.Source。时间序列按任务分组，查询涉及多个任务时序列名为 "指标 (任务 ID)"。
+func (h *History) Query(q *grafana.Query, t grafana.Target) ([]any, error) {
+	var payload queryPayload
+	if len(t.Payload) > 0 && string(t.Payload) != "null" {
+		if err := json.Unmarshal(t.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("无效的查询参数: %w", err)
+		}
+	}
+	runs := h.Runs(payload.Job, q.Range.From, q.Range.To)
+	if payload.Mode != "" {
+		filtered := runs[:0]
+		for _, run := range runs {
+			if run.Mode == payload.Mode {
+				filtered = append(filtered, run)
+			}
+		}
+		runs = filtered
+	}
+	if q.MaxDataPoints > 0 && len(runs) > q.MaxDataPoints {
+		runs = runs[len(runs)-q.MaxDataPoints:]
+	}
+
+	if t.Target == runsTarget {
+		table := grafana.NewTable(
+			grafana.Column{Text: "Time", Type: "time"},
+			grafana.Column{Text: "Job", Type: "string"},
+			grafana.Column{Text: "Mode", Type: "string"},
+			grafana.Column{Text: "Success", Type: "number"},
+			grafana.Column{Text: "Uploaded", Type: "number"},
+			grafana.Column{Text: "Deleted", Type: "number"},
+			grafana.Column{Text: "Failed", Type: "number"},
+			grafana.Column{Text: "Upload Bytes", Type: "number"},
+			grafana.Column{Text: "Duration", Type: "number"},
+			grafana.Column{Text: "Message", Type: "string"},
+		)
+		for _, run := range runs {
+			success := 0
+			if run.Success {
+				success = 1
+			}
+			table.Rows = append(table.Rows, []any{run.FinishedAt.UnixMilli(), run.Job, run.Mode, success,
+				run.Uploaded, run.Deleted, run.Failed, run.UploadBytes, run.Duration, run.Message})
+		}
+		return []any{table}, nil
+	}
+
+	for _, s := range runSeries {
+		if s.name != t.Target {
+			continue
+		}
+		var order []string
+		series := make(map[string]*grafana.TimeSeries)
+		for _, run := range runs {
+			ts := series[run.Job]
+			if ts == nil {
+				ts = &grafana.TimeSeries{Target: s.name, Datapoints: [][2]float64{}}
+				series[run.Job] = ts
+				order = append(order, run.Job)
+			}
+			ts.Datapoints = append(ts.Datapoints, grafana.Point(s.value(run), run.FinishedAt))
+		}
+		result := make([]any, 0, len(order))
+		for _, job := range order {
+			ts := series[job]
+			if len(order) > 1 {
+				ts.Target = fmt.Sprintf("%s (%s)", s.name, job)
+			}
+			result = append(result, *ts)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("未知的指标: %s", t.Target)
+}
@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// maxDuration 是 time.Duration 能表示的最长时间，翻倍时以它为上限避免溢出。
+const maxDuration = time.Duration(1<<63 - 1)
+
+// Backoff 统计定时同步的连续失败次数。
+// 连续失败达到 Threshold 次后进入“降级”状态，之后每多失败一次，同步间隔翻倍，最长不超过 Max；
+// 一次成功的同步即恢复正常间隔。Backoff 可以被多个 goroutine 同时使用。
+type Backoff struct {
+	Threshold int           // 进入降级状态所需的连续失败次数，0 表示不退避
+	Max       time.Duration // 退避后的最长间隔，0 表示不限制
+
+	mutex    sync.Mutex
+	failures int
+}
+
+// Record 记录一次同步的结果。
+// degraded 表示本次失败使连续失败次数恰好达到阈值，recovered 表示本次成功结束了降级状态，
+// 调用方据此各发送一次告警，而不是每次失败都发送。
+func (b *Backoff) Record(success bool) (degraded, recovered bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if success {
+		recovered = b.degraded()
+		b.failures = 0
+		return false, recovered
+	}
+	b.failures++
+	return b.Threshold > 0 && b.failures == b.Threshold, false
+}
+
+// Failures 返回当前的连续失败次数。
+func (b *Backoff) Failures() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.failures
+}
+
+// Interval 返回考虑退避后的同步间隔：达到阈值时为 interval 的 2 倍，之后每多失败一次再翻倍。
+func (b *Backoff) Interval(interval time.Duration) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.degraded() {
+		return interval
+	}
+	backoff := interval
+	for i := b.Threshold; i <= b.failures && backoff < maxDuration/2; i++ {
+		if b.Max > 0 && backoff >= b.Max {
+			break
+		}
+		backoff *= 2
+	}
+	if b.Max > 0 && backoff > b.Max {
+		backoff = max(b.Max, interval)
+	}
+	return backoff
+}
+
+// degraded 判断是否处于降级状态。调用方必须持有 b.mutex。
+func (b *Backoff) degraded() bool {
+	return b.Threshold > 0 && b.failures >= b.Threshold
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffInterval(t *testing.T) {
+	const interval = 10 * time.Minute
+	tests := []struct {
+		name      string
+		threshold int
+		max       time.Duration
+		failures  int
+		want      time.Duration
+	}{
+		{name: "没有失败", threshold: 3, failures: 0, want: interval},
+		{name: "未达到阈值", threshold: 3, failures: 2, want: interval},
+		{name: "达到阈值时翻倍", threshold: 3, failures: 3, want: 2 * interval},
+		{name: "每多失败一次再翻倍", threshold: 3, failures: 4, want: 4 * interval},
+		{name: "连续翻倍", threshold: 3, failures: 6, want: 16 * interval},
+		{name: "阈值为 0 表示不退避", threshold: 0, failures: 10, want: interval},
+		{name: "不超过 Max", threshold: 3, max: time.Hour, failures: 6, want: time.Hour},
+		{name: "未达到 Max 时照常翻倍", threshold: 3, max: time.Hour, failures: 4, want: 4 * interval},
+		{name: "恰好等于 Max", threshold: 3, max: 40 * time.Minute, failures: 4, want: 40 * time.Minute},
+		{name: "Max 小于间隔时不缩短间隔", threshold: 1, max: time.Minute, failures: 3, want: interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Backoff{Threshold: tt.threshold, Max: tt.max}
+			for i := 0; i < tt.failures; i++ {
+				b.Record(false)
+			}
+			if got := b.Interval(interval); got != tt.want {
+				t.Errorf("Interval() = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffIntervalNoOverflow(t *testing.T) {
+	b := &Backoff{Threshold: 1}
+	for i := 0; i < 200; i++ {
+		b.Record(false)
+	}
+	if got := b.Interval(time.Minute); got < maxDuration/2 {
+		t.Errorf("连续失败 200 次后 Interval() = %s，期望翻倍到接近上限而不是溢出", got)
+	}
+}
+
+func TestBackoffRecord(t *testing.T) {
+	b := &Backoff{Threshold: 2}
+	steps := []struct {
+		success       bool
+		wantDegraded  bool
+		wantRecovered bool
+	}{
+		{success: false},
+		{success: false, wantDegraded: true}, // 恰好达到阈值时告警一次
+		{success: false},                     // 之后的失败不再告警
+		{success: true, wantRecovered: true},
+		{success: true}, // 已恢复，不再重复通知
+		{success: false},
+		{success: false, wantDegraded: true},
+	}
+	for i, step := range steps {
+		degraded, recovered := b.Record(step.success)
+		if degraded != step.wantDegraded || recovered != step.wantRecovered {
+			t.Errorf("第 %d 次 Record(%v) = (%v, %v)，期望 (%v, %v)",
+				i+1, step.success, degraded, recovered, step.wantDegraded, step.wantRecovered)
+		}
+	}
+	if b.Failures() != 2 {
+		t.Errorf("Failures() = %d，期望 2", b.Failures())
+	}
+	b.Record(true)
+	if got := b.Interval(time.Minute); got != time.Minute {
+		t.Errorf("成功后 Interval() = %s，期望恢复为 1m0s", got)
+	}
+}
@@ -0,0 +1,93 @@
+// package schedule 计算定时同步的执行时间，供 Web UI 的任务管理器和命令行的常驻模式共用。
+package schedule
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Schedule 描述一个定时计划。
+type Schedule struct {
+	Interval time.Duration  // 两次执行之间的间隔
+	Jitter   time.Duration  // 每次执行额外推迟的最大随机时间，0 表示不推迟
+	Windows  []Window       // 允许执行的时间段，为空表示不限制；落在时间段之外的执行会推迟到下一个时间段开始
+	Location *time.Location // 解释时间段所用的时区，nil 表示系统本地时区
+	Backoff  *Backoff       // 连续失败后延长间隔，nil 表示不退避
+}
+
+// First 返回启动后第一次执行的时间：立即执行，但加上随机抖动，
+// 使同一份 compose 模板部署的多个实例不会在同一时刻同时请求 NodeImage。
+func (s Schedule) First(now time.Time) time.Time {
+	return s.allowed(now).Add(s.jitter())
+}
+
+// Next 返回上一次执行之后的下一次执行时间。
+// 设置了 Backoff 且连续失败次数达到阈值时，间隔按 Backoff 延长。
+func (s Schedule) Next(last time.Time) time.Time {
+	interval := s.Interval
+	if s.Backoff != nil {
+		interval = s.Backoff.Interval(interval)
+	}
+	return s.allowed(last.Add(interval)).Add(s.jitter())
+}
+
+// allowed 返回不早于 t 且位于允许时间段内的最早时间。
+// 抖动在推迟之后再加上，避免所有实例都恰好在时间段开始的那一刻执行。
+func (s Schedule) allowed(t time.Time) time.Time {
+	if len(s.Windows) == 0 {
+		return t
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := t.In(loc)
+	var earliest time.Time
+	for _, w := range s.Windows {
+		if w.contains(local) {
+			return t
+		}
+		if start := w.nextStart(local); earliest.IsZero() || start.Before(earliest) {
+			earliest = start
+		}
+	}
+	return earliest
+}
+
+// jitter 返回 [0, Jitter) 之间的随机时间。
+func (s Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.Jitter)
+}
+
+// Run 按计划反复调用 fn，直到 stop 被关闭。fn 在 Run 所在的 goroutine 中同步执行，
+// 下一次执行时间从 fn 返回后开始计算。
+func Run(stop <-chan struct{}, s Schedule, fn func()) {
+	timer := time.NewTimer(time.Until(s.First(time.Now())))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			fn()
+			timer.Reset(time.Until(s.Next(time.Now())))
+		case <-stop:
+			return
+		}
+	}
+}
+
+// New 创建定时计划。windows 为以逗号分隔的允许时间段（见 ParseWindows），
+// timezone 为解释时间段所用的时区名称，空字符串表示系统本地时区。
+func New(interval, jitter time.Duration, windows, timezone string) (Schedule, error) {
+	parsed, err := ParseWindows(windows)
+	if err != nil {
+		return Schedule{}, err
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return Schedule{}, err
+	}
+	return Schedule{Interval: interval, Jitter: jitter, Windows: parsed, Location: loc}, nil
+}
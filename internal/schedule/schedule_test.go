@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	windows, err := ParseWindows("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	degraded := &Backoff{Threshold: 1}
+	degraded.Record(false)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		last     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "不限制时间段",
+			schedule: Schedule{Interval: time.Hour},
+			last:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "跨越午夜的时间段内",
+			schedule: Schedule{Interval: time.Hour, Windows: windows, Location: time.UTC},
+			last:     time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 2, 0, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "落在时间段外推迟到当晚开始",
+			schedule: Schedule{Interval: time.Hour, Windows: windows, Location: time.UTC},
+			last:     time.Date(2024, 5, 2, 5, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 2, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "退避后的间隔",
+			schedule: Schedule{Interval: time.Hour, Backoff: degraded},
+			last:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Next(tt.last); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s，期望 %s", tt.last, got, tt.want)
+			}
+		})
+	}
+}
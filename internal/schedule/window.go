@@ -0,0 +1,88 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	_ "time/tzdata" // 在没有系统时区数据库的精简容器中也能解析 SYNC_TIMEZONE
+)
+
+// Window 是一天中允许定时同步的时间段，以当天零点起的分钟数表示。
+// End 小于 Start 时表示跨越午夜（例如 22:00-06:00）。
+type Window struct {
+	Start int
+	End   int
+}
+
+// ParseWindows 解析以逗号分隔的时间段列表，例如 "01:00-06:00" 或 "00:00-07:00,22:00-24:00"。
+// 空字符串表示不限制。
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("无效的时间段 %q，格式应为 HH:MM-HH:MM", part)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时间段 %q: %w", part, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时间段 %q: %w", part, err)
+		}
+		if start == end || start == 24*60 {
+			return nil, fmt.Errorf("无效的时间段 %q", part)
+		}
+		windows = append(windows, Window{Start: start, End: end})
+	}
+	return windows, nil
+}
+
+// parseClock 将 HH:MM 解析为当天零点起的分钟数，允许 24:00 表示一天结束。
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间 %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// LoadLocation 解析时区名称（例如 Asia/Shanghai），空字符串表示系统本地时区。
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// contains 判断 t（已转换到目标时区）是否在时间段内。
+func (w Window) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// nextStart 返回 t 之后（已转换到目标时区）该时间段的下一个开始时间。
+func (w Window) nextStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, w.Start, 0, 0, t.Location())
+	if !start.After(t) {
+		start = time.Date(t.Year(), t.Month(), t.Day()+1, 0, w.Start, 0, 0, t.Location())
+	}
+	return start
+}
@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Window
+		wantErr bool
+	}{
+		{name: "空字符串表示不限制", spec: ""},
+		{name: "只有分隔符", spec: " , ,"},
+		{name: "当天的时间段", spec: "01:00-06:30", want: []Window{{Start: 60, End: 390}}},
+		{name: "跨越午夜", spec: "22:00-06:00", want: []Window{{Start: 1320, End: 360}}},
+		{name: "以 24:00 结束", spec: "22:00-24:00", want: []Window{{Start: 1320, End: 1440}}},
+		{name: "全天", spec: "00:00-24:00", want: []Window{{Start: 0, End: 1440}}},
+		{name: "跨越午夜到 00:00", spec: "23:00-00:00", want: []Window{{Start: 1380, End: 0}}},
+		{
+			name: "多个时间段和空白",
+			spec: " 00:00-07:00 , 22:00-24:00 ,",
+			want: []Window{{Start: 0, End: 420}, {Start: 1320, End: 1440}},
+		},
+		{name: "从 24:00 开始", spec: "24:00-06:00", wantErr: true},
+		{name: "开始等于结束", spec: "06:00-06:00", wantErr: true},
+		{name: "缺少结束时间", spec: "06:00", wantErr: true},
+		{name: "超过 24:00", spec: "22:00-24:30", wantErr: true},
+		{name: "无效的小时", spec: "25:00-06:00", wantErr: true},
+		{name: "不是时间", spec: "night-morning", wantErr: true},
+		{name: "其中一个无效", spec: "01:00-02:00,03:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWindows(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindows(%q) 错误 = %v，期望错误 %v", tt.spec, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseWindows(%q) = %v，期望 %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	tests := []struct {
+		name   string
+		window Window
+		clock  string
+		want   bool
+	}{
+		{name: "当天时间段内", window: Window{Start: 60, End: 360}, clock: "03:00", want: true},
+		{name: "包含开始时间", window: Window{Start: 60, End: 360}, clock: "01:00", want: true},
+		{name: "不包含结束时间", window: Window{Start: 60, End: 360}, clock: "06:00", want: false},
+		{name: "跨越午夜的前半段", window: Window{Start: 1320, End: 360}, clock: "23:30", want: true},
+		{name: "跨越午夜的后半段", window: Window{Start: 1320, End: 360}, clock: "05:59", want: true},
+		{name: "跨越午夜的时间段外", window: Window{Start: 1320, End: 360}, clock: "12:00", want: false},
+		{name: "以 24:00 结束的最后一分钟", window: Window{Start: 1320, End: 1440}, clock: "23:59", want: true},
+		{name: "以 24:00 结束不包含午夜", window: Window{Start: 1320, End: 1440}, clock: "00:00", want: false},
+		{name: "全天", window: Window{Start: 0, End: 1440}, clock: "00:00", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock, err := time.Parse("15:04", tt.clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.window.contains(clock); got != tt.want {
+				t.Errorf("%+v.contains(%s) = %v，期望 %v", tt.window, tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		window Window
+		now    time.Time
+		want   time.Time
+	}{
+		{
+			name:   "当天稍后开始",
+			window: Window{Start: 120, End: 360},
+			now:    time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "恰好在开始时间推迟到第二天",
+			window: Window{Start: 120, End: 360},
+			now:    time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "今天已开始",
+			window: Window{Start: 1320, End: 360},
+			now:    time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 5, 2, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "午夜开始",
+			window: Window{Start: 0, End: 420},
+			now:    time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC),
+			want:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "跨月和跨年",
+			window: Window{Start: 60, End: 120},
+			now:    time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC),
+			want:   time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "夏令时开始的那一天只有 23 小时",
+			window: Window{Start: 60, End: 120},
+			now:    time.Date(2024, 3, 9, 23, 0, 0, 0, newYork),
+			want:   time.Date(2024, 3, 10, 1, 0, 0, 0, newYork),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.nextStart(tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextStart(%s) = %s，期望 %s", tt.now, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// package serverless 包含了 Vercel Serverless Functions 的实现。
+// api 目录下的每个文件只负责导出 Vercel 要求的处理函数，具体逻辑都放在这里，
+// 以便与 Web UI 和命令行共享同一个同步引擎。
+package serverless
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// httpClient 在同一个函数实例的多次调用（热启动）之间复用，以复用底层连接。
+var httpClient = sync_lib.NewHTTPClient(30 * time.Second)
+
+// runner 保证同一个函数实例内不会并发执行两次同步。
+var runner sync_lib.Runner
+
+// HandleSync 执行一次同步，并通过 SSE 实时输出日志和进度（事件格式见 sseWriter）。
+// 最后一个事件总是 result，其中 continue 字段表示是否还有未完成的分批操作。
+// 查询参数 format=json 时不使用 SSE，而是在同步结束后直接返回该汇总，失败时状态码为 500。
+//
+// 查询参数 mode 用于选择同步模式：
+//   - full（默认）：使用 NODEIMAGE_COOKIE 获取全部图片，执行上传和删除。
+//   - incremental：使用 NODEIMAGE_API_KEY 获取最新图片，只上传缺失的文件。
+//     Cookie 很快会过期，而 API Key 长期有效，更适合由定时任务触发的 Serverless 部署。
+//
+// 查询参数 batch（或环境变量 SYNC_BATCH_SIZE）大于 0 时启用分批模式：
+// 每次调用最多处理 batch 个文件，剩余部分的游标保存在 WebDAV 上，供下一次调用继续。
+//
+// 此外还支持 concurrency、base-path 和 dry-run 参数，详见 requestConfig。
+func HandleSync(w http.ResponseWriter, r *http.Request) {
+	appConfig := config.LoadConfig()
+	if status, err := authorize(r, appConfig); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	isFullSync, err := parseMode(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncConfig, err := requestConfig(r, appConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	batchSize := appConfig.BatchSize
+	if v := r.URL.Query().Get("batch"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "无效的 batch 参数: "+v, http.StatusBadRequest)
+			return
+		}
+		batchSize = n
+	}
+
+	level := logger.StringToLogLevel(appConfig.LogLevel)
+	run := func(log logger.Logger) (sync_lib.Result, bool) {
+		useKVCache(&syncConfig, appConfig, log)
+		return runner.TryDo(log, func() sync_lib.Result {
+			return sync_lib.RunBatch(r.Context(), log, syncConfig, isFullSync, batchSize, httpClient)
+		})
+	}
+
+	// format=json：不使用 SSE，日志只输出到函数日志，结束后返回一个汇总 JSON，适合 Vercel Cron 等调度器
+	if r.URL.Query().Get("format") == "json" {
+		result, ran := run(logger.New(level, os.Stdout))
+		if !ran {
+			writeError(w, http.StatusConflict, fmt.Errorf("同步任务已在运行中，本次请求被跳过"))
+			return
+		}
+		status := http.StatusOK
+		if !result.Success {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, newSummary(result))
+		return
+	}
+
+	sse, err := newSSEWriter(w, level)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sse.Close()
+
+	syncConfig.OnProgress = sse.progress
+	result, ran := run(sse)
+	if !ran {
+		sse.Warn("同步任务已在运行中，本次请求被跳过")
+		result = sync_lib.Result{Success: false, Message: "同步任务已在运行中，本次请求被跳过"}
+	}
+
+	// result 事件总是最后一个事件，告诉调度方本次结果以及是否需要继续调用，以完成剩余的分批操作
+	sse.result(newSummary(result))
+}
+
+// summary 是一次同步的精简结果，作为 SSE 的 result 事件或 format=json 的响应体。
+type summary struct {
+	Success         bool    `json:"success"`
+	Message         string  `json:"message"`
+	Uploaded        int     `json:"uploaded"`
+	Deleted         int     `json:"deleted"`
+	Renamed         int     `json:"renamed"`
+	Failed          int     `json:"failed"`
+	UploadBytes     int64   `json:"uploadBytes"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Continue        bool    `json:"continue"`
+	Remaining       int     `json:"remaining"`
+	Error           string  `json:"error,omitempty"` // 失败原因，result.Error 是接口类型，无法直接序列化
+}
+
+// newSummary 从同步结果生成精简结果。
+func newSummary(result sync_lib.Result) summary {
+	s := summary{
+		Success:         result.Success,
+		Message:         result.Message,
+		Uploaded:        result.Uploaded,
+		Deleted:         result.Deleted,
+		Renamed:         result.Renamed,
+		Failed:          result.Failed,
+		UploadBytes:     result.UploadSize,
+		DurationSeconds: result.Duration.Seconds(),
+		Continue:        result.Remaining > 0,
+		Remaining:       result.Remaining,
+	}
+	if result.Error != nil {
+		s.Error = result.Error.Error()
+	}
+	return s
+}
+
+// requestConfig 在环境变量配置的基础上，应用查询参数中的覆盖项，
+// 使同一个部署无需修改环境变量即可服务多种用途：
+//   - concurrency：上传/删除的并发数（1-64）。
+//   - base-path：WebDAV 上的同步目录，必须以 / 开头，且必须是 WEBDAV_FOLDER 本身或其子目录。
+//   - dry-run：为 true 时只输出计划，不执行任何上传或删除。
+//
+// 覆盖项只在设置了 CRON_SECRET 时接受，避免匿名请求改变同步范围。
+func requestConfig(r *http.Request, appConfig *config.Config) (sync_lib.Config, error) {
+	syncConfig := sync_lib.ConfigFromApp(*appConfig)
+	query := r.URL.Query()
+	if appConfig.CronSecret == "" && (query.Has("concurrency") || query.Has("base-path") || query.Has("dry-run")) {
+		return syncConfig, fmt.Errorf("未设置 CRON_SECRET，不接受 concurrency、base-path 和 dry-run 参数")
+	}
+
+	if v := query.Get("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 64 {
+			return syncConfig, fmt.Errorf("无效的 concurrency 参数: %s，取值范围为 1-64", v)
+		}
+		syncConfig.SyncConcurrency = n
+	}
+	if v := query.Get("base-path"); v != "" {
+		basePath, err := subPath(appConfig.WebdavBasePath, v)
+		if err != nil {
+			return syncConfig, err
+		}
+		syncConfig.WebdavBasePath = basePath
+	}
+	if v := query.Get("dry-run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return syncConfig, fmt.Errorf("无效的 dry-run 参数: %s", v)
+		}
+		syncConfig.DryRun = dryRun
+	}
+	return syncConfig, nil
+}
+
+// subPath 清理 base-path 参数 v，并检查它是 root（WEBDAV_FOLDER）本身或其子目录，
+// 防止通过 .. 或更上层的目录把同步（尤其是全量同步的删除）扩大到同步目录之外。
+func subPath(root, v string) (string, error) {
+	if !strings.HasPrefix(v, "/") {
+		return "", fmt.Errorf("无效的 base-path 参数: %s，必须以 / 开头", v)
+	}
+	cleaned := path.Clean(v)
+	base := path.Clean("/" + root)
+	if cleaned != base && base != "/" && !strings.HasPrefix(cleaned, base+"/") {
+		return "", fmt.Errorf("无效的 base-path 参数: %s，必须位于同步目录 '%s' 下", v, root)
+	}
+	return cleaned, nil
+}
+
+// parseMode 解析查询参数 mode，返回是否为全量同步。缺省为全量同步。
+func parseMode(r *http.Request) (bool, error) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "full":
+		return true, nil
+	case "incremental":
+		return false, nil
+	default:
+		return false, fmt.Errorf("无效的 mode 参数: %s，可选值为 full 或 incremental", mode)
+	}
+}
+
+// writeJSON 以 JSON 格式写入响应。
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 以 {"error": "..."} 的 JSON 格式写入错误响应。
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// errNoCronSecret 表示未设置 CRON_SECRET。
+var errNoCronSecret = fmt.Errorf("未设置 CRON_SECRET，拒绝执行：部署在公网上的端点可以触发会删除文件的全量同步，必须设置访问令牌")
+
+// authorize 检查请求是否携带了正确的凭据，失败时返回应使用的状态码和错误。
+// 请求头必须携带 CRON_SECRET（Vercel Cron 会以 Bearer Token 的形式自动携带它）。
+// 与 Web UI 不同，Serverless 端点总是暴露在公网上，因此未设置 CRON_SECRET 时拒绝所有请求。
+func authorize(r *http.Request, cfg *config.Config) (int, error) {
+	if cfg.CronSecret == "" {
+		return http.StatusServiceUnavailable, errNoCronSecret
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.CronSecret)) != 1 {
+		return http.StatusUnauthorized, fmt.Errorf("未授权")
+	}
+	return 0, nil
+}
@@ -0,0 +1,79 @@
+package serverless
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/kv"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// kvKeyPrefix 是缓存键在 Vercel KV 中的前缀。
+const kvKeyPrefix = "nodeimage-sync:webdav-listing:"
+
+// kvCache 将 WebDAV 文件列表保存在 Vercel KV 中，实现了 sync.ListingCache 接口。
+// Serverless 函数实例随时可能被回收，进程内缓存几乎无法命中；
+// 借助 KV，多次调用之间可以共享文件列表，跳过耗时的完整 PROPFIND。
+// KV 出错时只记录日志并视为未命中，不影响同步本身。
+type kvCache struct {
+	client *kv.Client
+	ttl    time.Duration
+	log    logger.Logger
+}
+
+// useKVCache 在配置了 Vercel KV 时，让本次同步使用 KV 缓存；否则保持默认的进程内缓存。
+func useKVCache(syncConfig *sync_lib.Config, appConfig *config.Config, log logger.Logger) {
+	if appConfig.KVRestAPIURL == "" || appConfig.KVRestAPIToken == "" {
+		return
+	}
+	syncConfig.Cache = &kvCache{
+		client: kv.NewClient(appConfig.KVRestAPIURL, appConfig.KVRestAPIToken, httpClient),
+		ttl:    time.Duration(appConfig.WebdavCacheTTL) * time.Minute,
+		log:    log,
+	}
+}
+
+func (c *kvCache) Load(ctx context.Context, key string) ([]webdav.FileInfo, bool) {
+	data, ok, err := c.client.Get(ctx, c.key(key))
+	if err != nil {
+		c.log.Warn("  -> ⚠️ 读取 KV 缓存失败: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	var files []webdav.FileInfo
+	if err := json.Unmarshal(data, &files); err != nil {
+		c.log.Warn("  -> ⚠️ KV 缓存已损坏，将重新扫描: %v", err)
+		return nil, false
+	}
+	return files, true
+}
+
+func (c *kvCache) Store(ctx context.Context, key string, files []webdav.FileInfo) {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(ctx, c.key(key), data, c.ttl); err != nil {
+		c.log.Warn("  -> ⚠️ 写入 KV 缓存失败: %v", err)
+	}
+}
+
+func (c *kvCache) Invalidate(ctx context.Context, key string) {
+	if err := c.client.Del(ctx, c.key(key)); err != nil {
+		c.log.Warn("  -> ⚠️ 清除 KV 缓存失败: %v", err)
+	}
+}
+
+// key 对同步目标做哈希，避免在 KV 中暴露 WebDAV 地址和用户名。
+func (c *kvCache) key(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return kvKeyPrefix + hex.EncodeToString(sum[:])
+}
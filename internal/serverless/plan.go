@@ -0,0 +1,95 @@
+package serverless
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// maxPlanBodySize 是 /api/execute 请求体的大小上限。
+const maxPlanBodySize = 16 << 20
+
+// HandlePlan 扫描两侧文件并以 JSON 返回同步计划（需要上传和删除的文件），不执行任何写操作。
+// 查询参数 mode 和 base-path 与 /api/sync 相同。返回的计划可以整体或拆分后提交给 /api/execute 执行。
+func HandlePlan(w http.ResponseWriter, r *http.Request) {
+	appConfig := config.LoadConfig()
+	if status, err := authorize(r, appConfig); err != nil {
+		writeError(w, status, err)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("只允许 GET 或 POST 方法"))
+		return
+	}
+
+	isFullSync, err := parseMode(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	syncConfig, err := requestConfig(r, appConfig)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	log := logger.New(logger.StringToLogLevel(appConfig.LogLevel), os.Stdout)
+	useKVCache(&syncConfig, appConfig, log)
+	plan, err := sync_lib.BuildPlan(r.Context(), log, syncConfig, isFullSync, httpClient)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// HandleExecute 执行请求体中给出的一批文件操作，并以与 /api/sync?format=json 相同的汇总 JSON 返回执行结果，失败时状态码为 500。
+// 请求体的格式与 /api/plan 的响应相同，只需包含 uploads 和/或 deletes 字段。
+// 为安全起见，删除路径必须位于同步目录的直接子级，上传文件名不能包含路径分隔符。
+// 支持与 /api/sync 相同的 concurrency、base-path 和 dry-run 参数。
+func HandleExecute(w http.ResponseWriter, r *http.Request) {
+	appConfig := config.LoadConfig()
+	if status, err := authorize(r, appConfig); err != nil {
+		writeError(w, status, err)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("只允许 POST 方法"))
+		return
+	}
+
+	syncConfig, err := requestConfig(r, appConfig)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var plan sync_lib.Plan
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPlanBodySize)).Decode(&plan); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("无效的请求体: %w", err))
+		return
+	}
+	if err := plan.Validate(syncConfig.WebdavBasePath); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	log := logger.New(logger.StringToLogLevel(appConfig.LogLevel), os.Stdout)
+	useKVCache(&syncConfig, appConfig, log)
+	result, ran := runner.TryDo(log, func() sync_lib.Result {
+		return sync_lib.ExecutePlan(r.Context(), log, syncConfig, &plan, httpClient)
+	})
+	if !ran {
+		writeError(w, http.StatusConflict, fmt.Errorf("同步任务已在运行中，本次请求被跳过"))
+		return
+	}
+	status := http.StatusOK
+	if !result.Success {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, newSummary(result))
+}
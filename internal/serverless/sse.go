@@ -0,0 +1,136 @@
+package serverless
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// heartbeatInterval 是发送心跳的间隔。
+// 扫描大目录（PROPFIND）时可能长时间没有日志输出，部分代理会因此断开空闲连接。
+const heartbeatInterval = 15 * time.Second
+
+// sseWriter 实现了 logger.Logger 接口，将日志以 Server-Sent Events 的形式实时写入 HTTP 响应。
+// Serverless 环境无法使用 WebSocket，SSE 是向调用方流式输出进度的最简单方式。
+//
+// 所有事件都是命名事件，数据为 JSON：
+//   - log：普通日志，{"time", "level", "message"}
+//   - error：ERROR 级别的日志，格式同 log
+//   - progress：执行进度，格式见 sync.Progress
+//   - result：最终结果，格式见 summary，总是最后一个事件
+//   - heartbeat：心跳，数据为 Unix 时间戳
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	level   logger.LogLevel
+	mutex   sync.Mutex // 同步任务会并发写日志，需要串行化对响应的写入
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newSSEWriter 设置 SSE 响应头并返回一个 sseWriter，同时开始定期发送心跳。
+// 如果底层 ResponseWriter 不支持 Flush，则返回错误。调用方必须在处理结束前调用 Close。
+func newSSEWriter(w http.ResponseWriter, level logger.LogLevel) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("当前环境不支持流式响应")
+	}
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &sseWriter{w: w, flusher: flusher, level: level, done: make(chan struct{})}
+	s.wg.Add(1)
+	go s.heartbeat()
+	return s, nil
+}
+
+// Close 停止心跳。它会等待心跳协程退出，保证返回后不再写入响应。
+func (s *sseWriter) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// heartbeat 定期写入一条 SSE 注释和一个名为 heartbeat 的事件。
+// 注释会被 EventSource 忽略，只用于保持连接活跃；heartbeat 事件则可供调用方检测连接是否存活。
+func (s *sseWriter) heartbeat() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			fmt.Fprintf(s.w, ": keepalive\n\nevent: heartbeat\ndata: %d\n\n", time.Now().Unix())
+			s.flusher.Flush()
+			s.mutex.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// send 写入一个命名的 SSE 事件，事件数据为 v 的 JSON 编码。
+func (s *sseWriter) send(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"message": err.Error()})
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+// logEvent 是 log 和 error 事件的数据格式。
+type logEvent struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// log 将日志作为 log 事件发送；ERROR 级别的日志作为 error 事件发送，便于调用方单独处理。
+func (s *sseWriter) log(level logger.LogLevel, levelStr string, format string, v ...interface{}) {
+	if s.level > level {
+		return
+	}
+	event := "log"
+	if level == logger.ERROR {
+		event = "error"
+	}
+	s.send(event, logEvent{Time: time.Now(), Level: levelStr, Message: fmt.Sprintf(format, v...)})
+}
+
+// progress 将执行进度作为 progress 事件发送，可直接用作 sync.Config 的 OnProgress 回调。
+func (s *sseWriter) progress(p sync_lib.Progress) {
+	s.send("progress", p)
+}
+
+// result 发送最终的 result 事件。每个 SSE 响应都以它结束。
+func (s *sseWriter) result(sum summary) {
+	s.send("result", sum)
+}
+
+func (s *sseWriter) Debug(format string, v ...interface{}) {
+	s.log(logger.DEBUG, "DEBUG", format, v...)
+}
+
+func (s *sseWriter) Info(format string, v ...interface{}) {
+	s.log(logger.INFO, "INFO", format, v...)
+}
+
+func (s *sseWriter) Warn(format string, v ...interface{}) {
+	s.log(logger.WARN, "WARN", format, v...)
+}
+
+func (s *sseWriter) Error(format string, v ...interface{}) {
+	s.log(logger.ERROR, "ERROR", format, v...)
+}
@@ -0,0 +1,188 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// stateDirName 是同步目录下用于保存同步状态（如分批同步的游标）的隐藏目录。
+// 文件列表只包含同步目录的直接子文件，因此该目录不会参与差异对比。
+const stateDirName = ".nodeimage-sync"
+
+// checkpointTTL 是分批同步游标的有效期。
+// 过期的游标会被丢弃并重新生成计划，避免依据过时的差异执行删除。
+const checkpointTTL = time.Hour
+
+// checkpoint 是保存在 WebDAV 上的分批同步游标，记录尚未执行的操作。
+type checkpoint struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Plan      Plan      `json:"plan"`
+}
+
+// RunBatch 分批执行同步，每次调用最多处理 batchSize 个上传或删除操作。
+// 首次调用会生成完整计划，未处理的部分作为游标保存到 WebDAV；后续调用直接从游标继续，
+// 无需重新扫描两侧文件列表。这使得同步可以拆分到多次受时间限制的 Serverless 调用中完成。
+// 返回结果的 Remaining 字段表示尚未处理的操作数，大于 0 时调用方应再次调用。
+func RunBatch(ctx context.Context, log logger.Logger, config Config, isFullSync bool, batchSize int, httpClient *http.Client) Result {
+	// 演练模式不会改变任何文件，也就无需读写游标
+	if config.DryRun {
+		return RunSync(ctx, log, config, isFullSync, httpClient)
+	}
+	if batchSize <= 0 {
+		result := RunSync(ctx, log, config, isFullSync, httpClient)
+		if result.Success {
+			// 一次完整的同步已覆盖之前未完成的分批操作，旧游标不再有效
+			clearCheckpoint(ctx, log, config, isFullSync, httpClient)
+		}
+		return result
+	}
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		err := fmt.Errorf("WebDAV 配置未完全设置")
+		log.Error("  -> ❌ 配置验证失败: %v", err)
+		return Result{Success: false, Message: err.Error(), Error: err}
+	}
+
+	config = config.withDefaults()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+	checkpointPath := checkpointPath(config.WebdavBasePath, isFullSync)
+
+	var plan *Plan
+	if cp := loadCheckpoint(ctx, log, webdavClient, checkpointPath); cp != nil && cp.Plan.IsFullSync == isFullSync {
+		plan = &cp.Plan
+		log.Info("<-----继续分批同步 (游标创建于 %s)----->", cp.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		log.Info("  -> [游标] 剩余上传: %d 张, 剩余删除: %d 张", len(plan.Uploads), len(plan.Deletes)+len(plan.Purges))
+	} else {
+		var err error
+		plan, err = BuildPlan(ctx, log, config, isFullSync, httpClient)
+		if err != nil {
+			return Result{Success: false, Message: err.Error(), Error: err}
+		}
+	}
+
+	batch, rest := splitPlan(plan, batchSize)
+	if !rest.Empty() {
+		log.Info("  -> [分批] 本次处理 %d 个操作，剩余 %d 个", batch.Len(), rest.Len())
+	}
+
+	result := ExecutePlan(ctx, log, config, batch, httpClient)
+	result.Remaining = rest.Len()
+
+	if rest.Empty() {
+		clearCheckpoint(ctx, log, config, isFullSync, httpClient)
+		return result
+	}
+	if err := saveCheckpoint(ctx, webdavClient, config.WebdavBasePath, checkpointPath, rest); err != nil {
+		// 游标保存失败不影响本批结果，下一次调用会重新生成计划
+		log.Warn("  -> ⚠️ 保存分批同步游标失败: %v", err)
+	}
+	return result
+}
+
+// splitPlan 将计划拆分为最多包含 size 个操作的本批计划和剩余计划。
+// 上传优先于删除，删除优先于旧版本清理。
+func splitPlan(plan *Plan, size int) (batch, rest *Plan) {
+	batch = &Plan{
+		IsFullSync:          plan.IsFullSync,
+		TotalNodeImageFiles: plan.TotalNodeImageFiles,
+		TotalNodeImageSize:  plan.TotalNodeImageSize,
+		TotalWebDAVFiles:    plan.TotalWebDAVFiles,
+		TotalWebDAVSize:     plan.TotalWebDAVSize,
+		startTime:           plan.startTime,
+	}
+	rest = &Plan{
+		IsFullSync:          plan.IsFullSync,
+		TotalNodeImageFiles: plan.TotalNodeImageFiles,
+		TotalNodeImageSize:  plan.TotalNodeImageSize,
+		TotalWebDAVFiles:    plan.TotalWebDAVFiles,
+		TotalWebDAVSize:     plan.TotalWebDAVSize,
+	}
+
+	// 重命名不需要传输图片数据，最先执行
+	renames := min(size, len(plan.Renames))
+	batch.Renames, rest.Renames = plan.Renames[:renames], plan.Renames[renames:]
+	uploads := min(size-renames, len(plan.Uploads))
+	batch.Uploads, rest.Uploads = plan.Uploads[:uploads], plan.Uploads[uploads:]
+	deletes := min(size-renames-uploads, len(plan.Deletes))
+	batch.Deletes, rest.Deletes = plan.Deletes[:deletes], plan.Deletes[deletes:]
+	purges := min(size-renames-uploads-deletes, len(plan.Purges))
+	batch.Purges, rest.Purges = plan.Purges[:purges], plan.Purges[purges:]
+
+	// 图片 ID 记录的变更随第一批写入，之后各批只追加本批上传、删除和重命名的文件
+	batch.IDs = plan.IDs
+
+	// 快照清单必须在最后一批完成后才写入
+	if rest.Empty() {
+		batch.Snapshot = plan.Snapshot
+	} else {
+		rest.Snapshot = plan.Snapshot
+	}
+
+	for _, file := range batch.Uploads {
+		batch.UploadSize += file.Size
+	}
+	for _, file := range rest.Uploads {
+		rest.UploadSize += file.Size
+	}
+	return batch, rest
+}
+
+// checkpointPath 返回指定模式下游标文件在 WebDAV 上的路径。
+func checkpointPath(basePath string, isFullSync bool) string {
+	mode := "incremental"
+	if isFullSync {
+		mode = "full"
+	}
+	return path.Join(basePath, stateDirName, "checkpoint-"+mode+".json")
+}
+
+// loadCheckpoint 读取游标。游标不存在、无法解析或已过期时返回 nil。
+func loadCheckpoint(ctx context.Context, log logger.Logger, client *webdav.Client, p string) *checkpoint {
+	data, err := client.ReadFile(ctx, p)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn("  -> ⚠️ 读取分批同步游标失败，将重新生成计划: %v", err)
+		}
+		return nil
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		log.Warn("  -> ⚠️ 分批同步游标已损坏，将重新生成计划: %v", err)
+		return nil
+	}
+	if time.Since(cp.CreatedAt) > checkpointTTL {
+		log.Info("  -> 分批同步游标已过期，将重新生成计划")
+		return nil
+	}
+	return &cp
+}
+
+// clearCheckpoint 删除指定模式下可能残留的游标。游标不存在时静默忽略。
+func clearCheckpoint(ctx context.Context, log logger.Logger, config Config, isFullSync bool, httpClient *http.Client) {
+	config = config.withDefaults()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+	if err := webdavClient.DeleteFile(ctx, checkpointPath(config.WebdavBasePath, isFullSync)); err != nil {
+		log.Debug("清理分批同步游标: %v", err)
+	}
+}
+
+// saveCheckpoint 将剩余计划作为游标写入 WebDAV。
+func saveCheckpoint(ctx context.Context, client *webdav.Client, basePath, p string, rest *Plan) error {
+	data, err := json.Marshal(checkpoint{CreatedAt: time.Now(), Plan: *rest})
+	if err != nil {
+		return fmt.Errorf("序列化游标失败: %w", err)
+	}
+	if err := client.MakeDir(ctx, path.Join(basePath, stateDirName)); err != nil {
+		return err
+	}
+	return client.UploadFile(ctx, p, data)
+}
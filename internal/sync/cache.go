@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// ListingCache 缓存 WebDAV 同步目录的文件列表，避免每次增量同步都执行耗时的 PROPFIND。
+// key 唯一标识一个同步目标（服务器、用户和目录）。实现必须是并发安全的。
+type ListingCache interface {
+	// Load 返回缓存的文件列表。没有缓存（或已过期）时返回 false。
+	Load(ctx context.Context, key string) ([]webdav.FileInfo, bool)
+	// Store 保存文件列表。
+	Store(ctx context.Context, key string, files []webdav.FileInfo)
+	// Invalidate 在文件系统发生变化（上传或删除）后清除缓存。
+	Invalidate(ctx context.Context, key string)
+}
+
+// memoryCache 是默认的进程内缓存，按同步目标分别保存文件列表。
+type memoryCache struct {
+	mutex   sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry 是一个同步目标的文件列表及其生成时间。
+type cacheEntry struct {
+	Files       []webdav.FileInfo `json:"files"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+// defaultCache 是未指定 Config.Cache 时使用的进程内缓存。
+var defaultCache = &memoryCache{entries: make(map[string]cacheEntry)}
+
+func (c *memoryCache) Load(_ context.Context, key string) ([]webdav.FileInfo, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.entries[key]
+	return entry.Files, ok
+}
+
+func (c *memoryCache) Store(_ context.Context, key string, files []webdav.FileInfo) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = cacheEntry{Files: files, GeneratedAt: time.Now()}
+}
+
+func (c *memoryCache) Invalidate(_ context.Context, key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key)
+}
+
+// InvalidateWebdavCache 清空默认进程内缓存中所有同步目标的文件列表。
+func InvalidateWebdavCache() {
+	defaultCache.mutex.Lock()
+	defer defaultCache.mutex.Unlock()
+	defaultCache.entries = make(map[string]cacheEntry)
+}
+
+// listingCache 返回本次同步使用的缓存。
+func (c Config) listingCache() ListingCache {
+	if c.Cache != nil {
+		return c.Cache
+	}
+	return defaultCache
+}
+
+// cacheKey 返回标识同步目标的缓存键。
+func (c Config) cacheKey() string {
+	return c.WebdavURL + "|" + c.WebdavUsername + "|" + path.Clean("/"+c.WebdavBasePath)
+}
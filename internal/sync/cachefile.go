@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheFileVersion 是缓存文件格式的版本，格式不兼容时忽略旧文件。
+const cacheFileVersion = 1
+
+// cacheFile 是默认进程内缓存在磁盘上的格式。
+type cacheFile struct {
+	Version int                   `json:"version"`
+	SavedAt time.Time             `json:"savedAt"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// SaveWebdavCache 将默认进程内缓存写入 file，返回写入的同步目标数。
+// 在进程退出前调用，使重新部署后的第一次增量同步无需重新执行耗时的 PROPFIND。
+func SaveWebdavCache(file string) (int, error) {
+	defaultCache.mutex.RLock()
+	data, err := json.Marshal(cacheFile{Version: cacheFileVersion, SavedAt: time.Now(), Entries: defaultCache.entries})
+	count := len(defaultCache.entries)
+	defaultCache.mutex.RUnlock()
+	if err != nil {
+		return 0, fmt.Errorf("序列化 WebDAV 缓存失败: %w", err)
+	}
+	if err := writeFileAtomic(file, data); err != nil {
+		return 0, fmt.Errorf("保存 WebDAV 缓存失败: %w", err)
+	}
+	return count, nil
+}
+
+// writeFileAtomic 先将 data 写入同一目录下的临时文件，再重命名为 file，使读取方不会读到写了一半的文件。
+// 文件权限为 0600。
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// LoadWebdavCache 从 file 恢复默认进程内缓存，返回恢复的同步目标数。
+// 生成时间早于 maxAge 的列表会被丢弃，因为进程停止期间 WebDAV 上的文件可能已被其他方式修改；
+// maxAge 为 0 表示不限制。文件不存在时不做任何事。
+func LoadWebdavCache(file string, maxAge time.Duration) (int, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取 WebDAV 缓存失败: %w", err)
+	}
+	var saved cacheFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, fmt.Errorf("解析 WebDAV 缓存失败: %w", err)
+	}
+	if saved.Version != cacheFileVersion {
+		return 0, nil
+	}
+
+	defaultCache.mutex.Lock()
+	defer defaultCache.mutex.Unlock()
+	count := 0
+	for key, entry := range saved.Entries {
+		if maxAge > 0 && time.Since(entry.GeneratedAt) > maxAge {
+			continue
+		}
+		// 不覆盖本进程已经获取的更新的列表
+		if current, ok := defaultCache.entries[key]; ok && current.GeneratedAt.After(entry.GeneratedAt) {
+			continue
+		}
+		defaultCache.entries[key] = entry
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+)
+
+// disambiguate 为 NodeImage 上文件名相同的不同图片生成互不冲突的文件名，否则对比时它们会被当作同一个文件，
+// 只有其中一张被保存。同名的每一张图片都在扩展名前加上 "-<图片 ID>"（例如 a.png -> a-abc123.png），
+// 使保存的文件名不依赖于列表的顺序；文件名不冲突的图片保持不变。
+// 文件名按 normalize 规范化后再判断是否相同。同一张图片（ID 相同）在列表中重复出现时只保留一次。
+// 返回新的列表，不修改 files。
+//
+// 增量同步只能看到最近的图片，与较早的图片同名时无法察觉，冲突会在下一次全量同步时被处理。
+func disambiguate(log logger.Logger, files []nodeimage.ImageInfo, normalize func(string) string) []nodeimage.ImageInfo {
+	seen := make(map[string]bool, len(files))
+	names := make(map[string]int, len(files))
+	result := make([]nodeimage.ImageInfo, 0, len(files))
+	for _, file := range files {
+		if file.ID != "" {
+			if seen[file.ID] {
+				continue
+			}
+			seen[file.ID] = true
+		}
+		names[normalized(normalize, file.Filename)]++
+		result = append(result, file)
+	}
+
+	collided := 0
+	for i, file := range result {
+		if names[normalized(normalize, file.Filename)] < 2 {
+			continue
+		}
+		collided++
+		ext := path.Ext(file.Filename)
+		stem := strings.TrimSuffix(file.Filename, ext)
+		suffix := file.ID
+		if suffix == "" {
+			suffix = fmt.Sprint(i + 1)
+		}
+		name := fmt.Sprintf("%s-%s%s", stem, suffix, ext)
+		// 极少数情况下生成的文件名恰好是另一张图片的文件名
+		for n := 2; names[normalized(normalize, name)] > 0; n++ {
+			name = fmt.Sprintf("%s-%s-%d%s", stem, suffix, n, ext)
+		}
+		names[normalized(normalize, name)]++
+		log.Debug("  -> [NodeImage] 文件名重复，保存为: %s -> %s", file.Filename, name)
+		result[i].Filename = name
+	}
+	if collided > 0 {
+		log.Warn("  -> ⚠️ [NodeImage] %d 张图片的文件名与其他图片重复，已在文件名后加上图片 ID 分别保存", collided)
+	}
+	return result
+}
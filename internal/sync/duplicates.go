@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// DuplicateFile 是重复文件组中的一个 WebDAV 文件。
+type DuplicateFile struct {
+	Path     string   `json:"path"`
+	ImageIDs []string `json:"imageIds,omitempty"` // 对应的 NodeImage 图片 ID（按文件名匹配）
+}
+
+// DuplicateGroup 是一组内容完全相同的文件。
+type DuplicateGroup struct {
+	Hash        string          `json:"hash"` // 文件内容的 SHA-256
+	Size        int64           `json:"size"`
+	Files       []DuplicateFile `json:"files"`
+	WastedBytes int64           `json:"wastedBytes"` // 只保留一份时可释放的空间
+}
+
+// DuplicateReport 是重复文件报告。
+type DuplicateReport struct {
+	ScannedFiles   int              `json:"scannedFiles"`
+	HashedFiles    int              `json:"hashedFiles"`
+	DuplicateFiles int              `json:"duplicateFiles"` // 多余的副本数（每组不计第一份）
+	WastedBytes    int64            `json:"wastedBytes"`
+	Groups         []DuplicateGroup `json:"groups"`
+}
+
+// FindDuplicates 扫描 WebDAV 同步目录，找出内容相同的文件。
+// 只有大小相同的文件才会被下载并计算哈希，因此即使目录很大，开销也主要取决于大小冲突的文件数。
+// 如果配置了 NodeImage 凭据，报告中还会标注每个文件对应的 NodeImage 图片 ID，
+// 以便找出在 NodeImage 上重复上传的图片。
+func FindDuplicates(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client) (*DuplicateReport, error) {
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		return nil, fmt.Errorf("WebDAV 配置未完全设置")
+	}
+	config = config.withDefaults()
+	stats := stats.New()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats, log, httpClient)
+
+	infos, err := webdavClient.ListFilesWithStats(ctx, config.WebdavBasePath)
+	if err != nil {
+		return nil, fmt.Errorf("获取 WebDAV 文件列表失败: %w", err)
+	}
+	log.Info("  -> [WebDAV] 发现 %d 个文件", len(infos))
+
+	// 步骤 1: 按大小分组，只有大小相同的文件才可能重复
+	bySize := make(map[int64][]string)
+	for _, info := range infos {
+		bySize[info.Size] = append(bySize[info.Size], info.Path)
+	}
+	var candidates []string
+	for _, paths := range bySize {
+		if len(paths) > 1 {
+			candidates = append(candidates, paths...)
+		}
+	}
+	log.Info("  -> 大小相同的候选文件: %d 个，正在计算哈希...", len(candidates))
+
+	// 步骤 2: 并发下载候选文件并计算哈希
+	hashes := make(map[string]string, len(candidates))
+	var mutex sync.Mutex
+	readFiles(ctx, log, config, webdavClient, candidates, func(p string, data []byte, err error) {
+		if err != nil {
+			log.Warn("  -> ⚠️ 无法读取 %s，已跳过: %v", p, err)
+			return
+		}
+		sum := sha256.Sum256(data)
+		mutex.Lock()
+		hashes[p] = hex.EncodeToString(sum[:])
+		mutex.Unlock()
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	imageIDs := nodeImageIDsByFilename(ctx, log, config, httpClient, stats)
+
+	// 步骤 3: 按大小和哈希分组
+	sizes := make(map[string]int64, len(infos))
+	for _, info := range infos {
+		sizes[info.Path] = info.Size
+	}
+	byHash := make(map[string][]string)
+	for p, hash := range hashes {
+		byHash[hash] = append(byHash[hash], p)
+	}
+
+	report := &DuplicateReport{ScannedFiles: len(infos), HashedFiles: len(hashes)}
+	for hash, paths := range byHash {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		group := DuplicateGroup{Hash: hash, Size: sizes[paths[0]]}
+		for _, p := range paths {
+			group.Files = append(group.Files, DuplicateFile{Path: p, ImageIDs: imageIDs[path.Base(p)]})
+		}
+		group.WastedBytes = group.Size * int64(len(paths)-1)
+		report.Groups = append(report.Groups, group)
+		report.DuplicateFiles += len(paths) - 1
+		report.WastedBytes += group.WastedBytes
+	}
+	// 浪费空间最多的组排在前面
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].WastedBytes != report.Groups[j].WastedBytes {
+			return report.Groups[i].WastedBytes > report.Groups[j].WastedBytes
+		}
+		return report.Groups[i].Hash < report.Groups[j].Hash
+	})
+
+	log.Info("  -> ✅ 发现 %d 组重复文件，共 %d 个多余副本，浪费 %s", len(report.Groups), report.DuplicateFiles, formatBytes(report.WastedBytes))
+	return report, nil
+}
+
+// nodeImageIDsByFilename 获取 NodeImage 的图片列表，并按文件名索引图片 ID。
+// 未配置凭据或获取失败时返回 nil，报告中将不包含图片 ID。
+func nodeImageIDsByFilename(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client, stats *stats.Stats) map[string][]string {
+	files, err := listNodeImageFiles(ctx, log, config, httpClient, stats)
+	if err != nil {
+		log.Warn("  -> ⚠️ 获取 NodeImage 文件列表失败，报告中将不包含图片 ID: %v", err)
+		return nil
+	}
+
+	ids := make(map[string][]string, len(files))
+	for _, f := range files {
+		ids[f.Filename] = append(ids[f.Filename], f.ID)
+	}
+	return ids
+}
+
+// listNodeImageFiles 获取 NodeImage 的图片列表，重复的文件名按同步时的规则改为保存时使用的文件名。
+// 优先使用 Cookie 获取完整列表，否则使用 API Key；两者都未配置时返回 nil。
+func listNodeImageFiles(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client, stats *stats.Stats) ([]nodeimage.ImageInfo, error) {
+	client := nodeimage.NewClient(config.NodeImageCookie, config.NodeImageAPIURL, log, stats, httpClient)
+	var files []nodeimage.ImageInfo
+	var err error
+	switch {
+	case config.NodeImageCookie != "":
+		files, err = client.GetImageListCookie(ctx)
+	case config.NodeImageAPIKey != "":
+		files, err = client.GetImageListAPIKey(ctx, config.NodeImageAPIKey)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return disambiguate(log, files, config.normalizer()), nil
+}
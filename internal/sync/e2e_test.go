@@ -0,0 +1,529 @@
+// 本文件在本地启动模拟的 NodeImage 和 WebDAV 服务器（见 fake_nodeimage_test.go 和 fake_webdav_test.go），
+// 通过 RunSync 运行同步引擎的端到端场景，无需真实凭据即可验证全量、增量、失败重试、分页等核心行为。
+
+package sync_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// basePath 是场景中使用的 WebDAV 同步目录。
+const basePath = "/backup"
+
+// testEnv 是一个场景的运行环境：一对新的模拟服务器，以及指向它们的同步配置。
+type testEnv struct {
+	NodeImage  *fakeNodeImage
+	WebDAV     *fakeWebDAV
+	Config     sync_lib.Config
+	HTTPClient *http.Client
+	Log        logger.Logger
+}
+
+// newTestEnv 启动包含 n 张图片的模拟 NodeImage 和空的模拟 WebDAV。调用方负责调用 Close。
+func newTestEnv(n int, log logger.Logger) *testEnv {
+	ni, dav := newFakeNodeImage(n), newFakeWebDAV()
+	return &testEnv{
+		NodeImage:  ni,
+		WebDAV:     dav,
+		HTTPClient: &http.Client{Transport: fakeTransport{NodeImage: ni}, Timeout: 10 * time.Second},
+		Log:        log,
+		Config: sync_lib.Config{
+			NodeImageCookie: testCookie,
+			NodeImageAPIKey: testAPIKey,
+			NodeImageAPIURL: ni.APIURL(),
+			WebdavURL:       dav.URL(),
+			WebdavUsername:  testUsername,
+			WebdavPassword:  testPassword,
+			WebdavBasePath:  basePath,
+			SyncConcurrency: 3,
+			SyncRetries:     0,
+			VerifyUploads:   true,
+		},
+	}
+}
+
+// Close 关闭模拟服务器。
+func (e *testEnv) Close() {
+	e.NodeImage.Close()
+	e.WebDAV.Close()
+}
+
+// Sync 执行一次同步。
+func (e *testEnv) Sync(isFullSync bool) sync_lib.Result {
+	return sync_lib.RunSync(context.Background(), e.Log, e.Config, isFullSync, e.HTTPClient)
+}
+
+// CheckMirrored 确认 WebDAV 同步目录中的图片与 NodeImage 完全一致（忽略保留的旧版本和状态目录）。
+func (e *testEnv) CheckMirrored() error {
+	files := e.WebDAV.Files(basePath)
+	images := e.NodeImage.Images()
+	count := 0
+	for name := range files {
+		if !strings.HasSuffix(name, ".deleted") {
+			count++
+		}
+	}
+	if count != len(images) {
+		return fmt.Errorf("WebDAV 上有 %d 个文件，NodeImage 上有 %d 张图片", count, len(images))
+	}
+	for _, img := range images {
+		data, ok := files[img.Name]
+		if !ok {
+			return fmt.Errorf("WebDAV 上缺少 %s", img.Name)
+		}
+		if !bytes.Equal(data, img.Data) {
+			return fmt.Errorf("%s 的内容不一致", img.Name)
+		}
+	}
+	return nil
+}
+
+// TestE2E 在各自独立的 testEnv 中运行每个场景。
+func TestE2E(t *testing.T) {
+	scenarios := []struct {
+		name string
+		run  func(e *testEnv) error
+	}{
+		{"增量同步上传全部缺失的图片", incrementalUploads},
+		{"增量同步不删除多余的文件", incrementalKeepsExtra},
+		{"全量同步上传缺失并删除多余的文件", fullMirrors},
+		{"旧版本模式保留被删除的文件", fullKeepsVersions},
+		{"重复同步不产生任何操作", secondRunIsNoop},
+		{"失败的上传在重试后成功", retryRecovers},
+		{"重试耗尽后同步失败并报告失败数", retryExhausted},
+		{"NodeImage 列表接口出错时同步失败且不写入", listFailure},
+		{"WebDAV 目录列表分页", webdavPagination},
+		{"演练模式不执行任何写操作", dryRunWritesNothing},
+		{"空间不足时中止同步", quotaAborts},
+		{"按 ID 对应时改名的图片通过 MOVE 重命名", renameMovedByID},
+		{"快照模式下改名的图片另存一份", renameCopiedInSnapshots},
+		{"按 ID 对应时原文件名被新图片占用", renamedNameReused},
+		{"同名的不同图片分别保存", collidingNamesKept},
+		{"WebDAV 返回 NFD 形式的文件名时不重复上传", nfdNamesMatched},
+		{"全量同步不删除临时文件和隐藏文件", fullKeepsTemporary},
+		{"限定删除范围时只删除本工具管理的文件", managedDeletesOnly},
+		{"本地索引代替增量同步的目录列表并筛选校验", localIndex},
+		{"上传失败的文件进入重试队列并在之后的增量同步中优先重试", retryQueue},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			env := newTestEnv(5, logger.New(logger.DEBUG, testWriter{t}))
+			defer env.Close()
+			if err := s.run(env); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// testWriter 将同步日志写入测试日志，只在测试失败或使用 -v 时输出。
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// expect 检查同步结果的成功状态和上传、删除、失败数。
+func expect(r sync_lib.Result, success bool, uploaded, deleted, failed int) error {
+	if r.Success != success || r.Uploaded != uploaded || r.Deleted != deleted || r.Failed != failed {
+		return fmt.Errorf("期望 成功=%v 上传=%d 删除=%d 失败=%d，实际 成功=%v 上传=%d 删除=%d 失败=%d (%s)",
+			success, uploaded, deleted, failed, r.Success, r.Uploaded, r.Deleted, r.Failed, r.Message)
+	}
+	return nil
+}
+
+func incrementalUploads(e *testEnv) error {
+	if err := expect(e.Sync(false), true, 5, 0, 0); err != nil {
+		return err
+	}
+	return e.CheckMirrored()
+}
+
+func incrementalKeepsExtra(e *testEnv) error {
+	e.WebDAV.Put(path.Join(basePath, "extra.png"), []byte("extra"))
+	if err := expect(e.Sync(false), true, 5, 0, 0); err != nil {
+		return err
+	}
+	if _, ok := e.WebDAV.Files(basePath)["extra.png"]; !ok {
+		return fmt.Errorf("增量同步删除了 extra.png")
+	}
+	return nil
+}
+
+func fullMirrors(e *testEnv) error {
+	images := e.NodeImage.Images()
+	e.WebDAV.Put(path.Join(basePath, images[0].Name), images[0].Data)
+	e.WebDAV.Put(path.Join(basePath, "extra1.png"), []byte("extra"))
+	e.WebDAV.Put(path.Join(basePath, "extra2.png"), []byte("extra"))
+	if err := expect(e.Sync(true), true, 4, 2, 0); err != nil {
+		return err
+	}
+	return e.CheckMirrored()
+}
+
+func fullKeepsVersions(e *testEnv) error {
+	e.Config.DeleteMode = sync_lib.DeleteModeVersion
+	e.WebDAV.Put(path.Join(basePath, "old.png"), []byte("old"))
+	if err := expect(e.Sync(true), true, 5, 1, 0); err != nil {
+		return err
+	}
+	files := e.WebDAV.Files(basePath)
+	if _, ok := files["old.png"]; ok {
+		return fmt.Errorf("old.png 仍然存在")
+	}
+	for name, data := range files {
+		if strings.HasPrefix(name, "old.png.") && strings.HasSuffix(name, ".deleted") && string(data) == "old" {
+			return e.CheckMirrored()
+		}
+	}
+	return fmt.Errorf("没有找到 old.png 的旧版本")
+}
+
+func secondRunIsNoop(e *testEnv) error {
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	downloads, puts := e.NodeImage.Downloads(), e.WebDAV.Requests(http.MethodPut)
+	for _, full := range []bool{false, true} {
+		if err := expect(e.Sync(full), true, 0, 0, 0); err != nil {
+			return err
+		}
+	}
+	if e.NodeImage.Downloads() != downloads || e.WebDAV.Requests(http.MethodPut) != puts {
+		return fmt.Errorf("第二次同步仍然下载或上传了文件")
+	}
+	return nil
+}
+
+func retryRecovers(e *testEnv) error {
+	e.Config.SyncRetries = 2
+	images := e.NodeImage.Images()
+	e.NodeImage.FailDownload(images[1].Name, 1)
+	e.WebDAV.FailPut(images[2].Name, 2)
+	if err := expect(e.Sync(false), true, 5, 0, 0); err != nil {
+		return err
+	}
+	return e.CheckMirrored()
+}
+
+func retryExhausted(e *testEnv) error {
+	e.Config.SyncRetries = 1
+	images := e.NodeImage.Images()
+	e.WebDAV.FailPut(images[3].Name, 2)
+	r := e.Sync(false)
+	if err := expect(r, false, 4, 0, 1); err != nil {
+		return err
+	}
+	if _, ok := e.WebDAV.Files(basePath)[images[3].Name]; ok {
+		return fmt.Errorf("上传失败的 %s 出现在 WebDAV 上", images[3].Name)
+	}
+	// 下一次同步应补上失败的文件
+	if err := expect(e.Sync(false), true, 1, 0, 0); err != nil {
+		return err
+	}
+	return e.CheckMirrored()
+}
+
+func listFailure(e *testEnv) error {
+	e.NodeImage.FailList(http.StatusInternalServerError)
+	for _, full := range []bool{false, true} {
+		if r := e.Sync(full); r.Success {
+			return fmt.Errorf("列表接口出错时同步成功: %s", r.Message)
+		}
+	}
+	if n := e.WebDAV.Requests(http.MethodPut) + e.WebDAV.Requests(http.MethodDelete); n != 0 {
+		return fmt.Errorf("列表接口出错时仍向 WebDAV 写入了 %d 次", n)
+	}
+	return nil
+}
+
+func webdavPagination(e *testEnv) error {
+	e.WebDAV.SetPageSize(2)
+	for i := 0; i < 7; i++ {
+		e.WebDAV.Put(path.Join(basePath, fmt.Sprintf("extra%d.png", i)), []byte("extra"))
+	}
+	images := e.NodeImage.Images()
+	for _, img := range images[:3] {
+		e.WebDAV.Put(path.Join(basePath, img.Name), img.Data)
+	}
+	// 只有读到全部分页，才能恰好上传 2 个、删除 7 个
+	if err := expect(e.Sync(true), true, 2, 7, 0); err != nil {
+		return err
+	}
+	if err := e.CheckMirrored(); err != nil {
+		return err
+	}
+	return expect(e.Sync(true), true, 0, 0, 0)
+}
+
+func dryRunWritesNothing(e *testEnv) error {
+	e.Config.DryRun = true
+	e.WebDAV.Put(path.Join(basePath, "extra.png"), []byte("extra"))
+	r := e.Sync(true)
+	if !r.Success || r.Uploaded != 0 || r.Deleted != 0 {
+		return fmt.Errorf("演练模式的结果不正确: %s", r.Message)
+	}
+	if n := e.WebDAV.Requests(http.MethodPut) + e.WebDAV.Requests(http.MethodDelete) + e.WebDAV.Requests("MOVE"); n != 0 {
+		return fmt.Errorf("演练模式向 WebDAV 写入了 %d 次", n)
+	}
+	return nil
+}
+
+func quotaAborts(e *testEnv) error {
+	e.Config.QuotaAction = sync_lib.QuotaAbort
+	e.WebDAV.SetQuota(200)
+	r := e.Sync(false)
+	if r.Success {
+		return fmt.Errorf("空间不足时同步成功: %s", r.Message)
+	}
+	if n := e.WebDAV.Requests(http.MethodPut); n != 0 {
+		return fmt.Errorf("空间不足时仍上传了 %d 次", n)
+	}
+	return nil
+}
+
+func renameMovedByID(e *testEnv) error {
+	e.Config.MatchBy = sync_lib.MatchByID
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	images := e.NodeImage.Images()
+	images[0].Name = "renamed.png"
+	images[1].Name = "renamed2.png"
+	e.NodeImage.SetImages(images)
+	downloads := e.NodeImage.Downloads()
+	r := e.Sync(true)
+	if err := expect(r, true, 0, 0, 0); err != nil {
+		return err
+	}
+	if r.Renamed != 2 || e.WebDAV.Requests("MOVE") != 2 {
+		return fmt.Errorf("期望通过 MOVE 重命名 2 个文件，实际 %d 个 (%d 次 MOVE)", r.Renamed, e.WebDAV.Requests("MOVE"))
+	}
+	if e.NodeImage.Downloads() != downloads {
+		return fmt.Errorf("重命名时仍下载了图片")
+	}
+	if err := e.CheckMirrored(); err != nil {
+		return err
+	}
+	// 重命名后的文件已记录新的文件名，再次同步不产生任何操作
+	return expect(e.Sync(true), true, 0, 0, 0)
+}
+
+func renameCopiedInSnapshots(e *testEnv) error {
+	e.Config.MatchBy = sync_lib.MatchByID
+	e.Config.Snapshots = true
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	images := e.NodeImage.Images()
+	images[0].Name = "renamed.png"
+	e.NodeImage.SetImages(images)
+	if err := expect(e.Sync(true), true, 1, 0, 0); err != nil {
+		return err
+	}
+	files := e.WebDAV.Files(basePath)
+	if _, ok := files[newImage(0).Name]; !ok {
+		return fmt.Errorf("快照模式下改名的图片的原文件被移走")
+	}
+	if _, ok := files["renamed.png"]; !ok {
+		return fmt.Errorf("快照模式下没有以新文件名保存改名的图片")
+	}
+	if n := e.WebDAV.Requests("MOVE"); n != 0 {
+		return fmt.Errorf("快照模式下执行了 %d 次 MOVE", n)
+	}
+	return nil
+}
+
+func renamedNameReused(e *testEnv) error {
+	e.Config.MatchBy = sync_lib.MatchByID
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	// 图片 0 改名，新图片 5 使用了图片 0 原来的文件名：两者都必须以各自的内容保存
+	images := e.NodeImage.Images()
+	oldName := images[0].Name
+	images[0].Name = "renamed.png"
+	reuse := newImage(5)
+	reuse.Name = oldName
+	e.NodeImage.SetImages(append(images, reuse))
+	if err := expect(e.Sync(true), true, 2, 0, 0); err != nil {
+		return err
+	}
+	return e.CheckMirrored()
+}
+
+func collidingNamesKept(e *testEnv) error {
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	// 图片 1 改为与图片 0 同名：两者都以 "<文件名>-<图片 ID>" 保存，原来的文件被删除
+	images := e.NodeImage.Images()
+	images[1].Name = images[0].Name
+	e.NodeImage.SetImages(images)
+	if err := expect(e.Sync(true), true, 2, 2, 0); err != nil {
+		return err
+	}
+	files := e.WebDAV.Files(basePath)
+	for _, img := range images[:2] {
+		name := strings.TrimSuffix(img.Name, ".png") + "-" + img.ID + ".png"
+		if !bytes.Equal(files[name], img.Data) {
+			return fmt.Errorf("%s 没有以 %s 保存", img.ID, name)
+		}
+	}
+	if len(files) != len(images) {
+		return fmt.Errorf("WebDAV 上有 %d 个文件，NodeImage 上有 %d 张图片", len(files), len(images))
+	}
+	return expect(e.Sync(true), true, 0, 0, 0)
+}
+
+func nfdNamesMatched(e *testEnv) error {
+	// NodeImage 上的文件名是 NFC 形式，WebDAV（例如基于 macOS 的服务）上的同一文件是 NFD 形式
+	images := e.NodeImage.Images()
+	images[0].Name = "caf\u00e9.png"
+	e.NodeImage.SetImages(images)
+	e.WebDAV.Put(path.Join(basePath, "cafe\u0301.png"), images[0].Data)
+	for i := 0; i < 2; i++ {
+		uploads := 4
+		if i > 0 {
+			uploads = 0
+		}
+		if err := expect(e.Sync(true), true, uploads, 0, 0); err != nil {
+			return err
+		}
+	}
+	if _, ok := e.WebDAV.Files(basePath)["caf\u00e9.png"]; ok {
+		return fmt.Errorf("NFC 形式的文件名被重复上传")
+	}
+	return nil
+}
+
+func fullKeepsTemporary(e *testEnv) error {
+	temporary := []string{"upload.png.part", "upload.TMP", ".~lock.notes.odt#", ".DS_Store"}
+	for _, name := range temporary {
+		e.WebDAV.Put(path.Join(basePath, name), []byte("temporary"))
+	}
+	e.WebDAV.Put(path.Join(basePath, "extra.png"), []byte("extra"))
+	if err := expect(e.Sync(true), true, 5, 1, 0); err != nil {
+		return err
+	}
+	files := e.WebDAV.Files(basePath)
+	for _, name := range temporary {
+		if _, ok := files[name]; !ok {
+			return fmt.Errorf("全量同步删除了 %s", name)
+		}
+	}
+	return nil
+}
+
+func managedDeletesOnly(e *testEnv) error {
+	e.Config.DeleteScope = sync_lib.DeleteScopeManaged
+	// 图片 0 的文件在启用前就已由其他方式放入，与图片同名也不视为本工具管理的文件
+	images := e.NodeImage.Images()
+	e.WebDAV.Put(path.Join(basePath, images[0].Name), images[0].Data)
+	e.WebDAV.Put(path.Join(basePath, "other-tool.png"), []byte("other"))
+	if err := expect(e.Sync(true), true, 4, 0, 0); err != nil {
+		return err
+	}
+	// 从 NodeImage 删除图片 0 和 1：只有本工具上传的图片 1 被删除，其他方式放入的文件保留
+	e.NodeImage.SetImages(images[2:])
+	if err := expect(e.Sync(true), true, 0, 1, 0); err != nil {
+		return err
+	}
+	files := e.WebDAV.Files(basePath)
+	for _, name := range []string{images[0].Name, "other-tool.png"} {
+		if _, ok := files[name]; !ok {
+			return fmt.Errorf("删除了不是由本工具上传的文件 %s", name)
+		}
+	}
+	if _, ok := files[images[1].Name]; ok {
+		return fmt.Errorf("未删除本工具上传的文件 %s", images[1].Name)
+	}
+	return expect(e.Sync(true), true, 0, 0, 0)
+}
+
+func localIndex(e *testEnv) error {
+	dir, err := os.MkdirTemp("", "e2e-index-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	e.Config.IndexFile = filepath.Join(dir, "index.json")
+
+	if err := expect(e.Sync(true), true, 5, 0, 0); err != nil {
+		return err
+	}
+	// 全量同步之后，增量同步从本地索引得到 WebDAV 上的文件，不再列出目录
+	listings := e.WebDAV.Listings()
+	e.NodeImage.SetImages(append(e.NodeImage.Images(), newImage(5)))
+	if err := expect(e.Sync(false), true, 1, 0, 0); err != nil {
+		return err
+	}
+	if err := expect(e.Sync(false), true, 0, 0, 0); err != nil {
+		return err
+	}
+	if n := e.WebDAV.Listings() - listings; n != 0 {
+		return fmt.Errorf("配置了本地索引的增量同步仍列出了 %d 次目录", n)
+	}
+	if err := expect(e.Sync(true), true, 0, 0, 0); err != nil {
+		return err
+	}
+
+	// 刚上传的文件不需要校验，其他方式放入的文件需要
+	e.WebDAV.Put(path.Join(basePath, "manual.png"), []byte("manual"))
+	e.Config.VerifyOlderThan = 24 * time.Hour
+	report, err := sync_lib.Verify(context.Background(), e.Log, e.Config, e.HTTPClient)
+	if err != nil {
+		return err
+	}
+	if report.RecentFiles != 6 || report.CheckedFiles != 1 {
+		return fmt.Errorf("期望跳过 6 个最近上传的文件并校验 1 个，实际跳过 %d 个、校验 %d 个", report.RecentFiles, report.CheckedFiles)
+	}
+	return nil
+}
+
+func retryQueue(e *testEnv) error {
+	dir, err := os.MkdirTemp("", "e2e-retries-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	e.Config.RetryFile = filepath.Join(dir, "retries.json")
+
+	images := e.NodeImage.Images()
+	e.WebDAV.FailPut(images[1].Name, 1)
+	if err := expect(e.Sync(false), false, 4, 0, 1); err != nil {
+		return err
+	}
+	retries, err := sync_lib.Retries(e.Config)
+	if err != nil {
+		return err
+	}
+	if len(retries) != 1 || retries[0].File.Filename != images[1].Name || retries[0].Failures != 1 {
+		return fmt.Errorf("期望重试队列中只有 %s，实际为 %+v", images[1].Name, retries)
+	}
+
+	// 之后的增量同步只能看到新图片，失败的文件只能从重试队列中得到
+	e.NodeImage.SetImages(append(images, newImage(5)))
+	e.NodeImage.SetRecent(1)
+	if err := expect(e.Sync(false), true, 2, 0, 0); err != nil {
+		return err
+	}
+	if retries, err := sync_lib.Retries(e.Config); err != nil || len(retries) != 0 {
+		return fmt.Errorf("上传成功后重试队列仍有 %d 个文件 (%v)", len(retries), err)
+	}
+	return e.CheckMirrored()
+}
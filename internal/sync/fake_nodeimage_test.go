@@ -0,0 +1,222 @@
+package sync_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 模拟服务器接受的凭据。
+const (
+	testCookie   = "session=e2e"
+	testAPIKey   = "e2e-api-key"
+	testUsername = "e2e"
+	testPassword = "e2e-password"
+)
+
+// fakeImage 是模拟 NodeImage 上的一张图片。
+type fakeImage struct {
+	ID   string
+	Name string
+	Data []byte
+}
+
+// fakeNodeImage 模拟 NodeImage 的 Cookie 列表接口 (/api/images)、API Key 列表接口 (/api/v1/list) 和图片直链 (/i/<图片 ID>/<文件名>)。
+type fakeNodeImage struct {
+	Server *httptest.Server
+
+	mutex         sync.Mutex
+	images        []fakeImage
+	listStatus    int            // 非 0 时列表接口返回该状态码
+	recent        int            // 非 0 时 API Key 列表接口只返回最后的 recent 张图片
+	failDownloads map[string]int // 文件名 -> 剩余的下载失败次数
+	downloads     int
+}
+
+// newFakeNodeImage 启动一个包含 n 张图片的模拟 NodeImage 服务器。调用方负责调用 Close。
+func newFakeNodeImage(n int) *fakeNodeImage {
+	m := &fakeNodeImage{failDownloads: make(map[string]int)}
+	for i := 0; i < n; i++ {
+		m.images = append(m.images, newImage(i))
+	}
+	m.Server = httptest.NewServer(m)
+	return m
+}
+
+// newImage 返回第 i 张测试图片，内容和大小各不相同。
+func newImage(i int) fakeImage {
+	return fakeImage{
+		ID:   fmt.Sprintf("e2e%04d", i),
+		Name: fmt.Sprintf("img%04d.png", i),
+		Data: []byte(strings.Repeat(strconv.Itoa(i%10), 100+i)),
+	}
+}
+
+// Close 关闭服务器。
+func (m *fakeNodeImage) Close() { m.Server.Close() }
+
+// APIURL 返回 Cookie 列表接口的地址，用作 NODEIMAGE_API_URL。
+func (m *fakeNodeImage) APIURL() string { return m.Server.URL + "/api/images" }
+
+// Images 返回当前的图片列表。
+func (m *fakeNodeImage) Images() []fakeImage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]fakeImage(nil), m.images...)
+}
+
+// SetImages 替换图片列表。
+func (m *fakeNodeImage) SetImages(images []fakeImage) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.images = append([]fakeImage(nil), images...)
+}
+
+// SetRecent 使 API Key 列表接口像真实接口一样只返回最近的 n 张图片，传入 0 返回全部图片。
+func (m *fakeNodeImage) SetRecent(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recent = n
+}
+
+// FailList 使列表接口返回 status，传入 0 恢复正常。
+func (m *fakeNodeImage) FailList(status int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listStatus = status
+}
+
+// FailDownload 使文件 name 接下来的 times 次下载返回 500。
+func (m *fakeNodeImage) FailDownload(name string, times int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.failDownloads[name] = times
+}
+
+// Downloads 返回图片直链被成功下载的次数。
+func (m *fakeNodeImage) Downloads() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.downloads
+}
+
+func (m *fakeNodeImage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/images":
+		if r.Header.Get("Cookie") != testCookie {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if m.listStatus != 0 {
+			http.Error(w, "injected failure", m.listStatus)
+			return
+		}
+		m.serveCookieList(w, r)
+	case r.URL.Path == "/api/v1/list":
+		if r.Header.Get("X-API-Key") != testAPIKey {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if m.listStatus != 0 {
+			http.Error(w, "injected failure", m.listStatus)
+			return
+		}
+		m.serveAPIKeyList(w)
+	case strings.HasPrefix(r.URL.Path, "/i/"):
+		id, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/i/"), "/")
+		m.serveImage(w, r, id, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveCookieList 按 page 和 limit 分页返回图片列表。
+func (m *fakeNodeImage) serveCookieList(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, limit = max(page, 1), max(limit, 1)
+
+	images := []map[string]any{}
+	for i := (page - 1) * limit; i < min(page*limit, len(m.images)); i++ {
+		img := m.images[i]
+		images = append(images, map[string]any{
+			"imageId":  img.ID,
+			"filename": img.Name,
+			"size":     len(img.Data),
+			"url":      m.imageURL(img),
+		})
+	}
+	totalPages := (len(m.images) + limit - 1) / limit
+	json.NewEncoder(w).Encode(map[string]any{
+		"images": images,
+		"pagination": map[string]any{
+			"currentPage": page,
+			"totalPages":  totalPages,
+			"totalCount":  len(m.images),
+			"hasNextPage": page < totalPages,
+			"hasPrevPage": page > 1,
+		},
+	})
+}
+
+// serveAPIKeyList 返回全部图片，设置了 recent 时只返回最近的图片。
+func (m *fakeNodeImage) serveAPIKeyList(w http.ResponseWriter) {
+	list := m.images
+	if m.recent > 0 && m.recent < len(list) {
+		list = list[len(list)-m.recent:]
+	}
+	images := []map[string]any{}
+	for _, img := range list {
+		images = append(images, map[string]any{
+			"image_id": img.ID,
+			"filename": img.Name,
+			"size":     len(img.Data),
+			"links":    map[string]string{"direct": m.imageURL(img)},
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "images": images})
+}
+
+// imageURL 返回图片的直链。链接中包含图片 ID，同名的不同图片也有各自的链接。
+func (m *fakeNodeImage) imageURL(img fakeImage) string {
+	return m.Server.URL + "/i/" + img.ID + "/" + img.Name
+}
+
+func (m *fakeNodeImage) serveImage(w http.ResponseWriter, r *http.Request, id, name string) {
+	if m.failDownloads[name] > 0 {
+		m.failDownloads[name]--
+		http.Error(w, "injected failure", http.StatusInternalServerError)
+		return
+	}
+	for _, img := range m.images {
+		if img.ID == id && img.Name == name {
+			m.downloads++
+			w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+			w.Write(img.Data)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// fakeTransport 将发往 api.nodeimage.com 的请求（API Key 接口的地址是固定的）转发到模拟服务器。
+type fakeTransport struct {
+	NodeImage *fakeNodeImage
+}
+
+func (t fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == "api.nodeimage.com" {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = "http"
+		r.URL.Host = strings.TrimPrefix(t.NodeImage.Server.URL, "http://")
+		r.Host = r.URL.Host
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
@@ -0,0 +1,320 @@
+package sync_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeWebDAV 是一个内存中的 WebDAV 服务器，实现了同步引擎用到的 PROPFIND、GET、PUT、DELETE、MKCOL 和 MOVE。
+// 设置 PageSize 后，目录列表会像部分网盘一样通过 Link 头分页。
+type fakeWebDAV struct {
+	Server *httptest.Server
+
+	mutex    sync.Mutex
+	files    map[string][]byte
+	dirs     map[string]bool
+	pageSize int
+	quota    int64          // 非 0 时报告的存储总容量（字节）
+	failPuts map[string]int // 文件名 -> 剩余的上传失败次数
+	requests map[string]int // 方法 -> 请求次数
+	listings int            // 目录列表（Depth: 1 的 PROPFIND）请求次数
+}
+
+// newFakeWebDAV 启动一个空的模拟 WebDAV 服务器。调用方负责调用 Close。
+func newFakeWebDAV() *fakeWebDAV {
+	m := &fakeWebDAV{
+		files:    make(map[string][]byte),
+		dirs:     map[string]bool{"/": true},
+		failPuts: make(map[string]int),
+		requests: make(map[string]int),
+	}
+	m.Server = httptest.NewServer(m)
+	return m
+}
+
+// Close 关闭服务器。
+func (m *fakeWebDAV) Close() { m.Server.Close() }
+
+// URL 返回服务器地址，用作 WEBDAV_URL。
+func (m *fakeWebDAV) URL() string { return m.Server.URL }
+
+// SetPageSize 使目录列表每页最多返回 n 个条目，0 表示不分页。
+func (m *fakeWebDAV) SetPageSize(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pageSize = n
+}
+
+// SetQuota 使服务器报告总容量为 total 字节的配额，0 表示不报告配额。
+func (m *fakeWebDAV) SetQuota(total int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.quota = total
+}
+
+// FailPut 使文件 name 接下来的 times 次上传返回 500。
+func (m *fakeWebDAV) FailPut(name string, times int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.failPuts[name] = times
+}
+
+// Put 直接写入一个文件，并创建其所在的目录。
+func (m *fakeWebDAV) Put(p string, data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	p = path.Clean("/" + p)
+	for dir := path.Dir(p); !m.dirs[dir]; dir = path.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	m.files[p] = append([]byte(nil), data...)
+}
+
+// Files 返回目录 dir 下的直接子文件（文件名 -> 内容）。
+func (m *fakeWebDAV) Files(dir string) map[string][]byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	dir = path.Clean("/" + dir)
+	files := make(map[string][]byte)
+	for p, data := range m.files {
+		if path.Dir(p) == dir {
+			files[path.Base(p)] = data
+		}
+	}
+	return files
+}
+
+// Requests 返回收到的 method 请求次数。
+func (m *fakeWebDAV) Requests(method string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.requests[method]
+}
+
+// Listings 返回目录列表请求（Depth: 1 的 PROPFIND，每一页计一次）的次数。
+func (m *fakeWebDAV) Listings() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.listings
+}
+
+func (m *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != testUsername || pass != testPassword {
+		w.Header().Set("WWW-Authenticate", `Basic realm="e2e"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[r.Method]++
+
+	p := path.Clean("/" + r.URL.Path)
+	switch r.Method {
+	case "PROPFIND":
+		m.propfind(w, r, p)
+	case http.MethodGet:
+		data, ok := m.files[p]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	case http.MethodPut:
+		m.put(w, r, p)
+	case http.MethodDelete:
+		if _, ok := m.files[p]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(m.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		if m.dirs[p] || m.files[p] != nil {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !m.dirs[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		m.dirs[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case "MOVE":
+		m.move(w, r, p)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *fakeWebDAV) put(w http.ResponseWriter, r *http.Request, p string) {
+	if m.failPuts[path.Base(p)] > 0 {
+		m.failPuts[path.Base(p)]--
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !m.dirs[path.Dir(p)] {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if m.quota > 0 && m.used()-int64(len(m.files[p]))+int64(len(data)) > m.quota {
+		w.WriteHeader(http.StatusInsufficientStorage)
+		return
+	}
+	_, existed := m.files[p]
+	m.files[p] = data
+	if existed {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (m *fakeWebDAV) move(w http.ResponseWriter, r *http.Request, p string) {
+	dst, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target := path.Clean("/" + dst.Path)
+	data, ok := m.files[p]
+	switch {
+	case !ok:
+		w.WriteHeader(http.StatusNotFound)
+	case !m.dirs[path.Dir(target)]:
+		w.WriteHeader(http.StatusConflict)
+	case m.files[target] != nil && r.Header.Get("Overwrite") == "F":
+		w.WriteHeader(http.StatusPreconditionFailed)
+	default:
+		delete(m.files, p)
+		m.files[target] = data
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// propfind 返回 p 自身（Depth: 0）或 p 及其直接子级（Depth: 1）的属性。
+func (m *fakeWebDAV) propfind(w http.ResponseWriter, r *http.Request, p string) {
+	body, _ := io.ReadAll(r.Body)
+	data, isFile := m.files[p]
+	if !isFile && !m.dirs[p] {
+		http.NotFound(w, r)
+		return
+	}
+
+	var responses []davResponse
+	if isFile {
+		responses = append(responses, fileResponse(p, data))
+	} else {
+		self := davResponse{Href: escape(p) + "/"}
+		if m.quota > 0 && strings.Contains(string(body), "quota-available-bytes") {
+			self.Propstat.Prop.Available = strconv.FormatInt(m.quota-m.used(), 10)
+			self.Propstat.Prop.Used = strconv.FormatInt(m.used(), 10)
+		}
+		responses = append(responses, self)
+	}
+
+	if !isFile && r.Header.Get("Depth") == "1" {
+		m.listings++
+		children := m.children(p)
+		if m.pageSize > 0 {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			page = max(page, 1)
+			last := max((len(children)+m.pageSize-1)/m.pageSize, 1)
+			if page < last {
+				pageURL := func(n int) string { return fmt.Sprintf("%s%s?page=%d", m.Server.URL, escape(p), n) }
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, pageURL(page+1)))
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)))
+			}
+			children = children[min((page-1)*m.pageSize, len(children)):min(page*m.pageSize, len(children))]
+		}
+		responses = append(responses, children...)
+	}
+
+	for i := range responses {
+		responses[i].Propstat.Status = "HTTP/1.1 200 OK"
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(davMultistatus{XMLNS: "DAV:", Responses: responses})
+}
+
+// children 按名称顺序返回目录 dir 的直接子级。
+func (m *fakeWebDAV) children(dir string) []davResponse {
+	var names []string
+	for p := range m.files {
+		if path.Dir(p) == dir {
+			names = append(names, p)
+		}
+	}
+	for p := range m.dirs {
+		if p != "/" && path.Dir(p) == dir {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+
+	responses := make([]davResponse, 0, len(names))
+	for _, p := range names {
+		if data, ok := m.files[p]; ok {
+			responses = append(responses, fileResponse(p, data))
+		} else {
+			responses = append(responses, davResponse{Href: escape(p) + "/"})
+		}
+	}
+	return responses
+}
+
+// used 返回已用空间。
+func (m *fakeWebDAV) used() int64 {
+	var used int64
+	for _, data := range m.files {
+		used += int64(len(data))
+	}
+	return used
+}
+
+func fileResponse(p string, data []byte) davResponse {
+	resp := davResponse{Href: escape(p)}
+	resp.Propstat.Prop.ContentLength = strconv.Itoa(len(data))
+	return resp
+}
+
+func escape(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// --- XML 结构体 ---
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"d:multistatus"`
+	XMLNS     string        `xml:"xmlns:d,attr"`
+	Responses []davResponse `xml:"d:response"`
+}
+
+type davResponse struct {
+	Href     string `xml:"d:href"`
+	Propstat struct {
+		Prop struct {
+			ContentLength string `xml:"d:getcontentlength,omitempty"`
+			Available     string `xml:"d:quota-available-bytes,omitempty"`
+			Used          string `xml:"d:quota-used-bytes,omitempty"`
+		} `xml:"d:prop"`
+		Status string `xml:"d:status"`
+	} `xml:"d:propstat"`
+}
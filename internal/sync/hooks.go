@@ -0,0 +1,191 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// Hook 是同步引擎在执行阶段的扩展点。通知、清单、图库等集成实现该接口，
+// 通过 RegisterHook 或 Config.Hooks 注册，而无需修改同步流程本身。
+// 引擎自带的集成（图片 ID 记录、本地索引、重试队列、快照清单和外部监控）同样以 Hook 实现，见 builtinHooks。
+// 只需关心部分事件的实现可以嵌入 NopHook。
+//
+// 同一次同步中，各方法按顺序被串行调用，实现无需自行加锁；但它们会阻塞执行阶段，耗时的操作应自行异步处理。
+// 演练模式下不调用任何 Hook。
+type Hook interface {
+	// OnPlan 在执行计划之前调用，可以修改计划（例如过滤掉部分文件）。返回错误会中止本次同步。
+	OnPlan(ctx context.Context, plan *Plan) error
+	// OnFileUploaded 在一个文件成功上传到 WebDAV 的 targetPath 后调用。
+	OnFileUploaded(ctx context.Context, file nodeimage.ImageInfo, targetPath string) error
+	// OnFileDeleted 在 WebDAV 上的一个文件被删除后调用。旧版本模式下 versionPath 是文件被保留的路径，否则为空。
+	// 清理过期旧版本不会触发该事件，见 FilePurgedHook。
+	OnFileDeleted(ctx context.Context, filePath, versionPath string) error
+	// OnComplete 在计划执行结束后调用，无论成功与否。RunSync 在扫描阶段就失败时同样会调用，此时 plan 为 nil。
+	OnComplete(ctx context.Context, plan *Plan, result Result) error
+}
+
+// SyncStartedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在 RunSync 开始扫描之前收到通知；
+// 单独调用 ExecutePlan 执行已有的计划时不会触发该事件。
+type SyncStartedHook interface {
+	OnSyncStarted(ctx context.Context, isFullSync bool) error
+}
+
+// FileRenamedHook 是 Hook 可选实现的接口。按 ID 对应时，NodeImage 上改过名的图片会在 WebDAV 上
+// 从 fromPath 重命名为 toPath，而不是删除后重新上传；实现了该接口的 Hook 会在重命名成功后收到通知。
+type FileRenamedHook interface {
+	OnFileRenamed(ctx context.Context, file nodeimage.ImageInfo, fromPath, toPath string) error
+}
+
+// UploadFailedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在一个文件重试耗尽后仍上传失败时收到通知，
+// err 为最后一次尝试的错误。
+type UploadFailedHook interface {
+	OnUploadFailed(ctx context.Context, file nodeimage.ImageInfo, err error) error
+}
+
+// FilePurgedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在一个过期的旧版本文件被清理后收到通知。
+type FilePurgedHook interface {
+	OnFilePurged(ctx context.Context, filePath string) error
+}
+
+// NopHook 是不做任何事的 Hook，可嵌入到只实现部分方法的 Hook 中。
+type NopHook struct{}
+
+func (NopHook) OnPlan(context.Context, *Plan) error                               { return nil }
+func (NopHook) OnFileUploaded(context.Context, nodeimage.ImageInfo, string) error { return nil }
+func (NopHook) OnFileDeleted(context.Context, string, string) error               { return nil }
+func (NopHook) OnComplete(context.Context, *Plan, Result) error                   { return nil }
+
+var (
+	hooksMutex sync.Mutex
+	hooks      []Hook
+)
+
+// RegisterHook 注册一个对所有同步生效的 Hook，通常在集成所在包的 init 中调用。
+// 注册的 Hook 在内置的 Hook 之后、Config.Hooks 中的 Hook 之前被调用。
+func RegisterHook(h Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append(hooks, h)
+}
+
+// hookRunner 将一次同步中的事件依次分发给所有 Hook，并串行化来自并发工作协程的调用。
+// 除 OnPlan 外，Hook 返回的错误只记录警告，不影响同步结果。
+type hookRunner struct {
+	mutex sync.Mutex
+	log   logger.Logger
+	hooks []Hook
+}
+
+// newHookRunner 返回本次同步需要调用的 Hook：内置的 Hook、全局注册的 Hook，再加上 config 中的 Hook。
+// 演练模式下不调用任何 Hook。
+func newHookRunner(log logger.Logger, config Config, httpClient *http.Client) *hookRunner {
+	if config.DryRun {
+		return &hookRunner{log: log}
+	}
+	all := builtinHooks(log, config, httpClient)
+	hooksMutex.Lock()
+	all = append(all, hooks...)
+	hooksMutex.Unlock()
+	return &hookRunner{log: log, hooks: append(all, config.Hooks...)}
+}
+
+// builtinHooks 返回按 config 启用的内置集成。新的内置集成应在这里注册，而不是修改同步流程。
+func builtinHooks(log logger.Logger, config Config, httpClient *http.Client) []Hook {
+	config = config.withDefaults()
+	var builtin []Hook
+	if config.tracksIDs() || config.Snapshots {
+		webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+		if config.tracksIDs() {
+			builtin = append(builtin, newIDIndexHook(log, webdavClient, config.WebdavBasePath))
+		}
+		if config.Snapshots {
+			builtin = append(builtin, newSnapshotHook(log, webdavClient, config.WebdavBasePath))
+		}
+	}
+	if config.IndexFile != "" {
+		builtin = append(builtin, newIndexHook(config))
+	}
+	if config.RetryFile != "" {
+		builtin = append(builtin, newRetryHook(config))
+	}
+	if config.HealthchecksURL != "" || config.UptimeKumaURL != "" {
+		builtin = append(builtin, newMonitorHook(log, config, httpClient))
+	}
+	return builtin
+}
+
+func (r *hookRunner) started(ctx context.Context, isFullSync bool) {
+	r.each(func(h Hook) error {
+		if sh, ok := h.(SyncStartedHook); ok {
+			return sh.OnSyncStarted(ctx, isFullSync)
+		}
+		return nil
+	})
+}
+
+func (r *hookRunner) plan(ctx context.Context, plan *Plan) error {
+	for _, h := range r.hooks {
+		if err := h.OnPlan(ctx, plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *hookRunner) uploaded(ctx context.Context, file nodeimage.ImageInfo, targetPath string) {
+	r.each(func(h Hook) error { return h.OnFileUploaded(ctx, file, targetPath) })
+}
+
+func (r *hookRunner) uploadFailed(ctx context.Context, file nodeimage.ImageInfo, err error) {
+	r.each(func(h Hook) error {
+		if fh, ok := h.(UploadFailedHook); ok {
+			return fh.OnUploadFailed(ctx, file, err)
+		}
+		return nil
+	})
+}
+
+func (r *hookRunner) deleted(ctx context.Context, filePath, versionPath string) {
+	r.each(func(h Hook) error { return h.OnFileDeleted(ctx, filePath, versionPath) })
+}
+
+func (r *hookRunner) renamed(ctx context.Context, file nodeimage.ImageInfo, fromPath, toPath string) {
+	r.each(func(h Hook) error {
+		if rh, ok := h.(FileRenamedHook); ok {
+			return rh.OnFileRenamed(ctx, file, fromPath, toPath)
+		}
+		return nil
+	})
+}
+
+func (r *hookRunner) purged(ctx context.Context, filePath string) {
+	r.each(func(h Hook) error {
+		if ph, ok := h.(FilePurgedHook); ok {
+			return ph.OnFilePurged(ctx, filePath)
+		}
+		return nil
+	})
+}
+
+func (r *hookRunner) complete(ctx context.Context, plan *Plan, result Result) {
+	r.each(func(h Hook) error { return h.OnComplete(ctx, plan, result) })
+}
+
+func (r *hookRunner) each(fn func(Hook) error) {
+	if len(r.hooks) == 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, h := range r.hooks {
+		if err := fn(h); err != nil {
+			r.log.Warn("  -> ⚠️ 扩展 %T 处理失败: %v", h, err)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/diff"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// 文件对应方式，决定 NodeImage 上的图片与 WebDAV 上的文件如何对应。
+const (
+	MatchByName = "name" // 按文件名对应（默认）
+	MatchByID   = "id"   // 优先按图片 ID 对应，NodeImage 上改名的图片不会被删除后重新上传
+)
+
+// 删除范围，决定全量同步可以删除同步目录中的哪些文件。
+const (
+	DeleteScopeAll     = "all"     // 所有没有对应图片的文件（默认）
+	DeleteScopeManaged = "managed" // 只删除本工具上传或重命名过的文件，其他工具放入目录的文件不受影响
+)
+
+// tracksIDs 判断本次同步是否需要维护图片 ID 记录：按 ID 对应时用于对应，限定删除范围时用作本工具管理的文件清单。
+func (c Config) tracksIDs() bool {
+	return c.MatchBy == MatchByID || c.DeleteScope == DeleteScopeManaged
+}
+
+// idIndexName 是状态目录下记录 WebDAV 文件名与图片 ID 对应关系的边车元数据文件。
+const idIndexName = "ids.json"
+
+// idIndex 是边车元数据文件的内容。
+// Files 记录所有已对应的文件的图片 ID，只用于按 ID 对应；Managed 只记录本工具上传或重命名的文件，
+// 限定删除范围时只有其中的文件可以被删除。两者分开记录，使恰好与图片同名的其他工具的文件不会被视为本工具管理的文件。
+// 没有图片 ID 的文件不会出现在 Files 中（按 ID 对应时退回按文件名对应），但由本工具上传时仍会被记入 Managed。
+type idIndex struct {
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Files     map[string]string `json:"files"`             // WebDAV 文件名 -> 图片 ID
+	Managed   map[string]bool   `json:"managed,omitempty"` // 本工具管理的 WebDAV 文件名
+}
+
+// idIndexPath 返回边车元数据文件在 WebDAV 上的路径。
+func idIndexPath(basePath string) string {
+	return path.Join(basePath, stateDirName, idIndexName)
+}
+
+// loadIDIndex 读取边车元数据。文件不存在时返回空记录；文件已损坏时记录警告并返回空记录，
+// 此时所有文件退回按文件名（或文件名中的 ID）对应，限定删除范围时不删除任何文件。
+func loadIDIndex(ctx context.Context, log logger.Logger, client *webdav.Client, basePath string) (idIndex, error) {
+	empty := idIndex{Files: map[string]string{}, Managed: map[string]bool{}}
+	data, err := client.ReadFile(ctx, idIndexPath(basePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return idIndex{}, fmt.Errorf("读取图片 ID 记录失败: %w", err)
+	}
+	var index idIndex
+	if err := json.Unmarshal(data, &index); err != nil || index.Files == nil {
+		log.Warn("  -> ⚠️ 图片 ID 记录已损坏，将重新生成: %v", err)
+		return empty, nil
+	}
+	if index.Managed == nil {
+		index.Managed = map[string]bool{}
+	}
+	return index, nil
+}
+
+// matchOptions 返回按 config.MatchBy 对比文件列表时使用的选项。需要维护图片 ID 记录时会读取边车元数据，
+// 读取失败时记录警告并退回按文件名（或文件名中的 ID）对应。返回的是读取到的记录，不需要时为零值。
+func matchOptions(ctx context.Context, log logger.Logger, client *webdav.Client, config Config) (diff.Options, idIndex) {
+	opts := diff.Options{Ignore: isVersionedFile, Normalize: config.normalizer()}
+	if !config.tracksIDs() {
+		return opts, idIndex{}
+	}
+	index, err := loadIDIndex(ctx, log, client, config.WebdavBasePath)
+	if err != nil {
+		log.Warn("  -> ⚠️ %v，本次按文件名对应", err)
+		index = idIndex{Files: map[string]string{}, Managed: map[string]bool{}}
+	}
+	if config.MatchBy == MatchByID {
+		opts.MatchBy, opts.IDs = diff.ByID, index.Files
+	}
+	return opts, index
+}
+
+// managedOnly 从删除列表中去掉不在本工具管理的文件 managed 中的文件。记录读取失败时 managed 为空，此时不删除任何文件。
+func managedOnly(log logger.Logger, toDelete []string, managed map[string]bool) []string {
+	kept := toDelete[:0:0]
+	for _, filePath := range toDelete {
+		if managed[path.Base(filePath)] {
+			kept = append(kept, filePath)
+		}
+	}
+	if skipped := len(toDelete) - len(kept); skipped > 0 {
+		log.Info("  -> [删除] 保留 %d 个不是由本工具管理的文件", skipped)
+	}
+	return kept
+}
+
+// saveIDIndex 将边车元数据写入 WebDAV。
+func saveIDIndex(ctx context.Context, client *webdav.Client, basePath string, index idIndex) error {
+	index.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化图片 ID 记录失败: %w", err)
+	}
+	if err := client.MakeDir(ctx, path.Join(basePath, stateDirName)); err != nil {
+		return err
+	}
+	return client.UploadFile(ctx, idIndexPath(basePath), data)
+}
+
+// idIndexPatch 根据本次对比的结果计算边车元数据的变更（文件名 -> 图片 ID，空字符串表示删除该文件的全部记录）：
+// 记录所有已对应的文件的图片 ID，并删除 WebDAV 上已不存在的文件的记录。没有任何变更时返回 nil。
+// 已对应的文件只记录 ID，不会因此成为本工具管理的文件。
+func idIndexPatch(index idIndex, files []nodeimage.ImageInfo, webdavFiles []string, matched []diff.Match) map[string]string {
+	patch := make(map[string]string)
+	present := make(map[string]bool, len(webdavFiles))
+	for _, f := range webdavFiles {
+		present[path.Base(f)] = true
+	}
+	for name := range index.Files {
+		if !present[name] {
+			patch[name] = ""
+		}
+	}
+	for name := range index.Managed {
+		if !present[name] {
+			patch[name] = ""
+		}
+	}
+	for _, m := range matched {
+		name, id := path.Base(m.Target), files[m.Source].ID
+		if id != "" && index.Files[name] != id {
+			patch[name] = id
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	return patch
+}
+
+// Rename 描述 WebDAV 上的一次重命名：图片在 NodeImage 上改了名，WebDAV 上已有同一图片 ID 的文件。
+// 用 MOVE 重命名该文件，而不是重新下载和上传。
+type Rename struct {
+	From string              `json:"from"` // WebDAV 上的原路径
+	File nodeimage.ImageInfo `json:"file"` // NodeImage 上的图片，文件名即新文件名
+}
+
+// target 返回重命名后的路径，与原文件位于同一目录。
+func (r Rename) target() string {
+	return path.Join(path.Dir(r.From), r.File.Filename)
+}
+
+// planRenames 找出按 ID 对应、但 WebDAV 上的文件名与 NodeImage 上不同的图片。文件名按 normalize 规范化后再比较。
+// 新文件名已被 WebDAV 上的其他文件占用时不重命名，图片继续以原文件名保存，
+// 待占用的文件被删除后的下一次同步再重命名。
+func planRenames(files []nodeimage.ImageInfo, webdavFiles []string, matched []diff.Match, normalize func(string) string) []Rename {
+	taken := make(map[string]bool, len(webdavFiles))
+	for _, f := range webdavFiles {
+		taken[normalized(normalize, path.Base(f))] = true
+	}
+	var renames []Rename
+	for _, m := range matched {
+		file := files[m.Source]
+		name := normalized(normalize, file.Filename)
+		if normalized(normalize, path.Base(m.Target)) == name || taken[name] {
+			continue
+		}
+		taken[name] = true
+		renames = append(renames, Rename{From: m.Target, File: file})
+	}
+	return renames
+}
+
+// moved 判断 from 已不存在且 to 已存在，即重命名已经完成。
+func moved(ctx context.Context, client *webdav.Client, from, to string) bool {
+	if _, err := client.Stat(ctx, from); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	_, err := client.Stat(ctx, to)
+	return err == nil
+}
+
+// idIndexHook 在执行阶段维护边车元数据：记录新上传和重命名的文件（并将其标记为本工具管理）、
+// 删除被删除的文件的记录，并在结束时合并写入。写入前重新读取 WebDAV 上的记录，使分批执行的各批次不会互相覆盖。
+type idIndexHook struct {
+	NopHook
+	log      logger.Logger
+	client   *webdav.Client
+	basePath string
+	patch    map[string]string // 图片 ID 的变更，空字符串表示删除该文件的全部记录
+	managed  map[string]bool   // 本工具管理的文件的变更，false 表示不再管理
+}
+
+func newIDIndexHook(log logger.Logger, client *webdav.Client, basePath string) *idIndexHook {
+	return &idIndexHook{log: log, client: client, basePath: basePath, patch: make(map[string]string), managed: make(map[string]bool)}
+}
+
+// OnPlan 从计划中取得扫描阶段得到的记录变更。
+func (h *idIndexHook) OnPlan(_ context.Context, plan *Plan) error {
+	for name, id := range plan.IDs {
+		h.patch[name] = id
+	}
+	return nil
+}
+
+func (h *idIndexHook) OnFileUploaded(_ context.Context, file nodeimage.ImageInfo, targetPath string) error {
+	h.record(path.Base(targetPath), file.ID)
+	return nil
+}
+
+func (h *idIndexHook) OnFileDeleted(_ context.Context, filePath, _ string) error {
+	h.forget(path.Base(filePath))
+	return nil
+}
+
+func (h *idIndexHook) OnFileRenamed(_ context.Context, file nodeimage.ImageInfo, fromPath, toPath string) error {
+	h.forget(path.Base(fromPath))
+	h.record(path.Base(toPath), file.ID)
+	return nil
+}
+
+// record 将本工具写入的文件 name 标记为本工具管理，并记录其图片 ID（如果有）。
+// 覆盖上传时同名文件原有的 ID 记录先被清除，避免留下另一张图片的 ID。
+func (h *idIndexHook) record(name, id string) {
+	h.patch[name] = id
+	h.managed[name] = true
+}
+
+// forget 删除文件 name 的全部记录。
+func (h *idIndexHook) forget(name string) {
+	h.patch[name] = ""
+	h.managed[name] = false
+}
+
+func (h *idIndexHook) OnComplete(ctx context.Context, _ *Plan, _ Result) error {
+	if len(h.patch) == 0 && len(h.managed) == 0 {
+		return nil
+	}
+	// 读取失败时不写入，避免用不完整的记录覆盖原有记录；下一次同步会重新记录已对应的文件
+	index, err := loadIDIndex(ctx, h.log, h.client, h.basePath)
+	if err != nil {
+		return err
+	}
+	for name, id := range h.patch {
+		if id == "" {
+			delete(index.Files, name)
+			delete(index.Managed, name)
+		} else {
+			index.Files[name] = id
+		}
+	}
+	for name, managed := range h.managed {
+		if managed {
+			index.Managed[name] = true
+		} else {
+			delete(index.Managed, name)
+		}
+	}
+	if err := saveIDIndex(ctx, h.client, h.basePath, index); err != nil {
+		return fmt.Errorf("保存图片 ID 记录失败: %w", err)
+	}
+	h.log.Debug("已更新图片 ID 记录 (%d 项变更，共 %d 个文件)", len(h.patch), len(index.Files))
+	return nil
+}
@@ -0,0 +1,229 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// indexFileVersion 是本地索引文件格式的版本，格式不兼容时忽略旧文件。
+const indexFileVersion = 1
+
+// IndexEntry 是本地索引中一个 WebDAV 文件的记录。
+type IndexEntry struct {
+	Size       int64     `json:"size"`
+	SyncedAt   time.Time `json:"syncedAt"`   // 最近一次由本工具上传的时间，在列表中发现的已有文件为零值
+	VerifiedAt time.Time `json:"verifiedAt"` // 最近一次校验通过的时间，从未校验过为零值
+}
+
+// checkedAt 返回文件最近一次被确认完好的时间：上传或校验通过，取较晚者。
+func (e IndexEntry) checkedAt() time.Time {
+	if e.VerifiedAt.After(e.SyncedAt) {
+		return e.VerifiedAt
+	}
+	return e.SyncedAt
+}
+
+// indexTarget 是一个同步目标在本地索引中的记录。
+type indexTarget struct {
+	ListedAt time.Time             `json:"listedAt"` // 最近一次用完整的 WebDAV 文件列表刷新的时间
+	Files    map[string]IndexEntry `json:"files"`    // 文件名 -> 记录
+}
+
+// indexFile 是本地索引文件的内容。
+type indexFile struct {
+	Version int                     `json:"version"`
+	Targets map[string]*indexTarget `json:"targets"` // 同步目标（见 cacheKey）-> 记录
+}
+
+// indexMutex 串行化本进程内对索引文件的读写，多个任务可能同时同步不同的目标。
+var indexMutex sync.Mutex
+
+// readIndex 读取索引文件。文件不存在或格式版本不同时返回空索引。
+func readIndex(file string) (*indexFile, error) {
+	index := &indexFile{Version: indexFileVersion, Targets: make(map[string]*indexTarget)}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取本地索引失败: %w", err)
+	}
+	var saved indexFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("解析本地索引失败: %w", err)
+	}
+	if saved.Version != indexFileVersion || saved.Targets == nil {
+		return index, nil
+	}
+	return &saved, nil
+}
+
+// loadIndexTarget 返回同步目标 key 在索引文件中的记录，没有记录时返回 nil。
+func loadIndexTarget(file, key string) (*indexTarget, error) {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	index, err := readIndex(file)
+	if err != nil {
+		return nil, err
+	}
+	return index.Targets[key], nil
+}
+
+// updateIndexTarget 读取同步目标 key 的记录，交给 fn 修改后写回索引文件。
+func updateIndexTarget(file, key string, fn func(t *indexTarget)) error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	index, err := readIndex(file)
+	if err != nil {
+		return err
+	}
+	target := index.Targets[key]
+	if target == nil {
+		target = &indexTarget{}
+	}
+	if target.Files == nil {
+		target.Files = make(map[string]IndexEntry)
+	}
+	fn(target)
+	index.Targets[key] = target
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("序列化本地索引失败: %w", err)
+	}
+	if err := writeFileAtomic(file, data); err != nil {
+		return fmt.Errorf("保存本地索引失败: %w", err)
+	}
+	return nil
+}
+
+// indexedFiles 将索引中的记录转换为 WebDAV 文件列表，用于不列出 WebDAV 的增量同步。
+func (t *indexTarget) indexedFiles(basePath string) []webdav.FileInfo {
+	files := make([]webdav.FileInfo, 0, len(t.Files))
+	for name, entry := range t.Files {
+		files = append(files, webdav.FileInfo{Path: path.Join(basePath, name), Size: entry.Size})
+	}
+	return files
+}
+
+// indexedListing 返回本地索引中记录的 WebDAV 文件列表，供增量同步代替 PROPFIND。
+// 全量同步、未配置索引、索引从未用完整的列表刷新过或读取失败时返回 false。
+func indexedListing(log logger.Logger, config Config, isFullSync bool) ([]webdav.FileInfo, bool) {
+	if isFullSync || config.IndexFile == "" {
+		return nil, false
+	}
+	t, err := loadIndexTarget(config.IndexFile, config.cacheKey())
+	if err != nil {
+		log.Warn("  -> ⚠️ %v，将重新获取 WebDAV 文件列表", err)
+		return nil, false
+	}
+	if t == nil || t.ListedAt.IsZero() {
+		return nil, false
+	}
+	return t.indexedFiles(config.WebdavBasePath), true
+}
+
+// refreshIndex 用完整的 WebDAV 文件列表替换同步目标的记录。大小未变的文件保留原有的同步和校验时间。
+func refreshIndex(log logger.Logger, config Config, files []webdav.FileInfo) {
+	if config.IndexFile == "" {
+		return
+	}
+	err := updateIndexTarget(config.IndexFile, config.cacheKey(), func(t *indexTarget) {
+		entries := make(map[string]IndexEntry, len(files))
+		for _, f := range files {
+			name := path.Base(f.Path)
+			entry := t.Files[name]
+			if entry.Size != f.Size {
+				entry = IndexEntry{Size: f.Size}
+			}
+			entries[name] = entry
+		}
+		t.Files, t.ListedAt = entries, time.Now()
+	})
+	if err != nil {
+		log.Warn("  -> ⚠️ %v", err)
+	}
+}
+
+// indexHook 在执行阶段将上传、删除、重命名和旧版本清理记录到本地索引中，并在结束时写入。
+// 索引被用来代替 WebDAV 文件列表，因此只记录确实成功的操作。
+type indexHook struct {
+	NopHook
+	file    string
+	key     string
+	changes map[string]*IndexEntry // 文件名 -> 新记录，nil 表示删除
+	indexed *indexTarget           // 执行前的记录，仅在需要时读取
+}
+
+func newIndexHook(config Config) *indexHook {
+	return &indexHook{file: config.IndexFile, key: config.cacheKey(), changes: make(map[string]*IndexEntry)}
+}
+
+func (h *indexHook) OnFileUploaded(_ context.Context, file nodeimage.ImageInfo, targetPath string) error {
+	h.changes[path.Base(targetPath)] = &IndexEntry{Size: file.Size, SyncedAt: time.Now()}
+	return nil
+}
+
+func (h *indexHook) OnFileDeleted(_ context.Context, filePath, versionPath string) error {
+	name := path.Base(filePath)
+	if versionPath != "" {
+		// 旧版本是原文件改名得到的，沿用原文件的记录
+		h.changes[path.Base(versionPath)] = h.entry(name)
+	}
+	h.changes[name] = nil
+	return nil
+}
+
+func (h *indexHook) OnFileRenamed(_ context.Context, file nodeimage.ImageInfo, fromPath, toPath string) error {
+	entry := h.entry(path.Base(fromPath))
+	entry.Size = file.Size
+	h.changes[path.Base(fromPath)] = nil
+	h.changes[path.Base(toPath)] = entry
+	return nil
+}
+
+func (h *indexHook) OnFilePurged(_ context.Context, filePath string) error {
+	h.changes[path.Base(filePath)] = nil
+	return nil
+}
+
+func (h *indexHook) OnComplete(context.Context, *Plan, Result) error {
+	if len(h.changes) == 0 {
+		return nil
+	}
+	return updateIndexTarget(h.file, h.key, func(t *indexTarget) {
+		for name, entry := range h.changes {
+			if entry == nil {
+				delete(t.Files, name)
+			} else {
+				t.Files[name] = *entry
+			}
+		}
+	})
+}
+
+// entry 返回文件 name 当前的记录的副本，没有记录时返回空记录。
+func (h *indexHook) entry(name string) *IndexEntry {
+	if entry := h.changes[name]; entry != nil {
+		copied := *entry
+		return &copied
+	}
+	if h.indexed == nil {
+		t, err := loadIndexTarget(h.file, h.key)
+		if err != nil || t == nil {
+			t = &indexTarget{}
+		}
+		h.indexed = t
+	}
+	entry := h.indexed.Files[name]
+	return &entry
+}
@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// limiter 限制同时进行的操作数。
+// 启用自适应模式时，它按 AIMD（加性增、乘性减）调整并发上限：
+// 遇到限流 (429/503 等) 或超时时将上限减半，后端恢复后每完成一轮成功操作将上限加一，
+// 直到回到配置的 SyncConcurrency。这样无需针对不同的 WebDAV 服务商手动调整并发数。
+// 成功操作的耗时不作为拥塞信号：上传耗时主要取决于文件大小，大文件变慢并不代表后端过载。
+type limiter struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	log      logger.Logger
+	adaptive bool
+
+	max       int       // 配置的并发上限
+	limit     int       // 当前的并发上限
+	inFlight  int       // 正在进行的操作数
+	successes int       // 上次调整以来的成功次数
+	lastCut   time.Time // 上次降低并发的时间
+}
+
+// newLimiter 创建并发限制器，concurrency 小于 1 时按 1 处理。
+func newLimiter(log logger.Logger, concurrency int, adaptive bool) *limiter {
+	concurrency = max(concurrency, 1)
+	l := &limiter{log: log, adaptive: adaptive, max: concurrency, limit: concurrency}
+	l.cond = sync.NewCond(&l.mutex)
+	return l
+}
+
+// do 在获得一个并发名额后执行 fn，并根据其结果调整并发上限。
+// 与 withRetry 配合时应在每次尝试内调用，使重试前的等待不占用名额，且每次失败都能被感知。
+func (l *limiter) do(fn func() error) error {
+	l.mutex.Lock()
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	l.mutex.Unlock()
+
+	start := time.Now()
+	err := fn()
+	l.release(start, err)
+	return err
+}
+
+// release 归还名额，并在自适应模式下调整并发上限。
+func (l *limiter) release(start time.Time, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	defer l.cond.Broadcast()
+	l.inFlight--
+	if !l.adaptive {
+		return
+	}
+
+	if isThrottled(err) {
+		// 只对上次降低之后才开始的操作作出反应，避免同一次拥塞中的多个失败把并发连续减半
+		if start.After(l.lastCut) && l.limit > 1 {
+			l.limit = max(l.limit/2, 1)
+			l.lastCut = time.Now()
+			l.successes = 0
+			l.log.Warn("  -> ⚠️ 后端限流或超时，并发数降低到 %d", l.limit)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	l.successes++
+	if l.limit < l.max && l.successes >= l.limit {
+		l.limit++
+		l.successes = 0
+		l.log.Debug("  -> 后端已恢复，并发数提高到 %d", l.limit)
+	}
+}
+
+// isThrottled 判断错误是否表示后端过载：限流或暂时不可用的状态码，以及请求超时。
+// 其他错误（例如 404 或权限错误）与后端负载无关，不会影响并发。
+func isThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	status := 0
+	var davErr *webdav.StatusError
+	var niErr *nodeimage.StatusError
+	switch {
+	case errors.As(err, &davErr):
+		status = davErr.StatusCode
+	case errors.As(err, &niErr):
+		status = niErr.StatusCode
+	}
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/diff"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// URLMapping 是一张已同步图片从 NodeImage 直链到 WebDAV 位置的映射。
+type URLMapping struct {
+	ImageID      string `json:"imageId"`
+	Filename     string `json:"filename"`
+	Size         int64  `json:"size"`
+	NodeImageURL string `json:"nodeimageUrl"`
+	WebdavPath   string `json:"webdavPath"`
+	WebdavURL    string `json:"webdavUrl"`
+}
+
+// BuildURLMapping 列出 NodeImage 上每一张已备份到 WebDAV 的图片的直链和 WebDAV 地址。
+// 尚未同步的图片不会出现在映射中。如果 NodeImage 停止服务，可以据此批量替换博客中的外链。
+func BuildURLMapping(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client) ([]URLMapping, error) {
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		return nil, fmt.Errorf("WebDAV 配置未完全设置")
+	}
+	if config.NodeImageCookie == "" && config.NodeImageAPIKey == "" {
+		return nil, fmt.Errorf("NodeImage Cookie 和 API Key 均未设置")
+	}
+	config = config.withDefaults()
+	stats := stats.New()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats, log, httpClient)
+
+	nodeImageFiles, err := listNodeImageFiles(ctx, log, config, httpClient, stats)
+	if err != nil {
+		return nil, fmt.Errorf("获取 NodeImage 文件列表失败: %w", err)
+	}
+	webdavFiles, err := webdavClient.ListFiles(ctx, config.WebdavBasePath)
+	if err != nil {
+		return nil, fmt.Errorf("获取 WebDAV 文件列表失败: %w", err)
+	}
+
+	opts, _ := matchOptions(ctx, log, webdavClient, config)
+	matched := diff.Compare(diffSources(nodeImageFiles), diffTargets(webdavFiles), opts).Matched
+	mappings := make([]URLMapping, 0, len(matched))
+	for _, m := range matched {
+		file, webdavPath := nodeImageFiles[m.Source], m.Target
+		webdavURL, err := url.JoinPath(config.WebdavURL, webdavPath)
+		if err != nil {
+			return nil, fmt.Errorf("无法生成 WebDAV 地址: %w", err)
+		}
+		mappings = append(mappings, URLMapping{
+			ImageID:      file.ID,
+			Filename:     file.Filename,
+			Size:         file.Size,
+			NodeImageURL: file.URL,
+			WebdavPath:   webdavPath,
+			WebdavURL:    webdavURL,
+		})
+	}
+	log.Info("  -> ✅ 已生成 %d 条链接映射 (NodeImage 共 %d 张图片)", len(mappings), len(nodeImageFiles))
+	return mappings, nil
+}
+
+// WriteURLMappingCSV 将链接映射以带表头的 CSV 格式写入 w。
+func WriteURLMappingCSV(w io.Writer, mappings []URLMapping) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"image_id", "filename", "size", "nodeimage_url", "webdav_path", "webdav_url"})
+	for _, m := range mappings {
+		cw.Write([]string{m.ImageID, m.Filename, strconv.FormatInt(m.Size, 10), m.NodeImageURL, m.WebdavPath, m.WebdavURL})
+	}
+	cw.Flush()
+	return cw.Error()
+}
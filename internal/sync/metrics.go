@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/pushgateway"
+)
+
+// PushMetrics 将一次同步的结果推送到 Pushgateway，便于对短生命周期的 cron 任务设置告警。
+// 指标带有 mode 分组标签（full 或 incremental），同一 job 的两种模式互不覆盖。
+func PushMetrics(ctx context.Context, httpClient *http.Client, gatewayURL, job string, isFullSync bool, result Result) error {
+	mode := "incremental"
+	if isFullSync {
+		mode = "full"
+	}
+	success := 0.0
+	if result.Success {
+		success = 1
+	}
+
+	metrics := []pushgateway.Metric{
+		{Name: "nodeimage_sync_duration_seconds", Help: "最近一次同步的耗时（秒）", Value: result.Duration.Seconds()},
+		{Name: "nodeimage_sync_uploaded_files", Help: "最近一次同步成功上传的文件数", Value: float64(result.Uploaded)},
+		{Name: "nodeimage_sync_deleted_files", Help: "最近一次同步成功删除的文件数", Value: float64(result.Deleted)},
+		{Name: "nodeimage_sync_failed_operations", Help: "最近一次同步中失败的上传和删除操作数", Value: float64(result.Failed)},
+		{Name: "nodeimage_sync_upload_bytes", Help: "最近一次同步计划上传的字节数", Value: float64(result.UploadSize)},
+		{Name: "nodeimage_sync_success", Help: "最近一次同步是否成功 (1 为成功，0 为失败)", Value: success},
+		{Name: "nodeimage_sync_last_run_timestamp_seconds", Help: "最近一次同步结束的 Unix 时间戳", Value: float64(time.Now().Unix())},
+	}
+
+	labels := [][2]string{{"mode", mode}}
+	return pushgateway.Push(ctx, httpClient, gatewayURL, job, labels, metrics)
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/healthchecks"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/uptimekuma"
+)
+
+// monitorTimeout 是向外部监控服务报告一次运行状态的超时时间。
+const monitorTimeout = 10 * time.Second
+
+// monitorHook 在一次同步开始和结束时向配置的外部监控服务报告状态。
+// 报告失败只记录日志，不影响同步结果。
+type monitorHook struct {
+	NopHook
+	log        logger.Logger
+	config     Config
+	httpClient *http.Client
+	runID      string
+	isFullSync bool
+}
+
+func newMonitorHook(log logger.Logger, config Config, httpClient *http.Client) *monitorHook {
+	return &monitorHook{log: log, config: config, httpClient: httpClient, runID: healthchecks.NewRunID()}
+}
+
+// OnSyncStarted 报告同步开始。
+func (m *monitorHook) OnSyncStarted(ctx context.Context, isFullSync bool) error {
+	m.isFullSync = isFullSync
+	if m.config.HealthchecksURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), monitorTimeout)
+	defer cancel()
+	return healthchecks.Ping(ctx, m.httpClient, m.config.HealthchecksURL, healthchecks.Start, m.runID, "")
+}
+
+// OnComplete 报告同步结果：Healthchecks 的请求体中附上运行摘要，Uptime Kuma 推送状态、一行消息和耗时。
+// 使用不随 ctx 取消的 context，确保收到退出信号后仍能报告最后一次的结果。
+func (m *monitorHook) OnComplete(ctx context.Context, plan *Plan, result Result) error {
+	if plan != nil {
+		m.isFullSync = plan.IsFullSync
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), monitorTimeout)
+	defer cancel()
+
+	if m.config.HealthchecksURL != "" {
+		signal := healthchecks.Success
+		if !result.Success {
+			signal = healthchecks.Fail
+		}
+		if err := healthchecks.Ping(ctx, m.httpClient, m.config.HealthchecksURL, signal, m.runID, m.summary(result)); err != nil {
+			m.log.Warn("  -> ⚠️ %v", err)
+		}
+	}
+	if m.config.UptimeKumaURL != "" {
+		status := uptimekuma.Up
+		if !result.Success {
+			status = uptimekuma.Down
+		}
+		if err := uptimekuma.Push(ctx, m.httpClient, m.config.UptimeKumaURL, status, m.headline(result), result.Duration); err != nil {
+			m.log.Warn("  -> ⚠️ %v", err)
+		}
+	}
+	return nil
+}
+
+// headline 返回一行的运行摘要，用于只显示单行消息的监控服务。
+func (m *monitorHook) headline(result Result) string {
+	if !result.Success {
+		return fmt.Sprintf("%s失败: %s", m.mode(), result.Message)
+	}
+	return fmt.Sprintf("%s成功: 上传 %d, 删除 %d", m.mode(), result.Uploaded, result.Deleted)
+}
+
+// summary 返回一次同步的文本摘要。
+func (m *monitorHook) summary(result Result) string {
+	status := "成功"
+	if !result.Success {
+		status = "失败"
+	}
+	return fmt.Sprintf("%s%s: %s\n耗时: %s\n上传: %d (%s), 删除: %d, 失败: %d\nNodeImage: %d 个文件 (%s)\nWebDAV: %d 个文件 (%s)\n",
+		m.mode(), status, result.Message, result.Duration.Round(time.Second),
+		result.Uploaded, formatBytes(result.UploadSize), result.Deleted, result.Failed,
+		result.TotalNodeImageFiles, formatBytes(result.TotalNodeImageSize),
+		result.TotalWebDAVFiles, formatBytes(result.TotalWebDAVSize))
+}
+
+// mode 返回本次同步的模式名称。
+func (m *monitorHook) mode() string {
+	if m.isFullSync {
+		return "全量同步"
+	}
+	return "增量同步"
+}
@@ -0,0 +1,27 @@
+package sync
+
+import "golang.org/x/text/unicode/norm"
+
+// 文件名的 Unicode 规范化方式，决定对比两侧文件名之前如何处理组合字符。
+// 基于 macOS 文件系统的 WebDAV 服务会以 NFD 形式返回文件名（例如 "é" 被拆成 "e" 和组合重音符），
+// 不规范化时这些文件永远对应不上，每次同步都会重新上传，全量同步还会删除"多余"的原文件。
+const (
+	UnicodeNFC = "nfc" // 两侧文件名都规范化为 NFC 后再对比（默认）
+	UnicodeOff = "off" // 按原始字节对比
+)
+
+// normalizer 返回对比文件名时使用的规范化函数，不规范化时返回 nil。
+func (c Config) normalizer() func(string) string {
+	if c.UnicodeNorm == UnicodeOff {
+		return nil
+	}
+	return norm.NFC.String
+}
+
+// normalized 用 normalize 规范化 name，normalize 为 nil 时原样返回。
+func normalized(normalize func(string) string, name string) string {
+	if normalize == nil {
+		return name
+	}
+	return normalize(name)
+}
@@ -0,0 +1,66 @@
+package sync
+
+import "sync"
+
+// 执行阶段的操作类型。
+const (
+	OpUpload = "upload"
+	OpDelete = "delete"
+	OpRename = "rename"
+)
+
+// Progress 描述执行阶段的实时进度，每完成一个上传或删除操作报告一次。
+type Progress struct {
+	Op      string `json:"op"`      // 操作类型：OpUpload、OpDelete 或 OpRename
+	File    string `json:"file"`    // 本次完成的文件名
+	Success bool   `json:"success"` // 本次操作是否成功
+	Done    int    `json:"done"`    // 已完成（含失败）的操作数
+	Total   int    `json:"total"`   // 计划中的操作总数
+}
+
+// ProgressFunc 是接收进度报告的回调。它会被串行调用，无需自行加锁。
+type ProgressFunc func(Progress)
+
+// tracker 以并发安全的方式统计执行阶段各类操作的成功和失败次数，并转发进度。
+type tracker struct {
+	mutex        sync.Mutex
+	total        int
+	uploaded     int
+	deleted      int
+	uploadFailed int
+	deleteFailed int
+	renamed      int
+	renameFailed int
+	onProgress   ProgressFunc
+}
+
+// record 记录一个操作的结果。
+func (t *tracker) record(op, file string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch {
+	case op == OpUpload && err == nil:
+		t.uploaded++
+	case op == OpUpload:
+		t.uploadFailed++
+	case op == OpRename && err == nil:
+		t.renamed++
+	case op == OpRename:
+		t.renameFailed++
+	case err == nil:
+		t.deleted++
+	default:
+		t.deleteFailed++
+	}
+
+	if t.onProgress != nil {
+		t.onProgress(Progress{
+			Op:      op,
+			File:    file,
+			Success: err == nil,
+			Done:    t.uploaded + t.uploadFailed + t.deleted + t.deleteFailed + t.renamed + t.renameFailed,
+			Total:   t.total,
+		})
+	}
+}
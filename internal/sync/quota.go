@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// 空间不足时的处理方式。
+const (
+	QuotaAbort = "abort" // 不执行任何操作，直接报错（默认）
+	QuotaTrim  = "trim"  // 只上传放得下的文件，其余留到下次同步，本次同步记为失败
+	QuotaOff   = "off"   // 不检查配额
+)
+
+// checkQuota 在开始上传前比较计划上传的大小和 WebDAV 的剩余空间（需扣除 MinFreeSpace）。
+// 服务器未报告配额或查询失败时不做限制。空间不足时按 QuotaAction 中止或裁剪计划：
+// 返回的计划只包含放得下的上传，skipped 为被跳过的上传数；中止时返回错误。
+// 同一计划中的删除与上传并发执行，因此计算时不把删除释放的空间算在内。
+func checkQuota(ctx context.Context, log logger.Logger, wd *webdav.Client, config Config, plan *Plan) (*Plan, int, error) {
+	if config.QuotaAction == QuotaOff || len(plan.Uploads) == 0 {
+		return plan, 0, nil
+	}
+	quota, ok, err := wd.GetQuota(ctx, config.WebdavBasePath)
+	if err != nil {
+		log.Warn("  -> ⚠️ 无法查询 WebDAV 存储配额，将不检查剩余空间: %v", err)
+		return plan, 0, nil
+	}
+	if !ok {
+		log.Debug("  -> WebDAV 服务器未报告存储配额，不检查剩余空间")
+		return plan, 0, nil
+	}
+
+	budget := quota.Available - config.MinFreeSpace
+	if plan.UploadSize <= budget {
+		log.Debug("  -> WebDAV 剩余空间 %s，计划上传 %s", formatBytes(quota.Available), formatBytes(plan.UploadSize))
+		return plan, 0, nil
+	}
+
+	if config.QuotaAction != QuotaTrim {
+		return plan, 0, fmt.Errorf("WebDAV 剩余空间不足: 计划上传 %s，剩余 %s（需保留 %s），同步已中止",
+			formatBytes(plan.UploadSize), formatBytes(quota.Available), formatBytes(config.MinFreeSpace))
+	}
+
+	// 按计划顺序保留放得下的文件，跳过放不下的文件后继续尝试更小的文件
+	trimmed := *plan
+	trimmed.Uploads = make([]nodeimage.ImageInfo, 0, len(plan.Uploads))
+	trimmed.UploadSize = 0
+	for _, file := range plan.Uploads {
+		if trimmed.UploadSize+file.Size > budget {
+			continue
+		}
+		trimmed.Uploads = append(trimmed.Uploads, file)
+		trimmed.UploadSize += file.Size
+	}
+	skipped := len(plan.Uploads) - len(trimmed.Uploads)
+	log.Warn("  -> ⚠️ WebDAV 剩余空间不足: 计划上传 %s，剩余 %s（需保留 %s），本次只上传 %d 张 (%s)，跳过 %d 张",
+		formatBytes(plan.UploadSize), formatBytes(quota.Available), formatBytes(config.MinFreeSpace),
+		len(trimmed.Uploads), formatBytes(trimmed.UploadSize), skipped)
+	return &trimmed, skipped, nil
+}
@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// NewHTTPClient 创建所有入口（Web UI、命令行、Serverless）共用配置的 HTTP 客户端。
+// timeout 为单个请求的超时时间，0 表示不限制。
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		Timeout: timeout,
+	}
+}
+
+// retryBaseDelay 是第一次重试前的等待时间，之后每次翻倍。
+const retryBaseDelay = time.Second
+
+// withRetry 执行 fn，失败时按指数退避最多重试 retries 次。
+// context 被取消时立即返回最后一次的错误。
+func withRetry(ctx context.Context, log logger.Logger, retries int, name string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("  -> ⚠️ %s 失败，%s 后重试 (%d/%d): %v", name, delay, attempt+1, retries, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// withTimeout 在一个带超时的子 context 中执行一次操作。
+// 超时后操作的请求会被取消，使单个卡住的请求（例如服务器不再响应的 PUT）无法拖住整个同步及其同步锁。
+// timeout 为 0 时不设超时。
+func withTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(opCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("操作超过 %s 未完成: %w", timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+)
+
+// retryFileVersion 是重试队列文件格式的版本，格式不兼容时忽略旧文件。
+const retryFileVersion = 1
+
+// RetryEntry 是重试队列中的一个文件：它在某次同步中重试耗尽后仍上传失败。
+// 增量同步只能看到最近的图片，失败的文件在之后的增量同步中可能不再出现，
+// 因此记录下来，由之后的每次同步优先重试，直到上传成功或文件已不再需要上传。
+type RetryEntry struct {
+	File          nodeimage.ImageInfo `json:"file"`
+	Failures      int                 `json:"failures"` // 上传失败的同步次数
+	LastError     string              `json:"lastError"`
+	FirstFailedAt time.Time           `json:"firstFailedAt"`
+	LastFailedAt  time.Time           `json:"lastFailedAt"`
+}
+
+// retryFile 是重试队列文件的内容。
+type retryFile struct {
+	Version int                              `json:"version"`
+	Targets map[string]map[string]RetryEntry `json:"targets"` // 同步目标（见 cacheKey）-> 文件名 -> 记录
+}
+
+// retryMutex 串行化本进程内对重试队列文件的读写。
+var retryMutex sync.Mutex
+
+// readRetries 读取重试队列文件。文件不存在或格式版本不同时返回空队列。
+func readRetries(file string) (*retryFile, error) {
+	queue := &retryFile{Version: retryFileVersion, Targets: make(map[string]map[string]RetryEntry)}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return queue, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取重试队列失败: %w", err)
+	}
+	var saved retryFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("解析重试队列失败: %w", err)
+	}
+	if saved.Version != retryFileVersion || saved.Targets == nil {
+		return queue, nil
+	}
+	return &saved, nil
+}
+
+// loadRetries 返回同步目标 key 的重试队列。
+func loadRetries(file, key string) (map[string]RetryEntry, error) {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	queue, err := readRetries(file)
+	if err != nil {
+		return nil, err
+	}
+	return queue.Targets[key], nil
+}
+
+// updateRetries 读取同步目标 key 的重试队列，交给 fn 修改，fn 返回 true 时写回文件。
+func updateRetries(file, key string, fn func(entries map[string]RetryEntry) bool) error {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	queue, err := readRetries(file)
+	if err != nil {
+		return err
+	}
+	entries := queue.Targets[key]
+	if entries == nil {
+		entries = make(map[string]RetryEntry)
+	}
+	if !fn(entries) {
+		return nil
+	}
+	if len(entries) == 0 {
+		delete(queue.Targets, key)
+	} else {
+		queue.Targets[key] = entries
+	}
+
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化重试队列失败: %w", err)
+	}
+	if err := writeFileAtomic(file, data); err != nil {
+		return fmt.Errorf("保存重试队列失败: %w", err)
+	}
+	return nil
+}
+
+// Retries 返回同步目标的重试队列，按首次失败的时间排序。未配置重试队列文件时返回空列表。
+func Retries(config Config) ([]RetryEntry, error) {
+	result := []RetryEntry{}
+	if config.RetryFile == "" {
+		return result, nil
+	}
+	entries, err := loadRetries(config.RetryFile, config.withDefaults().cacheKey())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].FirstFailedAt.Equal(result[j].FirstFailedAt) {
+			return result[i].FirstFailedAt.Before(result[j].FirstFailedAt)
+		}
+		return result[i].File.Filename < result[j].File.Filename
+	})
+	return result, nil
+}
+
+// ClearRetries 清空同步目标的重试队列，放弃重试其中的文件。返回被移除的文件数。
+func ClearRetries(config Config) (int, error) {
+	if config.RetryFile == "" {
+		return 0, nil
+	}
+	cleared := 0
+	err := updateRetries(config.RetryFile, config.withDefaults().cacheKey(), func(entries map[string]RetryEntry) bool {
+		cleared = len(entries)
+		clear(entries)
+		return cleared > 0
+	})
+	return cleared, err
+}
+
+// queuedRetries 将重试队列中的文件加入上传列表的最前面，使它们先于其他文件上传。
+// 已存在于 WebDAV 上（按文件名，或按 ID 对应时按图片 ID）的文件不再需要上传，会从队列中移除；
+// 全量同步能看到全部图片，已不在 NodeImage 上（或不在同步范围内）的文件同样被移除。
+// 已在 toUpload 中的文件只调整顺序，不会重复上传。
+func queuedRetries(log logger.Logger, config Config, isFullSync bool, nodeImageFiles []nodeimage.ImageInfo, webdavFiles []string, ids map[string]string, toUpload []nodeimage.ImageInfo) []nodeimage.ImageInfo {
+	if config.RetryFile == "" {
+		return toUpload
+	}
+	key := config.cacheKey()
+	entries, err := loadRetries(config.RetryFile, key)
+	if err != nil {
+		log.Warn("  -> ⚠️ %v", err)
+		return toUpload
+	}
+	if len(entries) == 0 {
+		return toUpload
+	}
+
+	normalize := config.normalizer()
+	existing := make(map[string]bool, len(webdavFiles))
+	for _, p := range webdavFiles {
+		existing[normalized(normalize, path.Base(p))] = true
+	}
+	storedIDs := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		storedIDs[id] = true
+	}
+	planned := make(map[string]int, len(toUpload))
+	for i, file := range toUpload {
+		planned[normalized(normalize, file.Filename)] = i
+	}
+	current := make(map[string]bool, len(nodeImageFiles))
+	for _, file := range nodeImageFiles {
+		current[normalized(normalize, file.Filename)] = true
+	}
+
+	var stale []string
+	var queued []RetryEntry
+	moved := make(map[int]bool)
+	for name, entry := range entries {
+		n := normalized(normalize, name)
+		switch i, ok := planned[n]; {
+		case ok:
+			entry.File = toUpload[i]
+			queued = append(queued, entry)
+			moved[i] = true
+		case existing[n] || (entry.File.ID != "" && storedIDs[entry.File.ID]) || (isFullSync && !current[n]):
+			stale = append(stale, name)
+		default:
+			queued = append(queued, entry)
+		}
+	}
+
+	if len(stale) > 0 {
+		log.Info("  -> [重试] %d 个此前上传失败的文件已不再需要上传，从重试队列中移除", len(stale))
+		if !config.DryRun {
+			err := updateRetries(config.RetryFile, key, func(entries map[string]RetryEntry) bool {
+				for _, name := range stale {
+					delete(entries, name)
+				}
+				return true
+			})
+			if err != nil {
+				log.Warn("  -> ⚠️ %v", err)
+			}
+		}
+	}
+	if len(queued) == 0 {
+		return toUpload
+	}
+	log.Info("  -> [重试] 优先上传 %d 个此前上传失败的文件", len(queued))
+	sort.Slice(queued, func(i, j int) bool { return queued[i].FirstFailedAt.Before(queued[j].FirstFailedAt) })
+	result := make([]nodeimage.ImageInfo, 0, len(toUpload)+len(queued))
+	for _, entry := range queued {
+		result = append(result, entry.File)
+	}
+	for i, file := range toUpload {
+		if !moved[i] {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// retryHook 在执行阶段维护重试队列：重试耗尽后仍上传失败的文件加入队列，上传成功的文件从队列中移除，
+// 并在结束时写入。
+type retryHook struct {
+	NopHook
+	file     string
+	key      string
+	failed   map[string]failedUpload
+	uploaded map[string]bool
+}
+
+// failedUpload 是本次同步中一个上传失败的文件。
+type failedUpload struct {
+	file nodeimage.ImageInfo
+	err  error
+	at   time.Time
+}
+
+func newRetryHook(config Config) *retryHook {
+	return &retryHook{file: config.RetryFile, key: config.cacheKey(), failed: make(map[string]failedUpload), uploaded: make(map[string]bool)}
+}
+
+func (h *retryHook) OnFileUploaded(_ context.Context, file nodeimage.ImageInfo, _ string) error {
+	h.uploaded[file.Filename] = true
+	return nil
+}
+
+func (h *retryHook) OnUploadFailed(_ context.Context, file nodeimage.ImageInfo, err error) error {
+	h.failed[file.Filename] = failedUpload{file: file, err: err, at: time.Now()}
+	return nil
+}
+
+func (h *retryHook) OnComplete(context.Context, *Plan, Result) error {
+	if len(h.failed) == 0 && len(h.uploaded) == 0 {
+		return nil
+	}
+	return updateRetries(h.file, h.key, func(entries map[string]RetryEntry) bool {
+		changed := false
+		for name := range h.uploaded {
+			if _, ok := entries[name]; ok {
+				delete(entries, name)
+				changed = true
+			}
+		}
+		for name, f := range h.failed {
+			entry, ok := entries[name]
+			if !ok {
+				entry.FirstFailedAt = f.at
+			}
+			entry.File = f.file
+			entry.Failures++
+			entry.LastError = f.err.Error()
+			entry.LastFailedAt = f.at
+			entries[name] = entry
+			changed = true
+		}
+		return changed
+	})
+}
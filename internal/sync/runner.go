@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// ConfigFromApp 将应用级配置转换为同步引擎所需的配置。
+// 所有入口（Web UI、命令行）都应通过它构建配置，避免各自映射字段导致行为不一致。
+func ConfigFromApp(cfg config.Config) Config {
+	return Config{
+		NodeImageCookie: cfg.NodeImageCookie,
+		NodeImageAPIKey: cfg.NodeImageAPIKey,
+		NodeImageAPIURL: cfg.NodeImageAPIURL,
+		WebdavURL:       cfg.WebdavURL,
+		WebdavUsername:  cfg.WebdavUsername,
+		WebdavPassword:  cfg.WebdavPassword,
+		WebdavBasePath:  cfg.WebdavBasePath,
+		SyncConcurrency: cfg.SyncConcurrency,
+		DeleteWorkers:   cfg.DeleteWorkers,
+		AutoConcurrency: cfg.AutoConcurrency,
+		SyncRetries:     cfg.SyncRetries,
+		OpTimeout:       time.Duration(cfg.OpTimeout) * time.Second,
+		VerifyUploads:   cfg.VerifyUploads,
+		QuotaAction:     cfg.QuotaAction,
+		MinFreeSpace:    int64(cfg.MinFreeMB) << 20,
+		DeleteMode:      cfg.DeleteMode,
+		MatchBy:         cfg.MatchBy,
+		KeepVersions:    cfg.KeepVersions,
+		VersionMaxAge:   time.Duration(cfg.VersionMaxAge) * 24 * time.Hour,
+		Snapshots:       cfg.Snapshots,
+		Scope:           cfg.Scope,
+		HealthchecksURL: cfg.HealthchecksURL,
+		UptimeKumaURL:   cfg.UptimeKumaURL,
+		UnicodeNorm:     cfg.UnicodeNorm,
+		DeleteScope:     cfg.DeleteScope,
+		IndexFile:       cfg.SyncIndexFile,
+		VerifyOlderThan: time.Duration(cfg.VerifyOlderDays) * 24 * time.Hour,
+		RetryFile:       cfg.SyncRetryFile,
+	}
+}
+
+// Runner 保证同一时刻只有一个同步任务在运行，并将任务中的 panic 转换为失败结果。
+// Web UI 的定时任务、手动触发以及命令行的常驻模式共享同样的加锁语义。
+type Runner struct {
+	mutex sync.Mutex
+}
+
+// TryRun 尝试执行一次同步。如果已有同步在运行，则不执行并返回 false。
+func (r *Runner) TryRun(ctx context.Context, log logger.Logger, config Config, isFullSync bool, httpClient *http.Client) (Result, bool) {
+	return r.TryDo(log, func() Result {
+		return RunSync(ctx, log, config, isFullSync, httpClient)
+	})
+}
+
+// TryDo 在持有同步锁的情况下执行任意同步函数（例如分批同步）。
+// 如果已有同步在运行，则不执行并返回 false。
+func (r *Runner) TryDo(log logger.Logger, fn func() Result) (result Result, ran bool) {
+	if !r.mutex.TryLock() {
+		return Result{}, false
+	}
+	defer r.mutex.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.Error("捕获到未处理的 panic: %v", p)
+			err := fmt.Errorf("同步过程中发生 panic: %v", p)
+			result, ran = Result{Success: false, Message: err.Error(), Error: err}, true
+		}
+	}()
+
+	return fn(), true
+}
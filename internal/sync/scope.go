@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+)
+
+// maxScopePages 是从站点地图中最多抓取的页面数，防止误配置的站点地图导致无限抓取。
+const maxScopePages = 2000
+
+// maxScopeBytes 是单个范围来源（文件或页面）最多读取的字节数。
+const maxScopeBytes = 16 << 20
+
+var (
+	urlPattern = regexp.MustCompile(`https?://[^\s"'<>()\[\]{}\\]+`)
+	locPattern = regexp.MustCompile(`<loc>\s*([^<\s]+)\s*</loc>`)
+)
+
+// scope 是一组被引用的图片，用于把同步范围限制在实际发布的图片上。
+type scope struct {
+	urls      map[string]bool
+	filenames map[string]bool
+}
+
+// contains 判断图片是否被引用：直链完全匹配，或任意引用链接的文件名与之相同（兼容 CDN 域名等变体）。
+func (s *scope) contains(file nodeimage.ImageInfo) bool {
+	return s.urls[file.URL] || s.filenames[file.Filename]
+}
+
+// add 从一段文本中提取所有链接。
+func (s *scope) add(text string) {
+	for _, raw := range urlPattern.FindAllString(text, -1) {
+		s.urls[raw] = true
+		if u, err := url.Parse(raw); err == nil {
+			if name := path.Base(u.Path); name != "/" && name != "." {
+				s.filenames[name] = true
+			}
+		}
+	}
+}
+
+// loadScope 读取范围来源并提取其中引用的所有链接。来源可以是本地文件路径或 http(s) 地址，内容可以是：
+//   - 链接列表、Markdown/HTML 文章导出等任意文本，直接从中提取链接；
+//   - XML 站点地图（urlset 或 sitemapindex），会继续抓取其中列出的每个页面（以及子站点地图）并提取链接。
+func loadScope(ctx context.Context, log logger.Logger, source string, httpClient *http.Client) (*scope, error) {
+	s := &scope{urls: make(map[string]bool), filenames: make(map[string]bool)}
+	text, err := readScopeSource(ctx, source, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := sitemapLocs(text)
+	if pending == nil {
+		s.add(text)
+		return s, nil
+	}
+
+	log.Info("  -> [范围] 正在抓取站点地图中的页面...")
+	visited := make(map[string]bool)
+	for len(pending) > 0 && len(visited) < maxScopePages {
+		page := pending[0]
+		pending = pending[1:]
+		if visited[page] {
+			continue
+		}
+		visited[page] = true
+
+		body, err := readScopeSource(ctx, page, httpClient)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn("  -> ⚠️ 抓取页面失败，已跳过: %v", err)
+			continue
+		}
+		if locs := sitemapLocs(body); locs != nil {
+			pending = append(pending, locs...) // 站点地图索引中的子站点地图
+			continue
+		}
+		s.add(body)
+	}
+	if len(pending) > 0 {
+		log.Warn("  -> ⚠️ 站点地图页面超过 %d 个，其余页面已忽略", maxScopePages)
+	}
+	return s, nil
+}
+
+// sitemapLocs 如果 text 是 XML 站点地图，返回其中的所有 <loc> 地址；否则返回 nil。
+func sitemapLocs(text string) []string {
+	if !strings.Contains(text, "<urlset") && !strings.Contains(text, "<sitemapindex") {
+		return nil
+	}
+	locs := []string{}
+	for _, m := range locPattern.FindAllStringSubmatch(text, -1) {
+		locs = append(locs, strings.ReplaceAll(m[1], "&amp;", "&"))
+	}
+	return locs
+}
+
+// readScopeSource 读取本地文件或 http(s) 地址的内容。
+func readScopeSource(ctx context.Context, source string, httpClient *http.Client) (string, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return "", fmt.Errorf("无法读取范围文件: %w", err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxScopeBytes))
+		if err != nil {
+			return "", fmt.Errorf("无法读取范围文件: %w", err)
+		}
+		return string(data), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", fmt.Errorf("无效的范围地址 '%s': %w", source, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("下载 '%s' 失败: %w", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("下载 '%s' 失败，状态码: %d", source, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxScopeBytes))
+	if err != nil {
+		return "", fmt.Errorf("读取 '%s' 失败: %w", source, err)
+	}
+	return string(data), nil
+}
+
+// filterScope 只保留被引用的图片。
+func filterScope(files []nodeimage.ImageInfo, s *scope) []nodeimage.ImageInfo {
+	var inScope []nodeimage.ImageInfo
+	for _, file := range files {
+		if s.contains(file) {
+			inScope = append(inScope, file)
+		}
+	}
+	return inScope
+}
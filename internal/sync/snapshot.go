@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// manifestDirName 是快照清单在状态目录下的子目录。
+const manifestDirName = "manifests"
+
+// SnapshotEntry 是快照清单中的一个文件。
+type SnapshotEntry struct {
+	ID         string `json:"imageId"`
+	Filename   string `json:"filename"`
+	Size       int64  `json:"size"`
+	UploadTime string `json:"uploadTime,omitempty"`
+}
+
+// manifest 是一次快照同步结束时写入的清单，记录当时 NodeImage 上的全部文件。
+// 快照模式下同步目录中的文件只增不删，因此根据任意一份清单都能还原出当时的图片集合。
+type manifest struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Files     []SnapshotEntry `json:"files"`
+}
+
+// snapshotOf 根据 NodeImage 的文件列表生成快照清单的文件项。
+func snapshotOf(files []nodeimage.ImageInfo) []SnapshotEntry {
+	entries := make([]SnapshotEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, SnapshotEntry{ID: f.ID, Filename: f.Filename, Size: f.Size, UploadTime: f.UploadTime})
+	}
+	return entries
+}
+
+// manifestPath 返回在 t 时刻写入的快照清单在 WebDAV 上的路径。
+func manifestPath(basePath string, t time.Time) string {
+	return path.Join(basePath, stateDirName, manifestDirName, t.Format(versionTimeLayout)+".json")
+}
+
+// writeManifest 将快照清单写入 WebDAV，返回清单路径。
+func writeManifest(ctx context.Context, client *webdav.Client, basePath string, entries []SnapshotEntry) (string, error) {
+	now := time.Now()
+	data, err := json.MarshalIndent(manifest{CreatedAt: now, Files: entries}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化快照清单失败: %w", err)
+	}
+	if err := client.MakeDir(ctx, path.Join(basePath, stateDirName)); err != nil {
+		return "", err
+	}
+	if err := client.MakeDir(ctx, path.Join(basePath, stateDirName, manifestDirName)); err != nil {
+		return "", err
+	}
+	p := manifestPath(basePath, now)
+	return p, client.UploadFile(ctx, p, data)
+}
+
+// snapshotHook 在快照模式的全量同步结束时写入计划中的快照清单。
+// 只有计划中的文件全部上传成功（没有失败，也没有因空间不足被跳过）时，清单才能准确描述这一时刻的图片集合，才会写入。
+type snapshotHook struct {
+	NopHook
+	log      logger.Logger
+	client   *webdav.Client
+	basePath string
+	uploaded int
+}
+
+func newSnapshotHook(log logger.Logger, client *webdav.Client, basePath string) *snapshotHook {
+	return &snapshotHook{log: log, client: client, basePath: basePath}
+}
+
+func (h *snapshotHook) OnFileUploaded(context.Context, nodeimage.ImageInfo, string) error {
+	h.uploaded++
+	return nil
+}
+
+func (h *snapshotHook) OnComplete(ctx context.Context, plan *Plan, _ Result) error {
+	if plan == nil || plan.Snapshot == nil || h.uploaded != len(plan.Uploads) {
+		return nil
+	}
+	p, err := writeManifest(ctx, h.client, h.basePath, plan.Snapshot)
+	if err != nil {
+		return fmt.Errorf("写入快照清单失败: %w", err)
+	}
+	h.log.Info("  -> ✅ 已写入快照清单: %s (%d 个文件)", path.Base(p), len(plan.Snapshot))
+	return nil
+}
@@ -0,0 +1,214 @@
+package sync
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// stateArchiveVersion 是状态归档的格式版本，导入时用于拒绝不兼容的归档。
+const stateArchiveVersion = 1
+
+// maxStateFileSize 是导入时单个状态文件的大小上限。
+const maxStateFileSize = 64 << 20
+
+// 状态归档中的条目名称。
+const (
+	stateMetaEntry    = "state.json"
+	stateCacheEntry   = "cache/webdav-listing.json"
+	stateWebdavPrefix = "webdav/"
+)
+
+// stateSubdirs 是状态目录下需要导出的子目录（"" 表示状态目录本身）。
+var stateSubdirs = []string{"", manifestDirName}
+
+// stateMeta 描述一份状态归档的来源。
+type stateMeta struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"createdAt"`
+	WebdavURL  string    `json:"webdavUrl"`
+	BasePath   string    `json:"basePath"`
+	StateFiles int       `json:"stateFiles"`
+}
+
+// ExportState 将同步引擎的状态（WebDAV 状态目录中的分批游标和快照清单，以及 WebDAV 文件列表缓存）
+// 打包为一个 tar.gz 归档写入 w，以便在另一台主机上通过 ImportState 恢复。
+func ExportState(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client, w io.Writer) error {
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		return fmt.Errorf("WebDAV 配置未完全设置")
+	}
+	config = config.withDefaults()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+	stateDir := path.Join(config.WebdavBasePath, stateDirName)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	meta := stateMeta{Version: stateArchiveVersion, CreatedAt: time.Now(), WebdavURL: config.WebdavURL, BasePath: config.WebdavBasePath}
+
+	for _, sub := range stateSubdirs {
+		dir := path.Join(stateDir, sub)
+		files, err := webdavClient.ListFiles(ctx, dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("列出状态目录失败: %w", err)
+		}
+		for _, p := range files {
+			data, err := webdavClient.ReadFile(ctx, p)
+			if err != nil {
+				return fmt.Errorf("读取状态文件失败: %w", err)
+			}
+			name := stateWebdavPrefix + path.Join(sub, path.Base(p))
+			if err := writeTarEntry(tw, name, data); err != nil {
+				return err
+			}
+			meta.StateFiles++
+		}
+	}
+
+	if infos, ok := config.listingCache().Load(ctx, config.cacheKey()); ok {
+		data, err := json.Marshal(infos)
+		if err != nil {
+			return fmt.Errorf("序列化文件列表缓存失败: %w", err)
+		}
+		if err := writeTarEntry(tw, stateCacheEntry, data); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化状态元数据失败: %w", err)
+	}
+	if err := writeTarEntry(tw, stateMetaEntry, data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("写入状态归档失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("写入状态归档失败: %w", err)
+	}
+	log.Info("  -> ✅ 已导出 %d 个状态文件", meta.StateFiles)
+	return nil
+}
+
+// ImportState 从 ExportState 生成的归档中恢复状态：状态文件写回当前 WebDAV 同步目录下的状态目录，
+// 文件列表缓存仅在同步目录与导出时相同时恢复（否则其中的路径已不再有效）。
+// 返回恢复的状态文件数。
+func ImportState(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client, r io.Reader) (int, error) {
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		return 0, fmt.Errorf("WebDAV 配置未完全设置")
+	}
+	config = config.withDefaults()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+	stateDir := path.Join(config.WebdavBasePath, stateDirName)
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("无效的状态归档: %w", err)
+	}
+	defer gz.Close()
+
+	// 先完整读取并校验归档，避免导入一半时才发现归档不兼容
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("无效的状态归档: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxStateFileSize+1))
+		if err != nil {
+			return 0, fmt.Errorf("读取状态归档失败: %w", err)
+		}
+		if len(data) > maxStateFileSize {
+			return 0, fmt.Errorf("状态文件 %q 过大", header.Name)
+		}
+		entries[header.Name] = data
+	}
+
+	var meta stateMeta
+	if err := json.Unmarshal(entries[stateMetaEntry], &meta); err != nil {
+		return 0, fmt.Errorf("状态归档缺少有效的 %s", stateMetaEntry)
+	}
+	if meta.Version != stateArchiveVersion {
+		return 0, fmt.Errorf("不支持的状态归档版本: %d", meta.Version)
+	}
+
+	imported := 0
+	madeDirs := make(map[string]bool)
+	for name, data := range entries {
+		if !strings.HasPrefix(name, stateWebdavPrefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(name, stateWebdavPrefix)
+		sub, file := path.Split(rel)
+		sub = strings.TrimSuffix(sub, "/")
+		if !slices.Contains(stateSubdirs, sub) || file == "" || file == "." || file == ".." {
+			return imported, fmt.Errorf("状态归档包含无效的路径: %q", name)
+		}
+
+		for _, dir := range []string{stateDir, path.Join(stateDir, sub)} {
+			if madeDirs[dir] {
+				continue
+			}
+			if err := webdavClient.MakeDir(ctx, dir); err != nil {
+				return imported, err
+			}
+			madeDirs[dir] = true
+		}
+		if err := webdavClient.UploadFile(ctx, path.Join(stateDir, sub, file), data); err != nil {
+			return imported, fmt.Errorf("写入状态文件失败: %w", err)
+		}
+		imported++
+	}
+
+	if data, ok := entries[stateCacheEntry]; ok {
+		if path.Clean(meta.BasePath) != path.Clean(config.WebdavBasePath) {
+			log.Warn("  -> ⚠️ 同步目录已从 %s 变为 %s，跳过文件列表缓存", meta.BasePath, config.WebdavBasePath)
+		} else {
+			var infos []webdav.FileInfo
+			if err := json.Unmarshal(data, &infos); err != nil {
+				return imported, fmt.Errorf("无效的文件列表缓存: %w", err)
+			}
+			config.listingCache().Store(ctx, config.cacheKey(), infos)
+		}
+	}
+
+	log.Info("  -> ✅ 已导入 %d 个状态文件 (导出于 %s)", imported, meta.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	return imported, nil
+}
+
+// writeTarEntry 向 tar 归档写入一个普通文件。
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入状态归档失败: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("写入状态归档失败: %w", err)
+	}
+	return nil
+}
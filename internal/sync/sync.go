@@ -5,31 +5,21 @@ package sync
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
-	"nodeimage_webdav_webui/pkg/logger"
-	"nodeimage_webdav_webui/pkg/nodeimage"
-	"nodeimage_webdav_webui/pkg/stats"
-	"nodeimage_webdav_webui/pkg/webdav"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/diff"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
 )
 
-// --- WebDAV 列表缓存 ---
-
-var (
-	webdavCache []webdav.FileInfo
-	cacheMutex  sync.RWMutex
-)
-
-// InvalidateWebdavCache 用于在文件系统发生变化（上传或删除）后清空缓存。
-func InvalidateWebdavCache() {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
-	webdavCache = nil
-}
-
 // --- 同步逻辑 ---
 
 // Config 聚合了执行一次同步所需的所有配置项。
@@ -41,7 +31,50 @@ type Config struct {
 	WebdavUsername  string
 	WebdavPassword  string
 	WebdavBasePath  string
-	SyncConcurrency int
+	SyncConcurrency int           // 并发上限
+	DeleteWorkers   int           // 删除、清理和重命名的并发上限，独立于上传；0 表示与 SyncConcurrency 相同
+	AutoConcurrency bool          // 自适应并发：遇到限流或超时时自动降低并发，恢复后再逐步提高
+	SyncRetries     int           // 单个上传/删除失败后的重试次数
+	OpTimeout       time.Duration // 单次下载/上传/删除尝试的超时时间，0 表示不限制
+	VerifyUploads   bool          // 上传后确认文件已以预期大小存在于 WebDAV 上，才计为成功
+	QuotaAction     string        // WebDAV 剩余空间不足时的处理方式：QuotaAbort（默认）、QuotaTrim 或 QuotaOff
+	MinFreeSpace    int64         // 上传后 WebDAV 上至少需要保留的剩余空间（字节）
+	DeleteMode      string        // 删除模式：DeleteModeDelete（默认）或 DeleteModeVersion
+	KeepVersions    int           // 每个文件最多保留的旧版本数，0 表示不限
+	VersionMaxAge   time.Duration // 旧版本的最长保留时间，0 表示不限
+	Snapshots       bool          // 快照模式：不删除任何文件，每次全量同步结束后写入一份清单
+	Scope           string        // 可选，链接列表、文章导出或站点地图的路径/地址，只同步其中引用的图片
+	DryRun          bool          // 演练模式：只输出计划，不执行任何上传或删除
+	OnProgress      ProgressFunc  // 可选，每完成一个上传或删除操作时被调用
+	Cache           ListingCache  // 可选，WebDAV 文件列表缓存，默认使用进程内缓存
+	MatchBy         string        // 文件对应方式：MatchByName（默认）或 MatchByID
+	Hooks           []Hook        // 可选，仅对本次同步生效的扩展，在 RegisterHook 注册的扩展之后调用
+	HealthchecksURL string        // 可选，Healthchecks.io 的 ping 地址，同步开始和结束时发送 ping
+	UptimeKumaURL   string        // 可选，Uptime Kuma Push 监控的地址，同步结束时推送状态
+	UnicodeNorm     string        // 对比前文件名的 Unicode 规范化方式：UnicodeNFC（默认）或 UnicodeOff
+	DeleteScope     string        // 全量同步的删除范围：DeleteScopeAll（默认）或 DeleteScopeManaged
+	IndexFile       string        // 可选，本地索引文件的路径，记录每个 WebDAV 文件的同步时间和大小，增量同步时代替 WebDAV 文件列表
+	VerifyOlderThan time.Duration // 配置了本地索引时，校验只检查超过该时间未上传或校验过的文件，0 表示检查全部文件
+	RetryFile       string        // 可选，重试队列文件的路径，记录重试耗尽后仍上传失败的文件，之后的同步优先重试
+}
+
+// deleteWorkers 返回删除、清理和重命名使用的并发上限。
+func (c Config) deleteWorkers() int {
+	if c.DeleteWorkers > 0 {
+		return c.DeleteWorkers
+	}
+	return c.SyncConcurrency
+}
+
+// withDefaults 返回填充了默认值的配置副本。
+func (c Config) withDefaults() Config {
+	if c.NodeImageAPIURL == "" {
+		c.NodeImageAPIURL = "https://api.nodeimage.com/api/images"
+	}
+	if c.WebdavURL == "" {
+		c.WebdavURL = "https://dav.jianguoyun.com/dav"
+	}
+	return c
 }
 
 // Result 包含了单次同步任务执行完成后的详细结果。
@@ -50,6 +83,8 @@ type Result struct {
 	Message             string        `json:"Message"`
 	Uploaded            int           `json:"Uploaded"`
 	Deleted             int           `json:"Deleted"`
+	Renamed             int           `json:"Renamed"`
+	Failed              int           `json:"Failed"`
 	UploadSize          int64         `json:"UploadSize"`
 	Duration            time.Duration `json:"Duration"`
 	Error               error         `json:"Error,omitempty"`
@@ -57,10 +92,99 @@ type Result struct {
 	TotalNodeImageSize  int64         `json:"TotalNodeImageSize"`
 	TotalWebDAVFiles    int           `json:"TotalWebDAVFiles"`
 	TotalWebDAVSize     int64         `json:"TotalWebDAVSize"`
+	Remaining           int           `json:"Remaining"` // 分批同步时尚未处理的操作数
+}
+
+// Plan 描述了一次同步需要执行的上传和删除操作，以及扫描阶段得到的统计信息。
+// 它可以被序列化为 JSON，以便分批执行或交由其他调用方处理。
+type Plan struct {
+	IsFullSync          bool                  `json:"isFullSync"`
+	Uploads             []nodeimage.ImageInfo `json:"uploads"`
+	Deletes             []string              `json:"deletes"`
+	Purges              []string              `json:"purges"`             // 按保留策略需要清理的旧版本文件，总是直接删除
+	Renames             []Rename              `json:"renames,omitempty"`  // 按 ID 对应时，在 NodeImage 上改过名、需要在 WebDAV 上重命名的文件
+	Snapshot            []SnapshotEntry       `json:"snapshot,omitempty"` // 快照模式下，全部操作成功后写入清单的文件列表
+	IDs                 map[string]string     `json:"ids,omitempty"`      // 按 ID 对应时，执行后要写入边车元数据的变更（文件名 -> 图片 ID，空字符串表示删除）
+	UploadSize          int64                 `json:"uploadSize"`
+	TotalNodeImageFiles int                   `json:"totalNodeImageFiles"`
+	TotalNodeImageSize  int64                 `json:"totalNodeImageSize"`
+	TotalWebDAVFiles    int                   `json:"totalWebDAVFiles"`
+	TotalWebDAVSize     int64                 `json:"totalWebDAVSize"`
+
+	startTime time.Time // 同步开始的时间，用于计算总耗时
+}
+
+// Empty 判断计划中是否没有任何需要执行的操作。
+func (p *Plan) Empty() bool {
+	return len(p.Uploads) == 0 && len(p.Deletes) == 0 && len(p.Purges) == 0 && len(p.Renames) == 0
+}
+
+// Len 返回计划中的操作总数。
+func (p *Plan) Len() int {
+	return len(p.Uploads) + len(p.Deletes) + len(p.Purges) + len(p.Renames)
+}
+
+// Validate 检查来自外部（例如 /api/execute 请求体）的计划是否安全，并重新计算上传总大小。
+// 上传文件名不能包含路径，删除路径必须是 basePath 的直接子级，以防止越权写入或删除。
+func (p *Plan) Validate(basePath string) error {
+	p.UploadSize = 0
+	for _, file := range p.Uploads {
+		if file.Filename == "" || file.Filename == "." || file.Filename == ".." || strings.ContainsAny(file.Filename, `/\`) {
+			return fmt.Errorf("无效的上传文件名: %q", file.Filename)
+		}
+		if !strings.HasPrefix(file.URL, "http://") && !strings.HasPrefix(file.URL, "https://") {
+			return fmt.Errorf("无效的下载地址: %q", file.URL)
+		}
+		p.UploadSize += file.Size
+	}
+	base := path.Clean("/" + basePath)
+	for _, filePath := range p.Deletes {
+		cleaned := path.Clean("/" + filePath)
+		if path.Dir(cleaned) != base || cleaned != "/"+strings.TrimPrefix(filePath, "/") {
+			return fmt.Errorf("删除路径必须位于同步目录 '%s' 下: %q", basePath, filePath)
+		}
+	}
+	for _, r := range p.Renames {
+		name := r.File.Filename
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("无效的重命名文件名: %q", name)
+		}
+		cleaned := path.Clean("/" + r.From)
+		if path.Dir(cleaned) != base || cleaned != "/"+strings.TrimPrefix(r.From, "/") || isVersionedFile(cleaned) {
+			return fmt.Errorf("重命名路径必须位于同步目录 '%s' 下: %q", basePath, r.From)
+		}
+	}
+	for name := range p.IDs {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("无效的图片 ID 记录文件名: %q", name)
+		}
+	}
+	for _, filePath := range p.Purges {
+		cleaned := path.Clean("/" + filePath)
+		if path.Dir(cleaned) != base || cleaned != "/"+strings.TrimPrefix(filePath, "/") || !isVersionedFile(cleaned) {
+			return fmt.Errorf("清理路径必须是同步目录 '%s' 下的旧版本文件: %q", basePath, filePath)
+		}
+	}
+	return nil
 }
 
 // RunSync 是执行同步流程的主函数。
+// 除执行阶段的事件外，Hook 还会在扫描开始前收到通知，扫描失败时同样会收到 OnComplete，
+// 使外部监控（Healthchecks、Uptime Kuma）等集成能够报告每一次运行。
 func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync bool, httpClient *http.Client) Result {
+	hooks := newHookRunner(log, config, httpClient)
+	hooks.started(ctx, isFullSync)
+	plan, err := BuildPlan(ctx, log, config, isFullSync, httpClient)
+	if err != nil {
+		result := Result{Success: false, Message: err.Error(), Error: err}
+		hooks.complete(ctx, nil, result)
+		return result
+	}
+	return executeWithHooks(ctx, log, config, plan, httpClient, hooks)
+}
+
+// BuildPlan 验证配置、扫描两侧的文件列表并对比差异，生成同步计划，但不执行任何写操作。
+func BuildPlan(ctx context.Context, log logger.Logger, config Config, isFullSync bool, httpClient *http.Client) (*Plan, error) {
 	startTime := time.Now()
 	syncMode := "增量同步"
 	if isFullSync {
@@ -74,14 +198,9 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	if (isFullSync && config.NodeImageCookie == "") || (!isFullSync && config.NodeImageAPIKey == "") || config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
 		err := fmt.Errorf("模式 '%s' 所需的配置未完全设置", syncMode)
 		log.Error("  -> ❌ 配置验证失败: %v", err)
-		return Result{Success: false, Message: err.Error(), Error: err}
-	}
-	if config.NodeImageAPIURL == "" {
-		config.NodeImageAPIURL = "https://api.nodeimage.com/api/images"
-	}
-	if config.WebdavURL == "" {
-		config.WebdavURL = "https://dav.jianguoyun.com/dav"
+		return nil, err
 	}
+	config = config.withDefaults()
 	stats := stats.New()
 	nodeImageClient := nodeimage.NewClient(config.NodeImageCookie, config.NodeImageAPIURL, log, stats, httpClient)
 	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats, log, httpClient)
@@ -90,7 +209,7 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	log.Info("[2/3] 扫描远程文件...")
 	if err := webdavClient.Connect(ctx, config.WebdavBasePath); err != nil {
 		log.Error("  -> ❌ 连接 WebDAV 失败: %v", err)
-		return Result{Success: false, Message: fmt.Sprintf("连接 WebDAV 失败: %v", err), Error: err}
+		return nil, fmt.Errorf("连接 WebDAV 失败: %w", err)
 	}
 
 	var nodeImageFiles []nodeimage.ImageInfo
@@ -98,7 +217,7 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	if isFullSync {
 		if err := nodeImageClient.TestConnection(ctx); err != nil {
 			log.Error("  -> ❌ 连接 NodeImage 失败: %v", err)
-			return Result{Success: false, Message: fmt.Sprintf("连接 NodeImage 失败: %v", err), Error: err}
+			return nil, fmt.Errorf("连接 NodeImage 失败: %w", err)
 		}
 		nodeImageFiles, err = nodeImageClient.GetImageListCookie(ctx)
 	} else {
@@ -106,9 +225,21 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	}
 	if err != nil {
 		log.Error("  -> ❌ 获取 NodeImage 文件列表失败: %v", err)
-		return Result{Success: false, Message: fmt.Sprintf("获取 NodeImage 文件列表失败: %v", err), Error: err}
+		return nil, fmt.Errorf("获取 NodeImage 文件列表失败: %w", err)
 	}
 	log.Info("  -> [NodeImage] 发现 %d 张图片", len(nodeImageFiles))
+	nodeImageFiles = disambiguate(log, nodeImageFiles, config.normalizer())
+
+	if config.Scope != "" {
+		scope, err := loadScope(ctx, log, config.Scope, httpClient)
+		if err != nil {
+			log.Error("  -> ❌ 加载同步范围失败: %v", err)
+			return nil, fmt.Errorf("加载同步范围失败: %w", err)
+		}
+		total := len(nodeImageFiles)
+		nodeImageFiles = filterScope(nodeImageFiles, scope)
+		log.Info("  -> [范围] 其中被引用的图片: %d 张，其余 %d 张不在同步范围内", len(nodeImageFiles), total-len(nodeImageFiles))
+	}
 
 	var totalNodeImageSize int64
 	for _, file := range nodeImageFiles {
@@ -116,27 +247,27 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	}
 	totalNodeImageFiles := len(nodeImageFiles)
 
+	cache, cacheKey := config.listingCache(), config.cacheKey()
 	if isFullSync {
-		InvalidateWebdavCache()
+		cache.Invalidate(ctx, cacheKey)
 	}
-	cacheMutex.RLock()
-	cachedFiles := webdavCache
-	cacheMutex.RUnlock()
 
 	var webdavFileInfos []webdav.FileInfo
-	if cachedFiles != nil {
+	if cachedFiles, ok := cache.Load(ctx, cacheKey); ok {
 		webdavFileInfos = cachedFiles
 		log.Info("  -> [WebDAV] 从缓存加载 %d 个文件", len(webdavFileInfos))
+	} else if indexed, ok := indexedListing(log, config, isFullSync); ok {
+		webdavFileInfos = indexed
+		log.Info("  -> [WebDAV] 从本地索引加载 %d 个文件", len(webdavFileInfos))
 	} else {
 		infos, err := webdavClient.ListFilesWithStats(ctx, config.WebdavBasePath)
 		if err != nil {
 			log.Error("  -> ❌ 获取 WebDAV 文件列表失败: %v", err)
-			return Result{Success: false, Message: fmt.Sprintf("获取 WebDAV 文件列表失败: %v", err), Error: err}
+			return nil, fmt.Errorf("获取 WebDAV 文件列表失败: %w", err)
 		}
 		webdavFileInfos = infos
-		cacheMutex.Lock()
-		webdavCache = infos
-		cacheMutex.Unlock()
+		cache.Store(ctx, cacheKey, infos)
+		refreshIndex(log, config, infos)
 		log.Info("  -> [WebDAV] 发现 %d 个文件", len(webdavFileInfos))
 	}
 
@@ -148,15 +279,107 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	}
 	totalWebDAVFiles := len(webdavFiles)
 
-	// --- 步骤 3: 分析并执行同步 ---
+	// --- 步骤 3: 分析差异 ---
 	log.Info("[3/3] 分析并执行同步...")
-	filesToUpload, filesToDeleteRaw := diffFiles(nodeImageFiles, webdavFiles)
+	opts, index := matchOptions(ctx, log, webdavClient, config)
+	filesToUpload, filesToDeleteRaw, matched := diffFiles(nodeImageFiles, webdavFiles, opts)
+	var ids map[string]string
+	var renames []Rename
+	if config.tracksIDs() {
+		ids = idIndexPatch(index, nodeImageFiles, webdavFiles, matched)
+	}
+	filesToUpload = queuedRetries(log, config, isFullSync, nodeImageFiles, webdavFiles, index.Files, filesToUpload)
+	if config.MatchBy == MatchByID {
+		renames = planRenames(nodeImageFiles, webdavFiles, matched, opts.Normalize)
+	}
+	if config.Snapshots && len(renames) > 0 {
+		// 历史清单按文件名引用图片，重命名会使其失效，因此快照模式下改名的图片以新文件名另存一份
+		for _, r := range renames {
+			filesToUpload = append(filesToUpload, r.File)
+		}
+		renames = nil
+	}
 	var filesToDelete []string
-	if isFullSync {
-		filesToDelete = filesToDeleteRaw
+	var snapshot []SnapshotEntry
+	switch {
+	case isFullSync && config.Snapshots:
+		// 快照模式下旧文件可能仍被历史清单引用，因此只上传不删除
+		snapshot = snapshotOf(nodeImageFiles)
+		if len(filesToDeleteRaw) > 0 {
+			log.Info("  -> [快照] 保留 %d 个已从 NodeImage 删除的文件", len(filesToDeleteRaw))
+		}
+	case isFullSync:
+		filesToDelete = skipTemporary(log, filesToDeleteRaw)
+		if config.DeleteScope == DeleteScopeManaged {
+			filesToDelete = managedOnly(log, filesToDelete, index.Managed)
+		}
 	}
 
-	if len(filesToUpload) == 0 && len(filesToDelete) == 0 {
+	// 保留策略：清理超出数量或时间限制的旧版本，增量同步同样执行，使清理随定时任务周期性进行
+	filesToPurge := expiredVersions(webdavFiles, config.KeepVersions, config.VersionMaxAge, time.Now())
+
+	var totalUploadSize int64
+	for _, file := range filesToUpload {
+		totalUploadSize += file.Size
+	}
+
+	plan := &Plan{
+		IsFullSync:          isFullSync,
+		Uploads:             filesToUpload,
+		Deletes:             filesToDelete,
+		Purges:              filesToPurge,
+		Renames:             renames,
+		Snapshot:            snapshot,
+		IDs:                 ids,
+		UploadSize:          totalUploadSize,
+		TotalNodeImageFiles: totalNodeImageFiles,
+		TotalNodeImageSize:  totalNodeImageSize,
+		TotalWebDAVFiles:    totalWebDAVFiles,
+		TotalWebDAVSize:     totalWebDAVSize,
+		startTime:           startTime,
+	}
+	if !plan.Empty() {
+		log.Info("  -> [计划] 上传: %d 张 (%s)", len(filesToUpload), formatBytes(totalUploadSize))
+		if isFullSync {
+			log.Info("  -> [计划] 删除: %d 张", len(filesToDelete))
+		}
+		if len(renames) > 0 {
+			log.Info("  -> [计划] 重命名: %d 张", len(renames))
+		}
+		if len(filesToPurge) > 0 {
+			log.Info("  -> [计划] 清理过期旧版本: %d 个", len(filesToPurge))
+		}
+	}
+	return plan, nil
+}
+
+// ExecutePlan 并发执行计划中的上传和删除操作，并返回执行结果。
+// 执行前后以及每个文件完成时会调用注册的 Hook，演练模式除外。
+func ExecutePlan(ctx context.Context, log logger.Logger, config Config, plan *Plan, httpClient *http.Client) Result {
+	return executeWithHooks(ctx, log, config, plan, httpClient, newHookRunner(log, config, httpClient))
+}
+
+// executeWithHooks 在 hooks 的 OnPlan 和 OnComplete 之间执行计划。
+func executeWithHooks(ctx context.Context, log logger.Logger, config Config, plan *Plan, httpClient *http.Client, hooks *hookRunner) Result {
+	if err := hooks.plan(ctx, plan); err != nil {
+		err = fmt.Errorf("扩展中止了同步: %w", err)
+		log.Error("  -> ❌ %v", err)
+		result := Result{Success: false, Message: err.Error(), Error: err}
+		hooks.complete(ctx, plan, result)
+		return result
+	}
+	result := executePlan(ctx, log, config, plan, httpClient, hooks)
+	hooks.complete(ctx, plan, result)
+	return result
+}
+
+func executePlan(ctx context.Context, log logger.Logger, config Config, plan *Plan, httpClient *http.Client, hooks *hookRunner) Result {
+	startTime := plan.startTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+
+	if plan.Empty() {
 		log.Info("  -> ✅ 文件已是最新状态，无需操作。")
 		duration := time.Since(startTime)
 		log.Info("  -> 任务完成，耗时: %s", duration.Round(time.Second))
@@ -164,89 +387,189 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 			Success:             true,
 			Message:             "文件已是最新状态，无需同步。",
 			Duration:            duration,
-			TotalNodeImageFiles: totalNodeImageFiles,
-			TotalNodeImageSize:  totalNodeImageSize,
-			TotalWebDAVFiles:    totalWebDAVFiles,
-			TotalWebDAVSize:     totalWebDAVSize,
+			TotalNodeImageFiles: plan.TotalNodeImageFiles,
+			TotalNodeImageSize:  plan.TotalNodeImageSize,
+			TotalWebDAVFiles:    plan.TotalWebDAVFiles,
+			TotalWebDAVSize:     plan.TotalWebDAVSize,
 		}
 	}
 
-	var totalUploadSize int64
-	for _, file := range filesToUpload {
-		totalUploadSize += file.Size
+	if config.DryRun {
+		return dryRunResult(log, plan, startTime, config.DeleteMode)
 	}
-	log.Info("  -> [计划] 上传: %d 张 (%s)", len(filesToUpload), formatBytes(totalUploadSize))
-	if isFullSync {
-		log.Info("  -> [计划] 删除: %d 张", len(filesToDelete))
+
+	config = config.withDefaults()
+	stats := stats.New()
+	nodeImageClient := nodeimage.NewClient(config.NodeImageCookie, config.NodeImageAPIURL, log, stats, httpClient)
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats, log, httpClient)
+
+	plan, skipped, err := checkQuota(ctx, log, webdavClient, config, plan)
+	if err != nil {
+		log.Error("  -> ❌ %v", err)
+		return Result{Success: false, Message: err.Error(), Error: err, Duration: time.Since(startTime)}
 	}
 
+	// 执行期间缓存的文件列表随时会过时。先清除它，避免进程中途退出时留下（甚至持久化）过时的列表
+	config.listingCache().Invalidate(ctx, config.cacheKey())
+
 	var wg sync.WaitGroup
-	guard := make(chan struct{}, config.SyncConcurrency)
-	var uploadCount, deleteCount int
-	var uploadErrCount, deleteErrCount int
+	pool := newLimiter(log, config.SyncConcurrency, config.AutoConcurrency)
+	// 删除等操作不传输数据，使用独立的名额，避免排在大文件上传之后
+	deletePool := newLimiter(log, config.deleteWorkers(), config.AutoConcurrency)
+	progress := &tracker{total: plan.Len(), onProgress: config.OnProgress}
 
-	for _, file := range filesToUpload {
+	for _, file := range plan.Uploads {
 		wg.Add(1)
 		go func(file nodeimage.ImageInfo) {
 			defer wg.Done()
-			guard <- struct{}{}
-			defer func() { <-guard }()
-			err := uploadFile(ctx, file, nodeImageClient, webdavClient, config.WebdavBasePath, log)
+			retrying := false
+			err := withRetry(ctx, log, config.SyncRetries, "上传 "+file.Filename, func() error {
+				return pool.do(func() error {
+					return withTimeout(ctx, config.OpTimeout, func(ctx context.Context) error {
+						// 上一次尝试可能已经写入成功，只是响应丢失或超时，此时无需重新下载和上传
+						if retrying && config.VerifyUploads && uploaded(ctx, webdavClient, config.WebdavBasePath, file) {
+							log.Info("  -> ✅ 上传成功 (上一次尝试已写入): %s", file.Filename)
+							return nil
+						}
+						retrying = true
+						return uploadFile(ctx, file, nodeImageClient, webdavClient, config.WebdavBasePath, config.VerifyUploads, log)
+					})
+				})
+			})
 			if err != nil {
 				log.Error("  -> ❌ 上传失败 %s: %v", file.Filename, err)
-				uploadErrCount++
+				hooks.uploadFailed(ctx, file, err)
 			} else {
-				uploadCount++
+				hooks.uploaded(ctx, file, filepath.Join(config.WebdavBasePath, file.Filename))
 			}
+			progress.record(OpUpload, file.Filename, err)
 		}(file)
 	}
 
-	if isFullSync {
-		for _, file := range filesToDelete {
-			wg.Add(1)
-			go func(filePath string) {
-				defer wg.Done()
-				guard <- struct{}{}
-				defer func() { <-guard }()
-				err := webdavClient.DeleteFile(ctx, filePath)
-				if err != nil {
-					log.Error("  -> ❌ 删除失败 %s: %v", filePath, err)
-					deleteErrCount++
-				} else {
-					log.Info("  -> ✅ 删除成功: %s", filepath.Base(filePath))
-					deleteCount++
-				}
-			}(file)
-		}
+	for _, file := range plan.Deletes {
+		wg.Add(1)
+		go func(filePath string) {
+			defer wg.Done()
+			var target string
+			err := withRetry(ctx, log, config.SyncRetries, "删除 "+filepath.Base(filePath), func() error {
+				return deletePool.do(func() error {
+					return withTimeout(ctx, config.OpTimeout, func(ctx context.Context) (err error) {
+						target, err = removeFile(ctx, webdavClient, config.DeleteMode, filePath)
+						return err
+					})
+				})
+			})
+			switch {
+			case err != nil:
+				log.Error("  -> ❌ 删除失败 %s: %v", filePath, err)
+			case target != "":
+				log.Info("  -> ✅ 已保留旧版本: %s -> %s", filepath.Base(filePath), path.Base(target))
+			default:
+				log.Info("  -> ✅ 删除成功: %s", filepath.Base(filePath))
+			}
+			if err == nil {
+				hooks.deleted(ctx, filePath, target)
+			}
+			progress.record(OpDelete, filepath.Base(filePath), err)
+		}(file)
+	}
+
+	for _, r := range plan.Renames {
+		wg.Add(1)
+		go func(r Rename) {
+			defer wg.Done()
+			target := r.target()
+			retrying := false
+			err := withRetry(ctx, log, config.SyncRetries, "重命名 "+path.Base(r.From), func() error {
+				return deletePool.do(func() error {
+					return withTimeout(ctx, config.OpTimeout, func(ctx context.Context) error {
+						err := webdavClient.MoveFile(ctx, r.From, target)
+						// 上一次尝试可能已经移动成功，只是响应丢失或超时
+						if err != nil && retrying && moved(ctx, webdavClient, r.From, target) {
+							return nil
+						}
+						retrying = true
+						return err
+					})
+				})
+			})
+			if err != nil {
+				log.Error("  -> ❌ 重命名失败 %s: %v", path.Base(r.From), err)
+			} else {
+				log.Info("  -> ✅ 重命名成功: %s -> %s", path.Base(r.From), r.File.Filename)
+				hooks.renamed(ctx, r.File, r.From, target)
+			}
+			progress.record(OpRename, r.File.Filename, err)
+		}(r)
+	}
+
+	for _, file := range plan.Purges {
+		wg.Add(1)
+		go func(filePath string) {
+			defer wg.Done()
+			err := withRetry(ctx, log, config.SyncRetries, "清理 "+filepath.Base(filePath), func() error {
+				return deletePool.do(func() error {
+					return withTimeout(ctx, config.OpTimeout, func(ctx context.Context) error {
+						return webdavClient.DeleteFile(ctx, filePath)
+					})
+				})
+			})
+			if err != nil {
+				log.Error("  -> ❌ 清理旧版本失败 %s: %v", filePath, err)
+			} else {
+				log.Info("  -> ✅ 已清理过期旧版本: %s", filepath.Base(filePath))
+				hooks.purged(ctx, filePath)
+			}
+			progress.record(OpDelete, filepath.Base(filePath), err)
+		}(file)
 	}
 
 	wg.Wait()
 
-	if uploadCount > 0 || deleteCount > 0 {
-		InvalidateWebdavCache()
+	uploadCount, deleteCount, renameCount := progress.uploaded, progress.deleted, progress.renamed
+	uploadErrCount, deleteErrCount, renameErrCount := progress.uploadFailed, progress.deleteFailed, progress.renameFailed
+	if uploadCount > 0 || deleteCount > 0 || renameCount > 0 {
+		config.listingCache().Invalidate(ctx, config.cacheKey())
 	}
 
 	duration := time.Since(startTime)
 	message := fmt.Sprintf("上传: %d (失败: %d), 删除: %d (失败: %d)",
 		uploadCount, uploadErrCount, deleteCount, deleteErrCount)
+	if len(plan.Renames) > 0 {
+		message += fmt.Sprintf(", 重命名: %d (失败: %d)", renameCount, renameErrCount)
+	}
+	if skipped > 0 {
+		message += fmt.Sprintf(", 因空间不足跳过: %d", skipped)
+	}
 
 	result := Result{
 		Uploaded:            uploadCount,
 		Deleted:             deleteCount,
-		UploadSize:          totalUploadSize,
+		Renamed:             renameCount,
+		Failed:              uploadErrCount + deleteErrCount + renameErrCount,
+		UploadSize:          plan.UploadSize,
 		Duration:            duration,
-		TotalNodeImageFiles: totalNodeImageFiles,
-		TotalNodeImageSize:  totalNodeImageSize,
-		TotalWebDAVFiles:    totalWebDAVFiles,
-		TotalWebDAVSize:     totalWebDAVSize,
+		TotalNodeImageFiles: plan.TotalNodeImageFiles,
+		TotalNodeImageSize:  plan.TotalNodeImageSize,
+		TotalWebDAVFiles:    plan.TotalWebDAVFiles,
+		TotalWebDAVSize:     plan.TotalWebDAVSize,
 		Message:             message,
 	}
 
-	if uploadErrCount > 0 || deleteErrCount > 0 {
+	switch {
+	case uploadErrCount > 0 || deleteErrCount > 0:
 		log.Error("  -> ❗ 同步摘要: %s", message)
 		result.Success = false
 		result.Error = fmt.Errorf("同步过程中有 %d 个上传和 %d 个删除操作失败", uploadErrCount, deleteErrCount)
-	} else {
+	case renameErrCount > 0:
+		log.Error("  -> ❗ 同步摘要: %s", message)
+		result.Success = false
+		result.Error = fmt.Errorf("同步过程中有 %d 个重命名操作失败", renameErrCount)
+	case skipped > 0:
+		log.Error("  -> ❗ 同步摘要: %s", message)
+		result.Success = false
+		result.Error = fmt.Errorf("WebDAV 剩余空间不足，有 %d 个文件未上传", skipped)
+	default:
 		log.Info("  -> ✅ 同步摘要: %s", message)
 		result.Success = true
 	}
@@ -255,30 +578,110 @@ func RunSync(ctx context.Context, log logger.Logger, config Config, isFullSync b
 	return result
 }
 
-// diffFiles 对比 NodeImage 和 WebDAV 的文件列表，找出需要上传和删除的文件。
-func diffFiles(nodeImageFiles []nodeimage.ImageInfo, webdavFiles []string) (toUpload []nodeimage.ImageInfo, toDelete []string) {
-	webdavFileMap := make(map[string]string)
-	for _, f := range webdavFiles {
-		webdavFileMap[filepath.Base(f)] = f
+// dryRunResult 在演练模式下逐条输出计划中的操作，并返回不包含任何实际变更的结果。
+func dryRunResult(log logger.Logger, plan *Plan, startTime time.Time, deleteMode string) Result {
+	for _, file := range plan.Uploads {
+		log.Info("  -> [演练] 将上传: %s (%s)", file.Filename, formatBytes(file.Size))
+	}
+	for _, filePath := range plan.Deletes {
+		if deleteMode == DeleteModeVersion {
+			log.Info("  -> [演练] 将保留为旧版本: %s", filepath.Base(filePath))
+		} else {
+			log.Info("  -> [演练] 将删除: %s", filepath.Base(filePath))
+		}
+	}
+	for _, r := range plan.Renames {
+		log.Info("  -> [演练] 将重命名: %s -> %s", path.Base(r.From), r.File.Filename)
+	}
+	for _, filePath := range plan.Purges {
+		log.Info("  -> [演练] 将清理过期旧版本: %s", filepath.Base(filePath))
+	}
+
+	duration := time.Since(startTime)
+	message := fmt.Sprintf("演练模式: 计划上传 %d (%s), 计划删除 %d，未执行任何操作",
+		len(plan.Uploads), formatBytes(plan.UploadSize), len(plan.Deletes)+len(plan.Purges))
+	if len(plan.Renames) > 0 {
+		message = fmt.Sprintf("演练模式: 计划上传 %d (%s), 计划删除 %d, 计划重命名 %d，未执行任何操作",
+			len(plan.Uploads), formatBytes(plan.UploadSize), len(plan.Deletes)+len(plan.Purges), len(plan.Renames))
+	}
+	log.Info("  -> ✅ 同步摘要: %s", message)
+	log.Info("  -> 任务完成，耗时: %s", duration.Round(time.Second))
+
+	return Result{
+		Success:             true,
+		Message:             message,
+		UploadSize:          plan.UploadSize,
+		Duration:            duration,
+		TotalNodeImageFiles: plan.TotalNodeImageFiles,
+		TotalNodeImageSize:  plan.TotalNodeImageSize,
+		TotalWebDAVFiles:    plan.TotalWebDAVFiles,
+		TotalWebDAVSize:     plan.TotalWebDAVSize,
+	}
+}
+
+// diffFiles 按 opts 对比 NodeImage 和 WebDAV 的文件列表，找出需要上传和删除的文件，以及已对应的文件。
+// 保留的旧版本文件（*.deleted）不参与对比，因此永远不会被删除。
+func diffFiles(nodeImageFiles []nodeimage.ImageInfo, webdavFiles []string, opts diff.Options) (toUpload []nodeimage.ImageInfo, toDelete []string, matched []diff.Match) {
+	result := diff.Compare(diffSources(nodeImageFiles), diffTargets(webdavFiles), opts)
+	for _, i := range result.Upload {
+		toUpload = append(toUpload, nodeImageFiles[i])
+	}
+	return toUpload, result.Delete, result.Matched
+}
+
+// diffSources 将 NodeImage 的文件列表转换为 diff 的源文件列表。
+func diffSources(files []nodeimage.ImageInfo) []diff.Source {
+	sources := make([]diff.Source, len(files))
+	for i, f := range files {
+		sources[i] = diff.Source{ID: f.ID, Name: f.Filename}
 	}
+	return sources
+}
+
+// diffTargets 将 WebDAV 的文件路径列表转换为 diff 的目标文件列表。
+func diffTargets(paths []string) []diff.Target {
+	targets := make([]diff.Target, len(paths))
+	for i, p := range paths {
+		targets[i] = diff.Target{Path: p}
+	}
+	return targets
+}
 
-	for _, niFile := range nodeImageFiles {
-		targetFilename := niFile.Filename
-		if _, exists := webdavFileMap[targetFilename]; !exists {
-			toUpload = append(toUpload, niFile)
+// temporaryPatterns 是其他客户端上传或编辑过程中产生的临时文件和隐藏文件（按小写文件名匹配）。
+var temporaryPatterns = []string{"*.part", "*.tmp", ".~lock*", ".*"}
+
+// isTemporaryFile 判断文件是否为临时文件或隐藏文件。
+func isTemporaryFile(filePath string) bool {
+	name := strings.ToLower(path.Base(filePath))
+	for _, pattern := range temporaryPatterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
 		}
-		delete(webdavFileMap, targetFilename)
 	}
+	return false
+}
 
-	for _, fullPath := range webdavFileMap {
-		toDelete = append(toDelete, fullPath)
+// skipTemporary 从删除列表中去掉临时文件和隐藏文件，避免删掉其他客户端正在上传的文件。
+func skipTemporary(log logger.Logger, toDelete []string) []string {
+	kept := toDelete[:0:0]
+	for _, filePath := range toDelete {
+		if isTemporaryFile(filePath) {
+			log.Debug("  -> 跳过临时文件: %s", path.Base(filePath))
+			continue
+		}
+		kept = append(kept, filePath)
 	}
-	return toUpload, toDelete
+	if skipped := len(toDelete) - len(kept); skipped > 0 {
+		log.Info("  -> [删除] 跳过 %d 个临时文件或隐藏文件", skipped)
+	}
+	return kept
 }
 
 // uploadFile 封装了单个文件的下载和上传流程。
 // 通过流式处理，它能以极低的内存占用处理大文件。
-func uploadFile(ctx context.Context, file nodeimage.ImageInfo, niClient *nodeimage.Client, wdClient *webdav.Client, basePath string, log logger.Logger) error {
+// verify 为 true 时，上传后还会确认文件已以实际发送的大小存在于 WebDAV 上，
+// 避免把行为异常的网关返回的 2xx 当作成功。
+func uploadFile(ctx context.Context, file nodeimage.ImageInfo, niClient *nodeimage.Client, wdClient *webdav.Client, basePath string, verify bool, log logger.Logger) error {
 	// 步骤 1: 获取图片数据流，而不是完整的字节数组
 	imageStream, err := niClient.DownloadImageStream(ctx, file.URL)
 	if err != nil {
@@ -288,15 +691,56 @@ func uploadFile(ctx context.Context, file nodeimage.ImageInfo, niClient *nodeima
 
 	// 步骤 2: 使用流式上传 API
 	targetPath := filepath.Join(basePath, file.Filename)
-	err = wdClient.UploadFileStream(ctx, targetPath, imageStream, file.Size)
+	counter := &countingReader{r: imageStream}
+	err = wdClient.UploadFileStream(ctx, targetPath, counter, file.Size)
 	if err != nil {
 		return fmt.Errorf("流式上传失败: %w", err)
 	}
 
+	// 步骤 3: 确认文件确实已写入
+	if verify {
+		if err := verifyUpload(ctx, wdClient, targetPath, counter.n); err != nil {
+			return err
+		}
+	}
+
 	log.Info("  -> ✅ 上传成功: %s", file.Filename)
 	return nil
 }
 
+// verifyUpload 确认 WebDAV 上的文件存在且大小为 size。
+func verifyUpload(ctx context.Context, wdClient *webdav.Client, targetPath string, size int64) error {
+	info, err := wdClient.Stat(ctx, targetPath)
+	if err != nil {
+		return fmt.Errorf("上传后校验失败: %w", err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("上传后校验失败: WebDAV 上的文件大小为 %d 字节，实际上传了 %d 字节", info.Size, size)
+	}
+	return nil
+}
+
+// uploaded 判断文件是否已以 NodeImage 报告的大小存在于 WebDAV 上。大小未知时返回 false。
+func uploaded(ctx context.Context, wdClient *webdav.Client, basePath string, file nodeimage.ImageInfo) bool {
+	if file.Size <= 0 {
+		return false
+	}
+	info, err := wdClient.Stat(ctx, filepath.Join(basePath, file.Filename))
+	return err == nil && info.Size == file.Size
+}
+
+// countingReader 统计已读取的字节数，即实际上传的大小。
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // formatBytes 将字节数格式化为更易读的单位 (KB, MB, GB)。
 func formatBytes(b int64) string {
 	const unit = 1024
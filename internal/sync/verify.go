@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/imagecheck"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// CorruptFile 是校验未通过的备份文件。
+type CorruptFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// VerifyReport 是校验模式的结果。
+type VerifyReport struct {
+	ScannedFiles int           `json:"scannedFiles"`
+	CheckedFiles int           `json:"checkedFiles"`
+	SkippedFiles int           `json:"skippedFiles"` // 非图片格式或旧版本文件，未校验
+	RecentFiles  int           `json:"recentFiles"`  // 本地索引显示最近已上传或校验过的文件，未校验
+	Corrupted    []CorruptFile `json:"corrupted"`
+}
+
+// Verify 下载 WebDAV 同步目录中的每一个图片文件，检查其内容是否与扩展名声明的格式相符，
+// 以便在真正需要恢复之前发现被截断、部分写入或被替换为错误页的备份。
+// 该操作只读，不会修改任何文件。
+// 配置了本地索引和 VerifyOlderThan 时，只校验超过该时间未上传或校验过的文件，校验通过的文件会记录校验时间。
+func Verify(ctx context.Context, log logger.Logger, config Config, httpClient *http.Client) (*VerifyReport, error) {
+	if config.WebdavUsername == "" || config.WebdavPassword == "" || config.WebdavBasePath == "" {
+		return nil, fmt.Errorf("WebDAV 配置未完全设置")
+	}
+	config = config.withDefaults()
+	webdavClient := webdav.NewClient(config.WebdavURL, config.WebdavUsername, config.WebdavPassword, stats.New(), log, httpClient)
+
+	infos, err := webdavClient.ListFilesWithStats(ctx, config.WebdavBasePath)
+	if err != nil {
+		return nil, fmt.Errorf("获取 WebDAV 文件列表失败: %w", err)
+	}
+	log.Info("  -> [WebDAV] 发现 %d 个文件，正在校验...", len(infos))
+	refreshIndex(log, config, infos)
+	recent := recentlyChecked(log, config)
+
+	report := &VerifyReport{ScannedFiles: len(infos)}
+	sizes := make(map[string]int64, len(infos))
+	var paths []string
+	for _, info := range infos {
+		if isVersionedFile(info.Path) || imagecheck.Format(info.Path) == "" {
+			report.SkippedFiles++
+			continue
+		}
+		if recent[path.Base(info.Path)] {
+			report.RecentFiles++
+			continue
+		}
+		sizes[info.Path] = info.Size
+		paths = append(paths, info.Path)
+	}
+
+	var mutex sync.Mutex
+	var passed []string
+	readFiles(ctx, log, config, webdavClient, paths, func(p string, data []byte, err error) {
+		if err == nil {
+			if int64(len(data)) != sizes[p] {
+				err = fmt.Errorf("实际大小 %d 与列表中的大小 %d 不符", len(data), sizes[p])
+			} else {
+				err = imagecheck.Check(p, data)
+			}
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		report.CheckedFiles++
+		if err != nil {
+			log.Error("  -> ❌ 校验失败 %s: %v", path.Base(p), err)
+			report.Corrupted = append(report.Corrupted, CorruptFile{Path: p, Error: err.Error()})
+		} else {
+			log.Debug("  -> 校验通过: %s", path.Base(p))
+			passed = append(passed, path.Base(p))
+		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	recordVerified(log, config, passed)
+
+	sort.Slice(report.Corrupted, func(i, j int) bool { return report.Corrupted[i].Path < report.Corrupted[j].Path })
+	if len(report.Corrupted) > 0 {
+		log.Error("  -> ❗ 校验完成: %d 个文件中有 %d 个已损坏", report.CheckedFiles, len(report.Corrupted))
+	} else {
+		log.Info("  -> ✅ 校验完成: %d 个文件全部通过", report.CheckedFiles)
+	}
+	if report.RecentFiles > 0 {
+		log.Info("  -> 跳过 %d 个最近 %s 内已上传或校验过的文件", report.RecentFiles, config.VerifyOlderThan)
+	}
+	return report, nil
+}
+
+// recentlyChecked 返回本地索引中在 config.VerifyOlderThan 之内上传或校验过的文件名。
+// 未配置本地索引或 VerifyOlderThan 时返回 nil，即校验全部文件。
+func recentlyChecked(log logger.Logger, config Config) map[string]bool {
+	if config.IndexFile == "" || config.VerifyOlderThan <= 0 {
+		return nil
+	}
+	t, err := loadIndexTarget(config.IndexFile, config.cacheKey())
+	if err != nil {
+		log.Warn("  -> ⚠️ %v，将校验全部文件", err)
+		return nil
+	}
+	if t == nil {
+		return nil
+	}
+	cutoff := time.Now().Add(-config.VerifyOlderThan)
+	recent := make(map[string]bool)
+	for name, entry := range t.Files {
+		if entry.checkedAt().After(cutoff) {
+			recent[name] = true
+		}
+	}
+	return recent
+}
+
+// recordVerified 在本地索引中记录 names 的校验时间。
+func recordVerified(log logger.Logger, config Config, names []string) {
+	if config.IndexFile == "" || len(names) == 0 {
+		return
+	}
+	now := time.Now()
+	err := updateIndexTarget(config.IndexFile, config.cacheKey(), func(t *indexTarget) {
+		for _, name := range names {
+			if entry, ok := t.Files[name]; ok {
+				entry.VerifiedAt = now
+				t.Files[name] = entry
+			}
+		}
+	})
+	if err != nil {
+		log.Warn("  -> ⚠️ %v", err)
+	}
+}
+
+// readFiles 以 config.SyncConcurrency 的并发度（启用自适应并发时会自动调整）逐个读取 WebDAV 文件，并对每个文件调用 fn。
+// 读取失败会按 config.SyncRetries 重试，最终的错误同样交给 fn 处理。fn 可能被并发调用。
+func readFiles(ctx context.Context, log logger.Logger, config Config, client *webdav.Client, paths []string, fn func(p string, data []byte, err error)) {
+	var wg sync.WaitGroup
+	pool := newLimiter(log, config.SyncConcurrency, config.AutoConcurrency)
+	for _, p := range paths {
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			var data []byte
+			err := withRetry(ctx, log, config.SyncRetries, "读取 "+path.Base(p), func() error {
+				return pool.do(func() error {
+					return withTimeout(ctx, config.OpTimeout, func(ctx context.Context) (err error) {
+						data, err = client.ReadFile(ctx, p)
+						return err
+					})
+				})
+			})
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			fn(p, data, err)
+		}(p)
+	}
+	wg.Wait()
+}
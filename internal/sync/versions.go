@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/webdav"
+)
+
+// 删除模式，决定全量同步时如何处理 NodeImage 上已不存在的文件。
+const (
+	DeleteModeDelete  = "delete"  // 直接从 WebDAV 删除（默认）
+	DeleteModeVersion = "version" // 原地重命名为 name.YYYYMMDD-HHMMSS.deleted，保留旧版本
+)
+
+// versionSuffix 是保留的旧版本文件的后缀。带有该后缀的文件不参与对比，也不会被删除。
+const versionSuffix = ".deleted"
+
+// versionName 返回文件在 t 时刻被保留时使用的路径，例如 a.png -> a.png.20240102-150405.deleted。
+func versionName(filePath string, t time.Time) string {
+	return filePath + "." + t.Format(versionTimeLayout) + versionSuffix
+}
+
+// isVersionedFile 判断文件是否为保留的旧版本。
+func isVersionedFile(name string) bool {
+	return strings.HasSuffix(name, versionSuffix)
+}
+
+// removeFile 根据删除模式删除文件，或将其重命名为带时间戳的旧版本。
+// 返回文件最终的去向，供日志输出使用；直接删除时返回空字符串。
+func removeFile(ctx context.Context, client *webdav.Client, mode, filePath string) (string, error) {
+	if mode != DeleteModeVersion {
+		return "", client.DeleteFile(ctx, filePath)
+	}
+	target := versionName(filePath, time.Now())
+	return target, client.MoveFile(ctx, filePath, target)
+}
+
+// versionTimeLayout 是旧版本文件名中时间戳的格式。
+const versionTimeLayout = "20060102-150405"
+
+// parseVersionName 从旧版本文件名中解析出原始文件名和保留时间。
+// 不是旧版本文件或时间戳无法解析时 ok 为 false。
+func parseVersionName(name string) (original string, at time.Time, ok bool) {
+	if !isVersionedFile(name) {
+		return "", time.Time{}, false
+	}
+	rest := strings.TrimSuffix(name, versionSuffix)
+	dot := strings.LastIndex(rest, ".")
+	if dot <= 0 {
+		return "", time.Time{}, false
+	}
+	at, err := time.ParseInLocation(versionTimeLayout, rest[dot+1:], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return rest[:dot], at, true
+}
+
+// expiredVersions 按保留策略找出需要清理的旧版本文件：
+// 每个原始文件只保留最新的 keep 个版本（0 表示不限），且超过 maxAge 的版本一律清理（0 表示不限）。
+func expiredVersions(webdavFiles []string, keep int, maxAge time.Duration, now time.Time) []string {
+	if keep <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	type version struct {
+		path string
+		at   time.Time
+	}
+	byOriginal := make(map[string][]version)
+	for _, f := range webdavFiles {
+		original, at, ok := parseVersionName(path.Base(f))
+		if !ok {
+			continue
+		}
+		byOriginal[original] = append(byOriginal[original], version{path: f, at: at})
+	}
+
+	var expired []string
+	for _, versions := range byOriginal {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].at.After(versions[j].at) })
+		for i, v := range versions {
+			if (keep > 0 && i >= keep) || (maxAge > 0 && now.Sub(v.at) > maxAge) {
+				expired = append(expired, v.path)
+			}
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
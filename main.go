@@ -2,19 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"nodeimage_webdav_webui/internal/config"
-	sync_lib "nodeimage_webdav_webui/internal/sync"
-	"nodeimage_webdav_webui/pkg/logger"
-	"nodeimage_webdav_webui/pkg/stats"
-	"nodeimage_webdav_webui/pkg/websocket"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/jobs"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/schedule"
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/grafana"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/sdnotify"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
 
 	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
@@ -26,58 +37,86 @@ var (
 	hub         *websocket.Hub
 	log         logger.Logger
 	st          *stats.Stats
-	syncMutex   sync.Mutex
+	manager     *jobs.Manager
 	httpClient  *http.Client
 	store       *sessions.CookieStore
+
+	// logOutput 是日志的输出目标。以 Windows 服务运行时没有控制台，日志会改为写入文件。
+	logOutput io.Writer = os.Stdout
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		fmt.Println("警告：未找到 .env 文件，将依赖系统环境变量")
+	// 在 Windows 上，install/uninstall/start/stop 子命令管理服务，由服务控制管理器启动时以服务方式运行
+	if handleService(os.Args[1:]) {
+		return
 	}
 
-	appConfig = config.LoadConfig()
+	once, job := parseFlags(os.Args[1:])
 
-	if appConfig.Password != "" {
-		store = sessions.NewCookieStore([]byte("secret-key")) // 在生产环境中应使用更安全的密钥
+	// 收到 SIGINT/SIGTERM 时停止定时任务、关闭服务器并保存缓存，而不是直接退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if once.set {
+		code := runOnce(ctx, job, once.full)
+		stop()
+		os.Exit(code)
+	}
+	if err := serve(ctx); err != nil {
+		os.Exit(1)
 	}
+}
+
+// setup 加载 .env 和环境变量配置，并创建 logger、WebSocket Hub 和 HTTP 客户端。
+func setup() {
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		processEnv[key] = true
+	}
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(logOutput, "警告：未找到 .env 文件，将依赖系统环境变量")
+	}
+	for _, kv := range os.Environ() {
+		if key, _, _ := strings.Cut(kv, "="); !processEnv[key] {
+			dotenvKeys[key] = true
+		}
+	}
+
+	appConfig = config.LoadConfig()
+	loadedConfig = *appConfig
 
 	logLevel := logger.StringToLogLevel(appConfig.LogLevel)
-	log = logger.New(logLevel, os.Stdout)
+	log = logger.New(logLevel, logOutput)
 	st = stats.New()
 	hub = websocket.NewHub()
 	go hub.Run()
 
-	httpClient = &http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-		Timeout: 30 * time.Second,
-	}
-
-	if appConfig.SyncInterval > 0 {
-		log.Info("已设置定时同步，每 %d 分钟执行一次增量同步", appConfig.SyncInterval)
-		ticker := time.NewTicker(time.Duration(appConfig.SyncInterval) * time.Minute)
-		go func() {
-			safeGo := func(isFull bool) {
-				go func() {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Error("捕获到未处理的 panic: %v", r)
-						}
-					}()
-					runSync(isFull, httpClient)
-				}()
-			}
-			safeGo(false)
-			for range ticker.C {
-				safeGo(false)
-			}
-		}()
+	httpClient = sync_lib.NewHTTPClient(30 * time.Second)
+}
+
+// serve 加载配置并运行 Web UI，直到 ctx 被取消或服务器出错。
+// 退出前会停止定时任务、关闭服务器并保存缓存。
+func serve(ctx context.Context) error {
+	setup()
+
+	if appConfig.Password != "" {
+		store = newSessionStore(*appConfig)
+	}
+
+	if _, err := schedule.New(0, 0, appConfig.SyncWindow, appConfig.SyncTimezone); err != nil {
+		log.Error("同步时间窗口配置无效: %v", err)
+		return err
 	}
 
+	loadWebdavCache()
+
+	specs, err := jobs.LoadSpecs(appConfig)
+	if err != nil {
+		log.Error("加载同步任务失败: %v", err)
+		return err
+	}
+	manager = jobs.NewManager(specs, appConfig.JobsFile, loadHistory(), currentConfig, hub, log, httpClient)
+	manager.Start()
+
 	mux := http.NewServeMux()
 	fs := http.FileServer(http.Dir("./public"))
 	mux.Handle("/", authMiddleware(fs))
@@ -88,16 +127,108 @@ func main() {
 	mux.HandleFunc("/login", loginHandler)
 	mux.Handle("/api/sync", authMiddleware(http.HandlerFunc(syncHandler)))
 	mux.Handle("/api/config", authMiddleware(http.HandlerFunc(configHandler)))
+	mux.Handle("/api/duplicates", authMiddleware(http.HandlerFunc(duplicatesHandler)))
+	mux.Handle("/api/verify", authMiddleware(http.HandlerFunc(verifyHandler)))
+	mux.Handle("/api/mapping", authMiddleware(http.HandlerFunc(mappingHandler)))
+	mux.Handle("/api/state", authMiddleware(http.HandlerFunc(stateHandler)))
+	mux.Handle("/api/retries", authMiddleware(http.HandlerFunc(retriesHandler)))
+	mux.Handle("/api/poll", authMiddleware(http.HandlerFunc(pollHandler)))
+	mux.Handle("/api/reload", authMiddleware(http.HandlerFunc(reloadHandler)))
+	mux.Handle("/api/history", statsAuth(http.HandlerFunc(historyHandler)))
+	mux.Handle("/api/grafana/", statsAuth(http.StripPrefix("/api/grafana", grafana.Handler(manager.History()))))
+	mux.Handle("/api/jobs", authMiddleware(http.HandlerFunc(jobsHandler)))
+	mux.Handle("/api/jobs/{id}", authMiddleware(http.HandlerFunc(jobHandler)))
+	mux.Handle("/api/jobs/{id}/{action}", authMiddleware(http.HandlerFunc(jobActionHandler)))
+	mux.Handle("/api/queue", authMiddleware(http.HandlerFunc(queueHandler)))
+	mux.Handle("/api/queue/{seq}", authMiddleware(http.HandlerFunc(queueItemHandler)))
 	mux.HandleFunc("/api/check-auth", checkAuthHandler)
 
-	log.Info("服务器启动，监听端口: %s", appConfig.Port)
-	if err := http.ListenAndServe(":"+appConfig.Port, mux); err != nil {
+	listener, err := net.Listen("tcp", ":"+appConfig.Port)
+	if err != nil {
 		log.Error("服务器启动失败: %v", err)
+		manager.Stop()
+		return err
+	}
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	serveErr := make(chan error, 1)
+	server := &http.Server{Handler: mux}
+	go func() {
+		log.Info("服务器启动，监听端口: %s", appConfig.Port)
+		err := server.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Error("服务器运行失败: %v", err)
+			stop()
+		}
+		serveErr <- err
+	}()
+
+	// 在 systemd 下以 Type=notify 运行时，端口开始监听后才报告就绪；
+	// 看门狗心跳会先确认任务管理器仍能响应，内部死锁时由 systemd 重启服务
+	if ok, err := sdnotify.Ready(); err != nil {
+		log.Warn("通知 systemd 失败: %v", err)
+	} else if ok {
+		log.Debug("已通知 systemd 服务就绪")
+	}
+	go sdnotify.Watchdog(ctx.Done(), func() bool {
+		manager.List()
+		return true
+	})
+
+	<-ctx.Done()
+	log.Info("正在关闭服务器...")
+	sdnotify.Stopping()
+	manager.Stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Warn("关闭服务器失败: %v", err)
+	}
+	saveWebdavCache()
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return nil
+}
+
+// loadWebdavCache 从 WEBDAV_CACHE_FILE 恢复上次退出时保存的 WebDAV 文件列表缓存。
+func loadWebdavCache() {
+	if appConfig.WebdavCacheFile == "" {
+		return
+	}
+	count, err := sync_lib.LoadWebdavCache(appConfig.WebdavCacheFile, time.Duration(appConfig.WebdavCacheTTL)*time.Minute)
+	if err != nil {
+		log.Warn("恢复 WebDAV 缓存失败，将重新获取文件列表: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Info("已从 %s 恢复 %d 个同步目标的 WebDAV 文件列表缓存", appConfig.WebdavCacheFile, count)
+	}
+}
+
+// saveWebdavCache 将 WebDAV 文件列表缓存保存到 WEBDAV_CACHE_FILE。
+func saveWebdavCache() {
+	file := currentConfig().WebdavCacheFile
+	if file == "" {
+		return
+	}
+	if _, err := sync_lib.SaveWebdavCache(file); err != nil {
+		log.Warn("保存 WebDAV 缓存失败: %v", err)
+	}
+}
+
+// loadHistory 从 SYNC_HISTORY_FILE 加载同步运行记录。加载失败时从空记录开始。
+func loadHistory() *jobs.History {
+	history, err := jobs.LoadHistory(appConfig.HistoryFile, appConfig.HistoryLimit)
+	if err != nil {
+		log.Warn("加载运行记录失败，将从空记录开始: %v", err)
+	}
+	return history
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
-	if appConfig.Password == "" {
+	cfg := currentConfig()
+	if cfg.Password == "" {
 		http.Error(w, "未设置密码，无需登录", http.StatusBadRequest)
 		return
 	}
@@ -109,19 +240,28 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	var creds struct {
 		Password string `json:"password"`
+		Remember bool   `json:"remember"` // 记住登录状态，会话在 REMEMBER_ME_DAYS 天后才过期
 	}
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		http.Error(w, "无效的请求", http.StatusBadRequest)
 		return
 	}
 
-	if creds.Password != appConfig.Password {
+	if creds.Password != cfg.Password {
 		http.Error(w, "密码错误", http.StatusUnauthorized)
 		return
 	}
 
-	session, _ := store.Get(r, "session-name")
+	session, _ := currentStore().Get(r, "session-name")
 	session.Values["authenticated"] = true
+	ttl := sessionTTL(cfg, creds.Remember)
+	session.Values["expiresAt"] = time.Now().Add(ttl).Unix()
+	if creds.Remember && cfg.RememberDays > 0 {
+		session.Options.MaxAge = int(ttl.Seconds())
+	} else {
+		// 普通会话使用浏览器会话 Cookie，关闭浏览器即失效
+		session.Options.MaxAge = 0
+	}
 	err := session.Save(r, w)
 	if err != nil {
 		log.Error("保存 session 失败: %v", err)
@@ -132,24 +272,19 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// checkAuthHandler 返回当前请求是否已登录，以及登录页是否应提供“记住我”选项 (rememberDays > 0)。
 func checkAuthHandler(w http.ResponseWriter, r *http.Request) {
-	if appConfig.Password == "" {
-		json.NewEncoder(w).Encode(map[string]bool{"authenticated": true})
-		return
-	}
-
-	session, _ := store.Get(r, "session-name")
-	auth, ok := session.Values["authenticated"].(bool)
-	if !ok || !auth {
-		json.NewEncoder(w).Encode(map[string]bool{"authenticated": false})
+	cfg := currentConfig()
+	if cfg.Password == "" {
+		json.NewEncoder(w).Encode(map[string]any{"authenticated": true})
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]bool{"authenticated": true})
+	json.NewEncoder(w).Encode(map[string]any{"authenticated": authenticated(r), "rememberDays": cfg.RememberDays})
 }
 
 func authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if appConfig.Password == "" {
+		if currentConfig().Password == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -160,10 +295,7 @@ func authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		session, _ := store.Get(r, "session-name")
-		auth, ok := session.Values["authenticated"].(bool)
-
-		if !ok || !auth {
+		if !authenticated(r) {
 			// 如果是 API 请求，返回 401
 			if strings.HasPrefix(r.URL.Path, "/api/") {
 				http.Error(w, "未授权", http.StatusUnauthorized)
@@ -178,6 +310,21 @@ func authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// statsAuth 允许携带 STATS_TOKEN 的请求（Authorization: Bearer <STATS_TOKEN>）直接访问统计接口，
+// 使 Grafana 等工具无需登录；其他请求与普通接口一样需要登录。
+func statsAuth(next http.Handler) http.Handler {
+	protected := authMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := currentConfig().StatsToken
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// syncHandler 将一次手动同步加入队列。查询参数 job 指定任务 ID，缺省为第一个任务。
 func syncHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "只允许 POST 方法", http.StatusMethodNotAllowed)
@@ -186,17 +333,133 @@ func syncHandler(w http.ResponseWriter, r *http.Request) {
 	mode := r.URL.Query().Get("mode")
 	isFullSync := mode == "full"
 
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Error("捕获到未处理的 panic: %v", r)
-			}
-		}()
-		runSync(isFullSync, httpClient)
-	}()
+	if err := manager.Trigger(r.URL.Query().Get("job"), isFullSync); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("同步任务已启动..."))
+	w.Write([]byte("同步任务已加入队列..."))
+}
+
+// queueHandler 返回正在运行和排队中的同步请求。
+func queueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manager.Queue())
+}
+
+// queueItemHandler 处理 DELETE /api/queue/{seq}，取消一个尚未开始的同步请求。
+func queueItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "只允许 DELETE 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	seq, err := strconv.ParseInt(r.PathValue("seq"), 10, 64)
+	if err != nil {
+		http.Error(w, "无效的请求序号", http.StatusBadRequest)
+		return
+	}
+	if err := manager.Cancel(seq); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jobsHandler 管理同步任务列表。
+//   - GET：返回所有同步任务的配置（不含凭据）和运行状态。
+//   - POST：以 JSON 请求体创建一个新任务，并写入任务文件。
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(manager.List())
+
+	case http.MethodPost:
+		var spec jobs.Spec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "无效的请求体", http.StatusBadRequest)
+			return
+		}
+		if err := manager.Create(spec); err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "不支持的方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// jobHandler 修改或删除单个同步任务。
+//   - PUT：以 JSON 请求体替换任务配置。凭据字段为 "******" 时保留原值。
+//   - DELETE：删除任务。
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodPut:
+		var spec jobs.Spec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "无效的请求体", http.StatusBadRequest)
+			return
+		}
+		spec.ID = id
+		if err := manager.Update(spec); err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := manager.Delete(id); err != nil {
+			writeJobError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "不支持的方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// jobActionHandler 处理 POST /api/jobs/{id}/enable 和 /api/jobs/{id}/disable。
+func jobActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	var err error
+	switch r.PathValue("action") {
+	case "enable":
+		err = manager.SetEnabled(r.PathValue("id"), true)
+	case "disable":
+		err = manager.SetEnabled(r.PathValue("id"), false)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJobError 根据任务操作的错误类型返回合适的状态码。
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound), errors.Is(err, jobs.ErrNotQueued):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, jobs.ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
 }
 
 func configHandler(w http.ResponseWriter, r *http.Request) {
@@ -229,37 +492,259 @@ func configHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func runSync(isFullSync bool, httpClient *http.Client) {
-	if !syncMutex.TryLock() {
-		log.Warn("同步任务已在运行中，本次请求被跳过")
-		wsLogger := logger.NewWebsocketLogger(hub, log, logger.StringToLogLevel(appConfig.LogLevel))
-		wsLogger.Warn("同步任务已在运行中，本次请求被跳过")
+// duplicatesHandler 扫描 WebDAV 同步目录并返回重复文件报告。
+// 该操作只读，不占用同步锁，但会下载所有大小相同的文件，耗时可能较长。
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
 		return
 	}
-	defer syncMutex.Unlock()
 
-	wsLogger := logger.NewWebsocketLogger(hub, log, logger.StringToLogLevel(appConfig.LogLevel))
-	wsLogger.Info("")
-	hub.Broadcast(websocket.Message{Type: "syncStatus", Content: "syncing"})
+	syncConfig, err := jobConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 
-	configMutex.RLock()
-	activeConfig := *appConfig
-	configMutex.RUnlock()
+	report, err := sync_lib.FindDuplicates(r.Context(), log, syncConfig, httpClient)
+	if err != nil {
+		log.Error("生成重复文件报告失败: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// verifyHandler 校验 WebDAV 上的备份图片是否损坏，并返回校验报告。
+// 与重复文件报告一样，该操作只读，但需要下载所有图片。
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	syncConfig, err := jobConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	report, err := sync_lib.Verify(r.Context(), log, syncConfig, httpClient)
+	if err != nil {
+		log.Error("校验备份失败: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// mappingHandler 以文件下载的形式返回 NodeImage 直链到 WebDAV 路径的映射。
+// 查询参数 format 可选 json（默认）或 csv。
+func mappingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "无效的 format 参数: "+format+"，可选值为 json 或 csv", http.StatusBadRequest)
+		return
+	}
+
+	syncConfig, err := jobConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	mappings, err := sync_lib.BuildURLMapping(r.Context(), log, syncConfig, httpClient)
+	if err != nil {
+		log.Error("生成链接映射失败: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=nodeimage-mapping."+format)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		sync_lib.WriteURLMappingCSV(w, mappings)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mappings)
+}
+
+// stateHandler 导出或导入同步引擎的状态归档 (tar.gz)，用于迁移到另一台主机。
+//   - GET：下载状态归档。
+//   - POST：以请求体上传状态归档并导入。导入期间持有同步锁，若有同步正在运行则返回 409。
+func stateHandler(w http.ResponseWriter, r *http.Request) {
+	syncConfig, err := jobConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename=nodeimage-sync-state.tar.gz")
+		if err := sync_lib.ExportState(r.Context(), log, syncConfig, httpClient, w); err != nil {
+			// 响应头可能已发送，只能记录日志
+			log.Error("导出状态失败: %v", err)
+		}
+
+	case http.MethodPost:
+		var imported int
+		result, ran, _ := manager.Do(r.URL.Query().Get("job"), func() sync_lib.Result {
+			n, err := sync_lib.ImportState(r.Context(), log, syncConfig, httpClient, http.MaxBytesReader(w, r.Body, 256<<20))
+			imported = n
+			if err != nil {
+				return sync_lib.Result{Success: false, Message: err.Error(), Error: err}
+			}
+			return sync_lib.Result{Success: true}
+		})
+		if !ran {
+			http.Error(w, "同步任务正在运行中，请稍后再导入", http.StatusConflict)
+			return
+		}
+		if !result.Success {
+			log.Error("导入状态失败: %v", result.Error)
+			http.Error(w, result.Message, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"imported": imported})
+
+	default:
+		http.Error(w, "不支持的方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// retriesHandler 查看或清空重试队列，即此前重试耗尽后仍上传失败、等待之后的同步优先重试的文件。
+//   - GET：返回队列中的文件，按首次失败的时间排序。
+//   - DELETE：清空队列，放弃重试其中的文件。全量同步仍会补上 NodeImage 上存在而 WebDAV 上缺少的文件。
+func retriesHandler(w http.ResponseWriter, r *http.Request) {
+	syncConfig, err := jobConfig(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		retries, err := sync_lib.Retries(syncConfig)
+		if err != nil {
+			log.Error("读取重试队列失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(retries)
+
+	case http.MethodDelete:
+		cleared, err := sync_lib.ClearRetries(syncConfig)
+		if err != nil {
+			log.Error("清空重试队列失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Info("已清空重试队列中的 %d 个文件", cleared)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"cleared": cleared})
+
+	default:
+		http.Error(w, "不支持的方法", http.StatusMethodNotAllowed)
+	}
+}
 
-	syncConfig := sync_lib.Config{
-		NodeImageCookie: activeConfig.NodeImageCookie,
-		NodeImageAPIKey: activeConfig.NodeImageAPIKey,
-		NodeImageAPIURL: activeConfig.NodeImageAPIURL,
-		WebdavURL:       activeConfig.WebdavURL,
-		WebdavUsername:  activeConfig.WebdavUsername,
-		WebdavPassword:  activeConfig.WebdavPassword,
-		WebdavBasePath:  activeConfig.WebdavBasePath,
-		SyncConcurrency: activeConfig.SyncConcurrency,
+// pollHandler 是 WebSocket 的长轮询替代：返回序号大于 cursor 的日志和状态消息，没有新消息时最多等待 timeout 秒。
+// 适合 WebSocket 和 SSE 都被代理拦截的环境。查询参数均可选：
+//   - cursor：上一次响应中的 cursor，缺省为 0，即返回最近的全部消息。
+//   - topic：只返回该任务的消息（以及不属于任何任务的消息），与 /ws 的 topic 参数相同。
+//   - timeout：没有新消息时的最长等待时间（秒，1-60），默认 25。
+//
+// 响应中的 lost 为 true 表示客户端太久没有轮询（或服务已重启），cursor 之后的部分消息已无法获取。
+func pollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
+		return
 	}
+	query := r.URL.Query()
+	var cursor uint64
+	if v := query.Get("cursor"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "无效的 cursor 参数: "+v, http.StatusBadRequest)
+			return
+		}
+		cursor = n
+	}
+	timeout := 25
+	if v := query.Get("timeout"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 60 {
+			http.Error(w, "无效的 timeout 参数: "+v+"，取值范围为 1-60", http.StatusBadRequest)
+			return
+		}
+		timeout = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	events, next, lost := hub.Since(ctx, cursor, query.Get("topic"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{"cursor": next, "events": events, "lost": lost})
+}
 
-	result := sync_lib.RunSync(context.Background(), wsLogger, syncConfig, isFullSync, httpClient)
+// historyHandler 以 JSON 数组返回同步运行记录，按结束时间排列，适合 Grafana Infinity 等通用 JSON 数据源。
+// 查询参数均可选：job 只返回该任务的记录；from、to 为 RFC 3339 时间或 Unix 毫秒时间戳，限制结束时间的范围。
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只允许 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v)
+		if err != nil {
+			http.Error(w, "无效的 "+name+" 参数: "+v, http.StatusBadRequest)
+			return
+		}
+		bounds[i] = t
+	}
+
+	runs := manager.History().Runs(query.Get("job"), bounds[0], bounds[1])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// parseTime 解析 RFC 3339 时间或 Unix 毫秒时间戳（Grafana 的 ${__from} 变量）。
+func parseTime(v string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// currentConfig 返回当前应用配置的副本。
+func currentConfig() config.Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return *appConfig
+}
 
-	resultJSON, _ := json.Marshal(result)
-	hub.Broadcast(websocket.Message{Type: "syncResult", Content: string(resultJSON)})
-	hub.Broadcast(websocket.Message{Type: "syncStatus", Content: "idle"})
+// jobConfig 返回请求中 job 参数指定的任务（缺省为第一个任务）实际使用的同步配置。
+func jobConfig(r *http.Request) (sync_lib.Config, error) {
+	return manager.Config(r.URL.Query().Get("job"))
 }
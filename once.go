@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/jobs"
+)
+
+// onceFlag 是 --once 参数。它可以不带值（增量同步），也可以写作 --once=full 或 --once full。
+type onceFlag struct {
+	set  bool
+	full bool
+}
+
+func (f *onceFlag) String() string {
+	if f.full {
+		return "full"
+	}
+	return "incremental"
+}
+
+func (f *onceFlag) Set(value string) error {
+	switch value {
+	case "true", "incremental":
+		f.full = false
+	case "full":
+		f.full = true
+	default:
+		return fmt.Errorf("无效的同步模式 %q，可选 full 或 incremental", value)
+	}
+	f.set = true
+	return nil
+}
+
+// IsBoolFlag 使 --once 可以不带值使用。
+func (f *onceFlag) IsBoolFlag() bool { return true }
+
+// parseFlags 解析 Web UI 程序的命令行参数。参数无效时输出用法并以退出码 2 退出。
+func parseFlags(args []string) (once onceFlag, job string) {
+	fs := flag.NewFlagSet("nodeimage-sync", flag.ExitOnError)
+	fs.Var(&once, "once", "执行一次同步后退出，不启动 Web UI；可选模式 full 或 incremental（默认）")
+	fs.StringVar(&job, "job", "", "与 --once 一起使用，指定要同步的任务 ID，默认为第一个任务")
+	fs.Parse(args)
+
+	// --once 是布尔式参数，“--once full” 中的模式会被当作位置参数
+	rest := fs.Args()
+	if once.set && len(rest) > 0 {
+		if err := once.Set(rest[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "未知的参数: %v\n", rest)
+		fs.Usage()
+		os.Exit(2)
+	}
+	return once, job
+}
+
+// runOnce 加载配置后通过 jobs.RunOnce 执行一次同步，返回进程退出码。
+func runOnce(ctx context.Context, id string, isFullSync bool) int {
+	setup()
+	return jobs.RunOnce(ctx, appConfig, hub, log, httpClient, id, isFullSync)
+}
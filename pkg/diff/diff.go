@@ -0,0 +1,161 @@
+// package diff 对比源端（NodeImage）和目标端（WebDAV）的文件列表，找出需要上传、删除的文件以及两侧的对应关系。
+// 同步、链接映射等功能共用这里的对比规则，避免各处的实现逐渐出现差异。
+package diff
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchBy 决定两侧的文件按什么对应。
+type MatchBy int
+
+const (
+	// ByName 按文件名对应（默认）。
+	ByName MatchBy = iota
+	// ByID 优先按图片 ID 对应，源文件改名后不会被重复上传；没有按 ID 对应上的源文件再按文件名对应。
+	// 目标文件的 ID 来自 Options.IDs，未列出的目标文件以去掉扩展名后的文件名作为 ID。
+	ByID
+)
+
+// Source 是源端的一个文件。
+type Source struct {
+	ID   string
+	Name string
+}
+
+// Target 是目标端的一个文件。
+type Target struct {
+	Path string // 完整路径，对应时只使用最后一段
+}
+
+// Options 控制对比规则，零值表示按文件名原样对比。
+type Options struct {
+	MatchBy   MatchBy
+	Ignore    func(path string) bool // 可选，返回 true 的目标文件不参与对比，既不会被对应也不会被删除
+	IDs       map[string]string      // 可选，ByID 时目标文件名到图片 ID 的映射（例如来自边车元数据）
+	Normalize func(string) string    // 可选，对应前规范化文件名和 ID，例如统一为 Unicode NFC 形式
+}
+
+// Match 是一对对应的文件。
+type Match struct {
+	Source int    // 源文件在 sources 中的下标
+	Target string // 目标文件的路径
+}
+
+// Result 是对比的结果。
+type Result struct {
+	Upload  []int    // 需要上传的源文件下标，按源列表的顺序，包括目标文件属于另一张图片而需要覆盖上传的源文件
+	Delete  []string // 没有对应源文件的目标文件路径，按目标列表的顺序
+	Matched []Match  // 已对应的文件，按源列表的顺序
+}
+
+// Compare 按 opts 对比 sources 和 targets。
+// 多个源文件对应同一个目标文件时，它们都视为已存在；多个目标文件对应同一个键时（例如规范化后同名），
+// 它们都不会被删除，源文件与其中第一个对应。
+// 按 ID 对应时，如果目标文件的文件名正被另一个源文件使用（例如改名后原文件名被新图片占用），
+// 则不按 ID 对应，避免两张图片共用同一个目标文件；按文件名对应上的目标文件如果记录的是另一张图片的 ID，
+// 该源文件会被列入 Upload 以覆盖上传。
+func Compare(sources []Source, targets []Target, opts Options) Result {
+	byName := make(map[string][]int, len(targets))
+	byID := make(map[string][]int)
+	for j, t := range targets {
+		if opts.Ignore != nil && opts.Ignore(t.Path) {
+			continue
+		}
+		name := opts.fold(path.Base(t.Path))
+		byName[name] = append(byName[name], j)
+		if opts.MatchBy == ByID {
+			id := opts.targetID(t.Path)
+			byID[id] = append(byID[id], j)
+		}
+	}
+
+	matches := make([][]int, len(sources))
+	claimed := make([]bool, len(targets)) // 已按 ID 对应的目标文件，不再参与按文件名对应
+	stale := make([]bool, len(sources))   // 按文件名对应上、但目标文件记录的是另一张图片的源文件
+	if opts.MatchBy == ByID {
+		names := make(map[string][]int, len(sources))
+		for i, s := range sources {
+			name := opts.fold(s.Name)
+			names[name] = append(names[name], i)
+		}
+		for i, s := range sources {
+			if s.ID == "" {
+				continue
+			}
+			for _, j := range byID[opts.fold(s.ID)] {
+				if !usedByOther(names[opts.fold(path.Base(targets[j].Path))], i) {
+					matches[i] = append(matches[i], j)
+					claimed[j] = true
+				}
+			}
+		}
+	}
+	for i, s := range sources {
+		if len(matches[i]) > 0 {
+			continue
+		}
+		for _, j := range byName[opts.fold(s.Name)] {
+			if claimed[j] {
+				continue
+			}
+			matches[i] = append(matches[i], j)
+			if id, ok := opts.IDs[path.Base(targets[j].Path)]; ok && opts.MatchBy == ByID && s.ID != "" && opts.fold(id) != opts.fold(s.ID) {
+				stale[i] = true
+			}
+		}
+	}
+
+	var result Result
+	used := make([]bool, len(targets))
+	for i := range sources {
+		if len(matches[i]) == 0 {
+			result.Upload = append(result.Upload, i)
+			continue
+		}
+		for _, j := range matches[i] {
+			used[j] = true
+		}
+		if stale[i] {
+			result.Upload = append(result.Upload, i)
+			continue
+		}
+		result.Matched = append(result.Matched, Match{Source: i, Target: targets[matches[i][0]].Path})
+	}
+
+	for j, t := range targets {
+		if used[j] || opts.Ignore != nil && opts.Ignore(t.Path) {
+			continue
+		}
+		result.Delete = append(result.Delete, t.Path)
+	}
+	return result
+}
+
+// usedByOther 判断 owners 中是否有 i 以外的源文件。
+func usedByOther(owners []int, i int) bool {
+	for _, owner := range owners {
+		if owner != i {
+			return true
+		}
+	}
+	return false
+}
+
+// targetID 返回目标文件的图片 ID：优先使用 IDs 中的记录，否则为去掉扩展名后的文件名。
+func (o Options) targetID(p string) string {
+	name := path.Base(p)
+	if id, ok := o.IDs[name]; ok {
+		return o.fold(id)
+	}
+	return o.fold(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// fold 返回用于对应的键，即按 Normalize 规范化后的文件名或 ID。
+func (o Options) fold(key string) string {
+	if o.Normalize != nil {
+		return o.Normalize(key)
+	}
+	return key
+}
@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	// nfc 是测试用的简化规范化：只把 "e" + 组合重音符合成为 "é"
+	nfc := func(s string) string { return strings.ReplaceAll(s, "e\u0301", "\u00e9") }
+
+	tests := []struct {
+		name    string
+		sources []Source
+		targets []Target
+		opts    Options
+		want    Result
+	}{
+		{
+			name: "两侧都为空",
+		},
+		{
+			name:    "目标为空时全部上传",
+			sources: []Source{{Name: "a.png"}, {Name: "b.png"}},
+			want:    Result{Upload: []int{0, 1}},
+		},
+		{
+			name:    "源为空时全部删除",
+			targets: []Target{{Path: "/d/a.png"}, {Path: "/d/b.png"}},
+			want:    Result{Delete: []string{"/d/a.png", "/d/b.png"}},
+		},
+		{
+			name:    "按文件名对应",
+			sources: []Source{{Name: "a.png"}, {Name: "b.png"}},
+			targets: []Target{{Path: "/d/b.png"}, {Path: "/d/c.png"}},
+			want: Result{
+				Upload:  []int{0},
+				Delete:  []string{"/d/c.png"},
+				Matched: []Match{{Source: 1, Target: "/d/b.png"}},
+			},
+		},
+		{
+			name:    "文件名区分大小写",
+			sources: []Source{{Name: "A.png"}},
+			targets: []Target{{Path: "/d/a.png"}},
+			want:    Result{Upload: []int{0}, Delete: []string{"/d/a.png"}},
+		},
+		{
+			name:    "被忽略的目标文件既不对应也不删除",
+			sources: []Source{{Name: "a.png.part"}},
+			targets: []Target{{Path: "/d/a.png.part"}, {Path: "/d/b.png"}},
+			opts:    Options{Ignore: func(p string) bool { return strings.HasSuffix(p, ".part") }},
+			want:    Result{Upload: []int{0}, Delete: []string{"/d/b.png"}},
+		},
+		{
+			name:    "规范化后对应",
+			sources: []Source{{Name: "caf\u00e9.png"}},
+			targets: []Target{{Path: "/d/cafe\u0301.png"}},
+			opts:    Options{Normalize: nfc},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/cafe\u0301.png"}}},
+		},
+		{
+			name:    "不规范化时视为不同文件",
+			sources: []Source{{Name: "caf\u00e9.png"}},
+			targets: []Target{{Path: "/d/cafe\u0301.png"}},
+			want:    Result{Upload: []int{0}, Delete: []string{"/d/cafe\u0301.png"}},
+		},
+		{
+			name:    "多个源文件对应同一个目标文件",
+			sources: []Source{{Name: "a.png"}, {Name: "a.png"}},
+			targets: []Target{{Path: "/d/a.png"}},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/a.png"}, {Source: 1, Target: "/d/a.png"}}},
+		},
+		{
+			name:    "多个目标文件对应同一个键时都不删除",
+			sources: []Source{{Name: "caf\u00e9.png"}},
+			targets: []Target{{Path: "/d/cafe\u0301.png"}, {Path: "/d/caf\u00e9.png"}},
+			opts:    Options{Normalize: nfc},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/cafe\u0301.png"}}},
+		},
+		{
+			name:    "按 ID 对应改名的文件",
+			sources: []Source{{ID: "abc", Name: "new.png"}},
+			targets: []Target{{Path: "/d/old.png"}},
+			opts:    Options{MatchBy: ByID, IDs: map[string]string{"old.png": "abc"}},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/old.png"}}},
+		},
+		{
+			name:    "未记录 ID 的目标文件以文件名作为 ID",
+			sources: []Source{{ID: "abc", Name: "new.png"}},
+			targets: []Target{{Path: "/d/abc.jpg"}},
+			opts:    Options{MatchBy: ByID},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/abc.jpg"}}},
+		},
+		{
+			name:    "按文件名对应时不使用 ID",
+			sources: []Source{{ID: "abc", Name: "new.png"}},
+			targets: []Target{{Path: "/d/old.png"}},
+			opts:    Options{IDs: map[string]string{"old.png": "abc"}},
+			want:    Result{Upload: []int{0}, Delete: []string{"/d/old.png"}},
+		},
+		{
+			name:    "没有 ID 的源文件按文件名对应",
+			sources: []Source{{Name: "a.png"}},
+			targets: []Target{{Path: "/d/a.png"}},
+			opts:    Options{MatchBy: ByID},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/a.png"}}},
+		},
+		{
+			name:    "原文件名被另一个源文件占用时不按 ID 对应",
+			sources: []Source{{ID: "abc", Name: "renamed.png"}, {ID: "xyz", Name: "old.png"}},
+			targets: []Target{{Path: "/d/old.png"}},
+			opts:    Options{MatchBy: ByID, IDs: map[string]string{"old.png": "abc"}},
+			want:    Result{Upload: []int{0, 1}},
+		},
+		{
+			name:    "ID 与文件名使用相同的规范化",
+			sources: []Source{{ID: "cafe\u0301", Name: "new.png"}},
+			targets: []Target{{Path: "/d/old.png"}},
+			opts:    Options{MatchBy: ByID, Normalize: nfc, IDs: map[string]string{"old.png": "caf\u00e9"}},
+			want:    Result{Matched: []Match{{Source: 0, Target: "/d/old.png"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.sources, tt.targets, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
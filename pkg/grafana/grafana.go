@@ -0,0 +1,130 @@
+// package grafana 实现了 Grafana JSON 数据源插件 (simpod-json-datasource) 所需的 HTTP 接口，
+// 使 Grafana 无需 Prometheus 即可直接查询本服务的数据。
+// 数据本身由调用方通过 Source 接口提供，本包只负责协议。
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Metric 是 /metrics 返回的一个可供查询的指标，Value 即查询时的 target。
+type Metric struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Target 是查询中的一个目标。
+type Target struct {
+	Target  string          `json:"target"`
+	RefID   string          `json:"refId"`
+	Hide    bool            `json:"hide"`
+	Payload json.RawMessage `json:"payload"` // 可选，查询编辑器中填写的附加参数 (JSON)
+}
+
+// Query 是一次 /query 请求。
+type Query struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	MaxDataPoints int      `json:"maxDataPoints"`
+	Targets       []Target `json:"targets"`
+}
+
+// TimeSeries 是时间序列形式的查询结果。每个数据点为 [值, Unix 毫秒时间戳]。
+type TimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Point 返回时间 t 处值为 value 的数据点。
+func Point(value float64, t time.Time) [2]float64 {
+	return [2]float64{value, float64(t.UnixMilli())}
+}
+
+// Column 是表格结果中的一列。Type 可选 "time"、"string" 或 "number"。
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// Table 是表格形式的查询结果。
+type Table struct {
+	Type    string   `json:"type"` // 固定为 "table"
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// NewTable 创建一个空表格。
+func NewTable(columns ...Column) *Table {
+	return &Table{Type: "table", Columns: columns, Rows: [][]any{}}
+}
+
+// Source 提供数据源的指标和查询结果。
+type Source interface {
+	// Metrics 返回所有可供查询的指标。
+	Metrics() []Metric
+	// Query 返回一个目标的查询结果，每个元素为 TimeSeries 或 *Table。
+	Query(q *Query, t Target) ([]any, error)
+}
+
+// Handler 返回实现 JSON 数据源协议的 http.Handler，应挂载在数据源地址下（通常配合 http.StripPrefix）：
+//   - GET /：连接测试，返回 200。
+//   - POST /metrics：返回可供查询的指标。
+//   - POST /search：旧版插件使用的指标列表，只包含指标名。
+//   - POST /metric-payload-options：附加参数的可选值，本实现总是返回空列表。
+//   - POST /query：返回各目标的查询结果。
+func Handler(source Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "/" + strings.Trim(r.URL.Path, "/")
+		if route == "/" {
+			w.Write([]byte("OK"))
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "只允许 POST 方法", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var response any
+		switch route {
+		case "/metrics":
+			response = source.Metrics()
+		case "/search":
+			names := []string{}
+			for _, m := range source.Metrics() {
+				names = append(names, m.Value)
+			}
+			response = names
+		case "/metric-payload-options":
+			response = []any{}
+		case "/query":
+			var q Query
+			if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+				http.Error(w, "无效的查询: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			results := []any{}
+			for _, t := range q.Targets {
+				if t.Hide || t.Target == "" {
+					continue
+				}
+				result, err := source.Query(&q, t)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				results = append(results, result...)
+			}
+			response = results
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
@@ -0,0 +1,77 @@
+// package healthchecks 实现了向 Healthchecks.io（或自建的兼容服务）发送 ping 的最小客户端。
+// 每次同步开始时发送 /start，结束时按结果发送成功或 /fail，并在请求体中附上运行摘要；
+// 同步没有按时运行或失败时，由 Healthchecks 负责发出告警。
+package healthchecks
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 发送的信号类型。
+const (
+	Success = ""      // 运行成功
+	Start   = "start" // 运行开始，Healthchecks 据此计算运行耗时
+	Fail    = "fail"  // 运行失败
+)
+
+// maxBodySize 是 Healthchecks 保存的请求体大小上限，超出部分会被截断。
+const maxBodySize = 100_000
+
+// Ping 向 pingURL 发送一个信号，body 会作为运行日志显示在 Healthchecks 中。
+// runID 用于把同一次运行的开始和结束信号关联起来，可以为空。
+func Ping(ctx context.Context, httpClient *http.Client, pingURL, signal, runID, body string) error {
+	u, err := url.Parse(strings.TrimRight(pingURL, "/"))
+	if err != nil {
+		return fmt.Errorf("无法解析 Healthchecks 地址: %w", err)
+	}
+	if signal != Success {
+		u.Path += "/" + signal
+	}
+	if runID != "" {
+		q := u.Query()
+		q.Set("rid", runID)
+		u.RawQuery = q.Encode()
+	}
+	if len(body) > maxBodySize {
+		body = body[:maxBodySize]
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 Healthchecks 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 错误信息中不包含 ping 地址，避免泄露其中的密钥
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("发送 Healthchecks ping 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Healthchecks 返回了非预期的状态码: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewRunID 生成一个随机的运行 ID (UUID v4)。
+func NewRunID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
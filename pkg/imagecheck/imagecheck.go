@@ -0,0 +1,155 @@
+// package imagecheck 检查图片文件的内容是否与其声明的格式相符。
+// 它不完整解码像素数据，只校验文件头和容器结构（PNG 的分块与 CRC、JPEG 的段结构、
+// WebP 的 RIFF 长度等），足以发现截断、被替换为 HTML 错误页或部分写入的备份文件。
+package imagecheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+)
+
+// ErrUnsupported 表示文件扩展名不是可校验的图片格式。
+var ErrUnsupported = errors.New("不支持校验的文件格式")
+
+// Format 根据文件扩展名返回声明的图片格式（jpeg、png、webp、gif），不支持时返回空字符串。
+func Format(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".webp":
+		return "webp"
+	case ".gif":
+		return "gif"
+	default:
+		return ""
+	}
+}
+
+// Check 校验 data 是否是一个结构完整的、与文件名扩展名相符的图片。
+// 扩展名不受支持时返回 ErrUnsupported。
+func Check(name string, data []byte) error {
+	switch format := Format(name); format {
+	case "jpeg":
+		return checkJPEG(data)
+	case "png":
+		return checkPNG(data)
+	case "webp":
+		return checkWebP(data)
+	case "gif":
+		return checkGIF(data)
+	default:
+		return ErrUnsupported
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// checkPNG 校验 PNG 签名，并遍历所有分块检查长度和 CRC，要求以 IHDR 开始、以 IEND 结束。
+func checkPNG(data []byte) error {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return fmt.Errorf("PNG 签名不正确")
+	}
+	rest := data[len(pngSignature):]
+	first := true
+	for {
+		if len(rest) < 12 {
+			return fmt.Errorf("PNG 文件被截断，缺少 IEND 分块")
+		}
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint64(length)+12 > uint64(len(rest)) {
+			return fmt.Errorf("PNG 分块长度超出文件末尾，文件可能被截断")
+		}
+		chunkType := string(rest[4:8])
+		body := rest[8 : 8+length]
+		crc := binary.BigEndian.Uint32(rest[8+length : 12+length])
+		if crc32.ChecksumIEEE(rest[4:8+length]) != crc {
+			return fmt.Errorf("PNG 分块 %q 的 CRC 校验失败", chunkType)
+		}
+		if first && (chunkType != "IHDR" || len(body) != 13) {
+			return fmt.Errorf("PNG 第一个分块不是有效的 IHDR")
+		}
+		first = false
+		rest = rest[12+length:]
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
+
+// checkJPEG 校验 SOI 标记，并遍历扫描数据之前的所有段，要求存在帧头 (SOF) 并以 EOI 结束。
+func checkJPEG(data []byte) error {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("JPEG 缺少 SOI 标记")
+	}
+	// 文件末尾允许有少量填充字节，但最后必须能找到 EOI 标记
+	if !bytes.Contains(data[max(len(data)-64, 2):], []byte{0xFF, 0xD9}) {
+		return fmt.Errorf("JPEG 缺少 EOI 标记，文件可能被截断")
+	}
+
+	hasFrame := false
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return fmt.Errorf("JPEG 段结构损坏 (偏移 %d)", pos)
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++ // 填充字节
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			pos += 2 // 无长度的独立标记
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return fmt.Errorf("JPEG 段长度超出文件末尾，文件可能被截断")
+		}
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			hasFrame = true
+		}
+		if marker == 0xDA { // SOS：之后是熵编码数据，不再按段解析
+			if !hasFrame {
+				return fmt.Errorf("JPEG 缺少帧头 (SOF)")
+			}
+			return nil
+		}
+		pos += 2 + length
+	}
+	return fmt.Errorf("JPEG 缺少扫描数据 (SOS)")
+}
+
+// checkWebP 校验 RIFF 头中的长度与实际文件大小一致，并且第一个分块是 VP8、VP8L 或 VP8X。
+func checkWebP(data []byte) error {
+	if len(data) < 20 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return fmt.Errorf("WebP 缺少 RIFF/WEBP 文件头")
+	}
+	size := binary.LittleEndian.Uint32(data[4:8])
+	if uint64(size)+8 != uint64(len(data)) {
+		return fmt.Errorf("WebP 声明大小 %d 与实际大小 %d 不符，文件可能被截断", uint64(size)+8, len(data))
+	}
+	switch chunk := string(data[12:16]); chunk {
+	case "VP8 ", "VP8L", "VP8X":
+		return nil
+	default:
+		return fmt.Errorf("WebP 第一个分块 %q 无效", chunk)
+	}
+}
+
+// checkGIF 校验 GIF 签名和结尾的 Trailer 字节。
+func checkGIF(data []byte) error {
+	if len(data) < 14 || (string(data[0:6]) != "GIF87a" && string(data[0:6]) != "GIF89a") {
+		return fmt.Errorf("GIF 签名不正确")
+	}
+	if data[len(data)-1] != 0x3B {
+		return fmt.Errorf("GIF 缺少结束标记，文件可能被截断")
+	}
+	return nil
+}
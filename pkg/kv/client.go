@@ -0,0 +1,98 @@
+// package kv 提供了 Vercel KV（基于 Upstash Redis 的 REST API）的最小客户端。
+// 只实现了缓存所需的 GET、SET（带过期时间）和 DEL 命令。
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client 封装了访问 Vercel KV REST API 所需的地址和令牌。
+type Client struct {
+	url        string       // REST API 地址，对应环境变量 KV_REST_API_URL
+	token      string       // 访问令牌，对应环境变量 KV_REST_API_TOKEN
+	httpClient *http.Client // 用于执行 HTTP 请求的客户端
+}
+
+// NewClient 创建一个新的 Vercel KV 客户端。
+func NewClient(url, token string, httpClient *http.Client) *Client {
+	return &Client{url: url, token: token, httpClient: httpClient}
+}
+
+// Get 读取 key 对应的值。key 不存在时返回 false。
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	result, err := c.command(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if string(result) == "null" {
+		return nil, false, nil
+	}
+	var value string
+	if err := json.Unmarshal(result, &value); err != nil {
+		return nil, false, fmt.Errorf("解析 KV 响应失败: %w", err)
+	}
+	return []byte(value), true, nil
+}
+
+// Set 写入 key，并在 ttl 后自动过期。ttl 为 0 表示永不过期。
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "EX", strconv.Itoa(int(ttl.Seconds())))
+	}
+	_, err := c.command(ctx, args...)
+	return err
+}
+
+// Del 删除 key。
+func (c *Client) Del(ctx context.Context, key string) error {
+	_, err := c.command(ctx, "DEL", key)
+	return err
+}
+
+// command 以 JSON 数组的形式发送一条 Redis 命令，并返回响应中的 result 字段。
+func (c *Client) command(ctx context.Context, args ...string) (json.RawMessage, error) {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建 KV 请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("执行 KV 命令 %s 失败: %w", args[0], err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 KV 响应失败: %w", err)
+	}
+
+	var kvResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &kvResp); err != nil {
+		return nil, fmt.Errorf("解析 KV 响应失败 (状态码: %d): %w", resp.StatusCode, err)
+	}
+	if kvResp.Error != "" {
+		return nil, fmt.Errorf("KV 命令 %s 失败: %s", args[0], kvResp.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KV 返回了非预期的状态码: %d", resp.StatusCode)
+	}
+	return kvResp.Result, nil
+}
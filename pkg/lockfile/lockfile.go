@@ -0,0 +1,142 @@
+// package lockfile 提供了基于 PID 文件的进程间互斥锁，
+// 用于防止多个由 cron 触发的同步进程同时运行。
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked 表示锁文件已被另一个仍在运行的进程持有。
+var ErrLocked = errors.New("锁文件已被其他进程持有")
+
+// Lock 表示一个已成功获取的锁文件。
+type Lock struct {
+	path string
+}
+
+// staleAge 是内容无效的锁文件被视为过期前需要等待的时间。
+// 旧版本先创建空文件再写入 PID，在此期间读到的空文件仍属于一个正在获取锁的进程。
+const staleAge = 10 * time.Second
+
+// Acquire 尝试在 path 处创建锁文件，并写入当前进程的 PID。
+// PID 先写入临时文件，再以硬链接的方式原子地放到 path，因此其他进程不会读到不完整的锁文件。
+// 如果锁文件已存在但其记录的进程已不存在（例如上次运行被强制终止），
+// 则视为过期锁，将其删除后重新获取。
+func Acquire(path string) (*Lock, error) {
+	tmp, err := writeTemp(path)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		// 目标已存在时 Link 失败，与 O_EXCL 一样保证只有一个进程能创建锁文件
+		err := os.Link(tmp, path)
+		if err == nil {
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("创建锁文件 '%s' 失败: %w", path, err)
+		}
+
+		if pid, held := holder(path); held {
+			return nil, lockedError(pid, path)
+		}
+		// 过期的锁文件：移走后重试一次
+		if err := removeStale(path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w (文件: %s)", ErrLocked, path)
+}
+
+// writeTemp 在锁文件所在目录创建一个写有当前进程 PID 的临时文件，返回其路径。
+func writeTemp(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("创建锁文件 '%s' 失败: %w", path, err)
+	}
+	_, writeErr := f.WriteString(strconv.Itoa(os.Getpid()))
+	closeErr := f.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("写入锁文件 '%s' 失败: %v", path, errors.Join(writeErr, closeErr))
+	}
+	return f.Name(), nil
+}
+
+// holder 返回锁文件 path 记录的 PID，以及该锁是否仍被持有。
+// 内容无效但在 staleAge 内修改过的锁文件可能正在被写入，同样视为被持有，此时 PID 为 0。
+func holder(path string) (int, bool) {
+	pid, err := readPID(path)
+	if err == nil {
+		return pid, processExists(pid)
+	}
+	info, statErr := os.Stat(path)
+	return 0, statErr == nil && time.Since(info.ModTime()) < staleAge
+}
+
+// lockedError 返回表示锁已被持有的错误，pid 为 0 表示持有者未知。
+func lockedError(pid int, path string) error {
+	if pid == 0 {
+		return fmt.Errorf("%w (文件: %s)", ErrLocked, path)
+	}
+	return fmt.Errorf("%w (PID: %d, 文件: %s)", ErrLocked, pid, path)
+}
+
+// removeStale 删除 path 处已判定过期的锁文件。
+// 判定和删除之间，另一个进程可能已经删除了过期锁并创建了新的锁文件，直接删除 path 会误删它。
+// 因此先将锁文件原子地重命名为本进程独有的临时文件，再检查其中的 PID：
+// 仍是过期的锁时删除临时文件；否则说明移走的是别的进程刚获取的锁，将其放回原处并报告已被持有。
+func removeStale(path string) error {
+	stale := fmt.Sprintf("%s.stale.%d", path, os.Getpid())
+	if err := os.Rename(path, stale); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("移除过期锁文件 '%s' 失败: %w", path, err)
+	}
+	if pid, held := holder(stale); held {
+		// 使用硬链接放回，不会覆盖此间又被其他进程创建的锁文件
+		linkErr := os.Link(stale, path)
+		os.Remove(stale)
+		if linkErr != nil && !errors.Is(linkErr, os.ErrExist) {
+			return fmt.Errorf("恢复锁文件 '%s' 失败: %w", path, linkErr)
+		}
+		return lockedError(pid, path)
+	}
+	if err := os.Remove(stale); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除过期锁文件 '%s' 失败: %w", stale, err)
+	}
+	return nil
+}
+
+// Release 删除锁文件。对 nil 的 Lock 调用是安全的。
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("删除锁文件 '%s' 失败: %w", l.path, err)
+	}
+	return nil
+}
+
+// readPID 从锁文件中读取持有者的 PID。
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("锁文件内容无效: %q", string(data))
+	}
+	return pid, nil
+}
@@ -0,0 +1,122 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadPID 是一个不可能存在的进程 ID，用于模拟被强制终止的进程留下的锁文件。
+const deadPID = 1<<31 - 1
+
+func TestAcquireExisting(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		age        time.Duration // 锁文件的修改时间距现在多久
+		wantLocked bool
+	}{
+		{name: "持有者仍在运行", content: strconv.Itoa(os.Getpid()), wantLocked: true},
+		{name: "持有者已退出", content: strconv.Itoa(deadPID), wantLocked: false},
+		// 旧版本创建锁文件和写入 PID 是两步操作，刚创建的空文件属于一个正在获取锁的进程
+		{name: "刚创建的空文件", content: "", wantLocked: true},
+		{name: "刚创建的无效内容", content: "abc", wantLocked: true},
+		{name: "过期的空文件", content: "", age: 2 * staleAge, wantLocked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sync.lock")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.age > 0 {
+				mtime := time.Now().Add(-tt.age)
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lock, err := Acquire(path)
+			if tt.wantLocked {
+				if !errors.Is(err, ErrLocked) {
+					t.Fatalf("Acquire() 错误 = %v，期望 ErrLocked", err)
+				}
+				data, readErr := os.ReadFile(path)
+				if readErr != nil || string(data) != tt.content {
+					t.Errorf("被持有的锁文件被修改: 内容 %q, 错误 %v", data, readErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Acquire() 错误 = %v，期望成功获取过期锁", err)
+				}
+				defer lock.Release()
+				if pid, err := readPID(path); err != nil || pid != os.Getpid() {
+					t.Errorf("锁文件中的 PID = %d (错误 %v)，期望 %d", pid, err, os.Getpid())
+				}
+			}
+			assertNoLeftovers(t, path)
+		})
+	}
+}
+
+// TestAcquireConcurrent 模拟多个同步进程同时启动：只能有一个获取到锁，
+// 且其他竞争者在任何时刻读到的锁文件都包含完整的 PID，不会把它当作过期锁删除。
+func TestAcquireConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	const workers = 16
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		held  []*Lock
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			lock, err := Acquire(path)
+			if err != nil {
+				if !errors.Is(err, ErrLocked) {
+					t.Errorf("Acquire() 错误 = %v，期望 ErrLocked", err)
+				}
+				return
+			}
+			mutex.Lock()
+			held = append(held, lock)
+			mutex.Unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if len(held) != 1 {
+		t.Fatalf("%d 个竞争者获取到了锁，期望只有 1 个", len(held))
+	}
+	if err := held[0].Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Release() 后锁文件仍存在: %v", err)
+	}
+	assertNoLeftovers(t, path)
+}
+
+// assertNoLeftovers 检查锁文件所在目录中没有残留的临时文件。
+func assertNoLeftovers(t *testing.T, path string) {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != filepath.Base(path) {
+			t.Errorf("残留临时文件: %s", e.Name())
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// processExists 通过发送 0 号信号检查进程是否仍然存在。
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	// EPERM 表示进程存在，只是属于其他用户
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
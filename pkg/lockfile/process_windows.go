@@ -0,0 +1,29 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive 是 GetExitCodeProcess 对仍在运行的进程返回的退出码 (STILL_ACTIVE)。
+const stillActive = 259
+
+// processExists 打开进程句柄并查询其退出码判断进程是否仍在运行。
+// 已退出但句柄尚未被全部关闭的进程仍能被打开，因此不能只看 OpenProcess 是否成功。
+func processExists(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// 拒绝访问表示进程存在，只是属于其他用户或受保护
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -10,7 +10,7 @@ import (
 	"strings"
 	"time"
 
-	"nodeimage_webdav_webui/pkg/websocket"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
 )
 
 // LogLevel 定义了日志的严重性级别。
@@ -99,14 +99,22 @@ type websocketLogger struct {
 	hub      *websocket.Hub // WebSocket 管理器，用于广播消息
 	fallback Logger         // 备用 logger，用于将消息也输出到控制台
 	level    LogLevel       // 此 logger 的日志级别
+	topic    string         // 消息所属的主题，为空表示广播给所有客户端
 }
 
 // NewWebsocketLogger 创建一个新的 websocketLogger 实例。
 func NewWebsocketLogger(hub *websocket.Hub, fallback Logger, level LogLevel) Logger {
+	return NewTopicWebsocketLogger(hub, fallback, level, "")
+}
+
+// NewTopicWebsocketLogger 创建一个只向订阅了 topic 的客户端推送日志的 websocketLogger。
+// 输出到备用 logger 的消息会带上 [topic] 前缀，以便区分多个同时运行的任务。
+func NewTopicWebsocketLogger(hub *websocket.Hub, fallback Logger, level LogLevel, topic string) Logger {
 	return &websocketLogger{
 		hub:      hub,
 		fallback: fallback,
 		level:    level,
+		topic:    topic,
 	}
 }
 
@@ -123,9 +131,12 @@ func (l *websocketLogger) log(level LogLevel, levelStr string, format string, v
 	htmlMsg := fmt.Sprintf(`<span class="log-time">[%s]</span> <span class="log-%s">[%s]</span> %s`, timestamp, levelStr, levelStr, msg)
 
 	// 通过 WebSocket 广播格式化后的消息
-	l.hub.Broadcast(websocket.Message{Type: "log", Content: htmlMsg})
+	l.hub.Broadcast(websocket.Message{Type: "log", Content: htmlMsg, Topic: l.topic})
 
 	// 同时，将原始消息发送到备用 logger
+	if l.topic != "" {
+		msg = "[" + l.topic + "] " + msg
+	}
 	switch level {
 	case DEBUG:
 		l.fallback.Debug(msg)
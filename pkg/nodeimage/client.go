@@ -12,8 +12,8 @@ import (
 	"io"
 	"net/http"
 
-	"nodeimage_webdav_webui/pkg/logger"
-	"nodeimage_webdav_webui/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
 
 	"github.com/klauspost/compress/zstd"
 )
@@ -70,6 +70,15 @@ type Client struct {
 	stats      *stats.Stats  // 统计信息收集器
 }
 
+// StatusError 表示服务器返回了非预期的 HTTP 状态码，调用方可以据此区分限流 (429) 等错误。
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("状态码: %d", e.StatusCode)
+}
+
 // NewClient 创建一个新的 NodeImage API 客户端实例。
 func NewClient(cookie, baseURL string, logger logger.Logger, stats *stats.Stats, httpClient *http.Client) *Client {
 	return &Client{
@@ -246,7 +255,7 @@ func (c *Client) DownloadImage(ctx context.Context, url string) ([]byte, error)
 
 	if resp.StatusCode != http.StatusOK {
 		c.stats.AddFailure()
-		return nil, fmt.Errorf("下载时服务器返回了非预期的状态码: %d", resp.StatusCode)
+		return nil, fmt.Errorf("下载时服务器返回了非预期的%w", &StatusError{resp.StatusCode})
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -277,7 +286,7 @@ func (c *Client) DownloadImageStream(ctx context.Context, url string) (io.ReadCl
 	if resp.StatusCode != http.StatusOK {
 		c.stats.AddFailure()
 		resp.Body.Close() // 确保在出错时关闭 body
-		return nil, fmt.Errorf("下载时服务器返回了非预期的状态码: %d", resp.StatusCode)
+		return nil, fmt.Errorf("下载时服务器返回了非预期的%w", &StatusError{resp.StatusCode})
 	}
 
 	// 不使用 io.ReadAll，直接返回响应体。
@@ -0,0 +1,143 @@
+// package notify 将告警发送到外部通知渠道。
+// 目前支持通用 Webhook（以 JSON 形式 POST 事件）和 Telegram 机器人，两者可以同时启用。
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Event 是一条告警事件。
+type Event struct {
+	Type    string    `json:"type"`          // 事件类型，例如 "degraded"、"recovered"
+	Job     string    `json:"job,omitempty"` // 相关的同步任务 ID
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier 是一个通知渠道。
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Multi 将事件发送到多个渠道，返回所有失败渠道的错误。
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// New 根据配置创建通知渠道。webhookURL 为空时不启用 Webhook，token 或 chatID 为空时不启用 Telegram；
+// 都未启用时返回空的 Multi，调用 Notify 不做任何事。
+func New(webhookURL, telegramToken, telegramChatID string, httpClient *http.Client) Multi {
+	var m Multi
+	if webhookURL != "" {
+		m = append(m, &Webhook{URL: webhookURL, Client: httpClient})
+	}
+	if telegramToken != "" && telegramChatID != "" {
+		m = append(m, &Telegram{Token: telegramToken, ChatID: telegramChatID, Client: httpClient})
+	}
+	return m
+}
+
+// Webhook 将事件以 JSON 形式 POST 到指定地址。
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	return post(ctx, w.Client, w.URL, "application/json", body, "Webhook")
+}
+
+// Telegram 通过 Telegram 机器人发送消息。
+type Telegram struct {
+	Token  string
+	ChatID string
+	Client *http.Client
+}
+
+func (t *Telegram) Notify(ctx context.Context, event Event) error {
+	form := url.Values{
+		"chat_id": {t.ChatID},
+		"text":    {event.Title + "\n" + event.Message},
+	}
+	endpoint := "https://api.telegram.org/bot" + t.Token + "/sendMessage"
+	return post(ctx, t.Client, endpoint, "application/x-www-form-urlencoded", []byte(form.Encode()), "Telegram")
+}
+
+// post 发送一个 POST 请求，非 2xx 状态码视为失败。错误信息中不包含请求地址，避免泄露令牌。
+func post(ctx context.Context, client *http.Client, endpoint, contentType string, body []byte, channel string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建 %s 通知请求失败", channel)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("发送 %s 通知失败: %w", channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s 通知返回了非预期的状态码: %d, 响应: %s", channel, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// Degraded 创建“同步降级”告警：任务连续失败 failures 次，定时同步间隔已延长到 interval。
+func Degraded(job string, failures int, interval time.Duration, lastErr string) Event {
+	return Event{
+		Type:    "degraded",
+		Job:     job,
+		Title:   fmt.Sprintf("同步降级: %s", job),
+		Message: fmt.Sprintf("任务 %s 已连续失败 %d 次，定时同步间隔已延长到 %s。最近一次错误: %s", job, failures, interval, lastErr),
+		Time:    time.Now(),
+	}
+}
+
+// Failed 创建“同步失败”告警，用于没有连续失败统计的单次运行。
+func Failed(job, message string) Event {
+	return Event{
+		Type:    "failed",
+		Job:     job,
+		Title:   fmt.Sprintf("同步失败: %s", job),
+		Message: fmt.Sprintf("任务 %s 的同步失败: %s", job, message),
+		Time:    time.Now(),
+	}
+}
+
+// Recovered 创建“同步已恢复”告警，在降级后的第一次成功同步时发送。
+func Recovered(job string) Event {
+	return Event{
+		Type:    "recovered",
+		Job:     job,
+		Title:   fmt.Sprintf("同步已恢复: %s", job),
+		Message: fmt.Sprintf("任务 %s 的同步已恢复正常，定时同步间隔已还原。", job),
+		Time:    time.Now(),
+	}
+}
@@ -0,0 +1,70 @@
+// package pushgateway 实现了向 Prometheus Pushgateway 推送指标的最小客户端。
+// 短生命周期的 cron 任务无法被 Prometheus 主动抓取，因此在运行结束时把结果推送到 Pushgateway。
+// 本实现直接生成 Prometheus 文本格式，不依赖官方客户端库。
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Metric 表示一个待推送的指标样本。
+type Metric struct {
+	Name  string  // 指标名，例如 "nodeimage_sync_duration_seconds"
+	Help  string  // 指标说明
+	Type  string  // 指标类型，通常为 "gauge"
+	Value float64 // 指标值
+}
+
+// Push 使用 PUT 方法将指标推送到 Pushgateway，替换同一分组下的所有旧指标。
+// labels 是附加的分组标签（除 job 外），会按顺序拼接到 URL 路径中。
+func Push(ctx context.Context, httpClient *http.Client, gatewayURL, job string, labels [][2]string, metrics []Metric) error {
+	u, err := url.Parse(strings.TrimRight(gatewayURL, "/"))
+	if err != nil {
+		return fmt.Errorf("无法解析 Pushgateway 地址 '%s': %w", gatewayURL, err)
+	}
+	segments := []string{u.Path, "metrics", "job", url.PathEscape(job)}
+	for _, label := range labels {
+		segments = append(segments, url.PathEscape(label[0]), url.PathEscape(label[1]))
+	}
+	u.Path = strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(encode(metrics)))
+	if err != nil {
+		return fmt.Errorf("创建 Pushgateway 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("推送指标到 Pushgateway 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Pushgateway 返回了非预期的状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// encode 将指标编码为 Prometheus 文本格式。
+func encode(metrics []Metric) []byte {
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		metricType := m.Type
+		if metricType == "" {
+			metricType = "gauge"
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", m.Name, metricType)
+		fmt.Fprintf(&buf, "%s %s\n", m.Name, strconv.FormatFloat(m.Value, 'g', -1, 64))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,85 @@
+// package sdnotify 实现 systemd 的 sd_notify 协议，使程序可以作为 Type=notify 服务运行。
+// 它通过 NOTIFY_SOCKET 环境变量指定的 Unix 数据报套接字向 systemd 报告就绪、状态文本和看门狗心跳。
+// 不在 systemd 下运行（未设置 NOTIFY_SOCKET）时，所有函数都不做任何事。
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notify 向 systemd 发送一条通知，例如 "READY=1"。
+// 未设置 NOTIFY_SOCKET 时返回 false 和 nil。
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// 以 @ 开头的是 Linux 抽象命名空间套接字
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ready 通知 systemd 服务已启动完成。
+func Ready() (bool, error) {
+	return Notify("READY=1")
+}
+
+// Stopping 通知 systemd 服务正在退出。
+func Stopping() (bool, error) {
+	return Notify("STOPPING=1")
+}
+
+// Status 设置 systemctl status 中显示的状态文本。
+func Status(text string) (bool, error) {
+	// 状态文本只能占一行
+	return Notify("STATUS=" + strings.ReplaceAll(text, "\n", " "))
+}
+
+// WatchdogInterval 返回 systemd 要求的看门狗心跳间隔（WatchdogSec）。
+// 未启用看门狗，或看门狗针对的是其他进程时返回 0。
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog 在启用了看门狗时，按心跳间隔的一半发送 WATCHDOG=1，直到 stop 被关闭。
+// 每次发送前都会调用 healthy（可以为 nil）：它返回 false 或被阻塞（例如程序内部死锁）时不发送心跳，
+// systemd 会在超时后按 Restart= 的设置重启服务。未启用看门狗时立即返回。
+func Watchdog(stop <-chan struct{}, healthy func() bool) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if healthy == nil || healthy() {
+				Notify("WATCHDOG=1")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
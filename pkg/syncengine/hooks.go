@@ -0,0 +1,110 @@
+package syncengine
+
+import (
+	"context"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+)
+
+// Hook 是同步引擎在执行阶段的扩展点，通过 Options.Hooks 注册。只关心部分事件的实现可以嵌入 NopHook。
+//
+// 同一次同步中，各方法按顺序被串行调用，实现无需自行加锁；但它们会阻塞执行阶段，耗时的操作应自行异步处理。
+// 演练模式下不调用任何 Hook。
+type Hook interface {
+	// OnPlan 在执行计划之前调用，可以修改计划（例如过滤掉部分文件）。返回错误会中止本次同步。
+	OnPlan(ctx context.Context, plan *Plan) error
+	// OnFileUploaded 在一个文件成功上传到 WebDAV 的 targetPath 后调用。
+	OnFileUploaded(ctx context.Context, file nodeimage.ImageInfo, targetPath string) error
+	// OnFileDeleted 在 WebDAV 上的一个文件被删除后调用。旧版本模式下 versionPath 是文件被保留的路径，否则为空。
+	// 清理过期旧版本不会触发该事件，见 FilePurgedHook。
+	OnFileDeleted(ctx context.Context, filePath, versionPath string) error
+	// OnComplete 在计划执行结束后调用，无论成功与否。Engine.Sync 在扫描阶段就失败时同样会调用，此时 plan 为 nil。
+	OnComplete(ctx context.Context, plan *Plan, result Result) error
+}
+
+// SyncStartedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在 Engine.Sync 开始扫描之前收到通知；
+// Engine.Execute 执行已有的计划时不会触发该事件。
+type SyncStartedHook interface {
+	OnSyncStarted(ctx context.Context, isFullSync bool) error
+}
+
+// FileRenamedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在一个文件从 fromPath 重命名为 toPath 后收到通知。
+type FileRenamedHook interface {
+	OnFileRenamed(ctx context.Context, file nodeimage.ImageInfo, fromPath, toPath string) error
+}
+
+// UploadFailedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在一个文件重试耗尽后仍上传失败时收到通知，
+// err 为最后一次尝试的错误。
+type UploadFailedHook interface {
+	OnUploadFailed(ctx context.Context, file nodeimage.ImageInfo, err error) error
+}
+
+// FilePurgedHook 是 Hook 可选实现的接口。实现了该接口的 Hook 会在一个过期的旧版本文件被清理后收到通知。
+type FilePurgedHook interface {
+	OnFilePurged(ctx context.Context, filePath string) error
+}
+
+// NopHook 是不做任何事的 Hook，可嵌入到只实现部分方法的 Hook 中。
+type NopHook struct{}
+
+func (NopHook) OnPlan(context.Context, *Plan) error                               { return nil }
+func (NopHook) OnFileUploaded(context.Context, nodeimage.ImageInfo, string) error { return nil }
+func (NopHook) OnFileDeleted(context.Context, string, string) error               { return nil }
+func (NopHook) OnComplete(context.Context, *Plan, Result) error                   { return nil }
+
+// hookAdapter 将 Hook 适配为引擎内部的 Hook，并在两者之间转换计划和结果。
+type hookAdapter struct {
+	hook Hook
+}
+
+func (a hookAdapter) OnPlan(ctx context.Context, internal *sync_lib.Plan) error {
+	plan := newPlan(internal)
+	err := a.hook.OnPlan(ctx, plan)
+	plan.applyTo(internal)
+	return err
+}
+
+func (a hookAdapter) OnFileUploaded(ctx context.Context, file nodeimage.ImageInfo, targetPath string) error {
+	return a.hook.OnFileUploaded(ctx, file, targetPath)
+}
+
+func (a hookAdapter) OnFileDeleted(ctx context.Context, filePath, versionPath string) error {
+	return a.hook.OnFileDeleted(ctx, filePath, versionPath)
+}
+
+func (a hookAdapter) OnComplete(ctx context.Context, internal *sync_lib.Plan, result sync_lib.Result) error {
+	var plan *Plan
+	if internal != nil {
+		plan = newPlan(internal)
+	}
+	return a.hook.OnComplete(ctx, plan, newResult(result))
+}
+
+func (a hookAdapter) OnSyncStarted(ctx context.Context, isFullSync bool) error {
+	if h, ok := a.hook.(SyncStartedHook); ok {
+		return h.OnSyncStarted(ctx, isFullSync)
+	}
+	return nil
+}
+
+func (a hookAdapter) OnFileRenamed(ctx context.Context, file nodeimage.ImageInfo, fromPath, toPath string) error {
+	if h, ok := a.hook.(FileRenamedHook); ok {
+		return h.OnFileRenamed(ctx, file, fromPath, toPath)
+	}
+	return nil
+}
+
+func (a hookAdapter) OnUploadFailed(ctx context.Context, file nodeimage.ImageInfo, err error) error {
+	if h, ok := a.hook.(UploadFailedHook); ok {
+		return h.OnUploadFailed(ctx, file, err)
+	}
+	return nil
+}
+
+func (a hookAdapter) OnFilePurged(ctx context.Context, filePath string) error {
+	if h, ok := a.hook.(FilePurgedHook); ok {
+		return h.OnFilePurged(ctx, filePath)
+	}
+	return nil
+}
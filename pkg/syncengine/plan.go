@@ -0,0 +1,98 @@
+package syncengine
+
+import (
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/nodeimage"
+)
+
+// Plan 是一次同步需要执行的上传、删除和重命名操作，可以在执行前检查或修改。
+// 由 Engine.Plan 生成的计划还带有引擎内部的状态（快照清单、图片 ID 变更、开始时间），
+// 经 JSON 序列化后这些状态会丢失，此时快照模式不会写入清单。
+type Plan struct {
+	IsFullSync  bool                  `json:"isFullSync"`
+	Uploads     []nodeimage.ImageInfo `json:"uploads"`
+	Deletes     []string              `json:"deletes"`
+	Purges      []string              `json:"purges"`            // 按保留策略需要清理的旧版本文件，总是直接删除
+	Renames     []Rename              `json:"renames,omitempty"` // 按 ID 对应时，在 NodeImage 上改过名、需要在 WebDAV 上重命名的文件
+	UploadSize  int64                 `json:"uploadSize"`
+	SourceFiles int                   `json:"sourceFiles"` // NodeImage 上的文件数
+	SourceBytes int64                 `json:"sourceBytes"` // NodeImage 上的文件总大小
+	TargetFiles int                   `json:"targetFiles"` // WebDAV 上的文件数
+	TargetBytes int64                 `json:"targetBytes"` // WebDAV 上的文件总大小
+
+	internal *sync_lib.Plan // 生成该计划的内部计划，为空表示计划来自外部
+}
+
+// Rename 描述一个需要在 WebDAV 上重命名的文件。
+type Rename struct {
+	From string              `json:"from"` // WebDAV 上的原路径
+	File nodeimage.ImageInfo `json:"file"` // NodeImage 上的图片，文件名即新文件名
+}
+
+// Empty 判断计划中是否没有任何需要执行的操作。
+func (p *Plan) Empty() bool {
+	return p.Len() == 0
+}
+
+// Len 返回计划中的操作总数。
+func (p *Plan) Len() int {
+	return len(p.Uploads) + len(p.Deletes) + len(p.Purges) + len(p.Renames)
+}
+
+// Validate 检查来自外部的计划是否安全，并重新计算上传总大小。
+// 上传文件名不能包含路径，删除路径必须是 basePath 的直接子级。
+func (p *Plan) Validate(basePath string) error {
+	internal := p.toInternal()
+	if err := internal.Validate(basePath); err != nil {
+		return err
+	}
+	p.UploadSize = internal.UploadSize
+	return nil
+}
+
+// newPlan 将引擎内部的计划转换为 Plan。两者共享切片，修改 Plan 后需要调用 applyTo 写回。
+func newPlan(internal *sync_lib.Plan) *Plan {
+	p := &Plan{
+		IsFullSync:  internal.IsFullSync,
+		Uploads:     internal.Uploads,
+		Deletes:     internal.Deletes,
+		Purges:      internal.Purges,
+		UploadSize:  internal.UploadSize,
+		SourceFiles: internal.TotalNodeImageFiles,
+		SourceBytes: internal.TotalNodeImageSize,
+		TargetFiles: internal.TotalWebDAVFiles,
+		TargetBytes: internal.TotalWebDAVSize,
+		internal:    internal,
+	}
+	for _, r := range internal.Renames {
+		p.Renames = append(p.Renames, Rename(r))
+	}
+	return p
+}
+
+// applyTo 将 p 中可修改的字段写回引擎内部的计划。
+func (p *Plan) applyTo(internal *sync_lib.Plan) {
+	internal.IsFullSync = p.IsFullSync
+	internal.Uploads = p.Uploads
+	internal.Deletes = p.Deletes
+	internal.Purges = p.Purges
+	internal.Renames = nil
+	for _, r := range p.Renames {
+		internal.Renames = append(internal.Renames, sync_lib.Rename(r))
+	}
+	internal.UploadSize = p.UploadSize
+	internal.TotalNodeImageFiles = p.SourceFiles
+	internal.TotalNodeImageSize = p.SourceBytes
+	internal.TotalWebDAVFiles = p.TargetFiles
+	internal.TotalWebDAVSize = p.TargetBytes
+}
+
+// toInternal 返回与 p 对应的内部计划。p 由 Engine.Plan 生成时保留其内部状态，p 本身不会被修改。
+func (p *Plan) toInternal() *sync_lib.Plan {
+	var internal sync_lib.Plan
+	if p.internal != nil {
+		internal = *p.internal
+	}
+	p.applyTo(&internal)
+	return &internal
+}
@@ -0,0 +1,276 @@
+// package syncengine 是同步引擎对外发布的稳定接口，供其他 Go 程序嵌入 NodeImage 到 WebDAV 的同步，
+// 而无需运行 Web UI 或命令行工具。Web UI、命令行和 Serverless 部署使用的是同一个引擎，
+// 重试、自适应并发、上传校验、空间检查、旧版本保留等行为完全一致。
+//
+// 通过 go get github.com/zouzonghao/nodeimage_webdav_vercel/pkg/syncengine 引入。最简单的用法：
+//
+//	engine, err := syncengine.New(syncengine.Options{
+//		NodeImageAPIKey: "...",
+//		WebdavURL:       "https://dav.example.com/dav",
+//		WebdavUsername:  "user",
+//		WebdavPassword:  "pass",
+//		WebdavFolder:    "/nodeimage",
+//	})
+//	if err != nil { ... }
+//	result, err := engine.Sync(ctx, syncengine.Incremental)
+//
+// 本包导出的类型和函数保持向后兼容；引擎的内部实现位于 internal/sync，可能随时变化。
+package syncengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	sync_lib "github.com/zouzonghao/nodeimage_webdav_vercel/internal/sync"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+)
+
+// Mode 是同步模式。
+type Mode int
+
+const (
+	// Incremental 使用 API Key 获取最新的图片并上传缺失的文件，不删除任何文件。
+	Incremental Mode = iota
+	// Full 使用 Cookie 获取全部图片，上传缺失的文件，并按 DeleteMode 处理 NodeImage 上已不存在的文件。
+	Full
+)
+
+// 删除模式，见 Options.DeleteMode。
+const (
+	DeleteModeDelete  = sync_lib.DeleteModeDelete
+	DeleteModeVersion = sync_lib.DeleteModeVersion
+)
+
+// 删除范围，见 Options.DeleteScope。
+const (
+	DeleteScopeAll     = sync_lib.DeleteScopeAll
+	DeleteScopeManaged = sync_lib.DeleteScopeManaged
+)
+
+// 空间不足时的处理方式，见 Options.QuotaAction。
+const (
+	QuotaAbort = sync_lib.QuotaAbort
+	QuotaTrim  = sync_lib.QuotaTrim
+	QuotaOff   = sync_lib.QuotaOff
+)
+
+// 文件对应方式，见 Options.MatchBy。
+const (
+	MatchByName = sync_lib.MatchByName
+	MatchByID   = sync_lib.MatchByID
+)
+
+// 文件名的 Unicode 规范化方式，见 Options.UnicodeNorm。
+const (
+	UnicodeNFC = sync_lib.UnicodeNFC
+	UnicodeOff = sync_lib.UnicodeOff
+)
+
+// 执行阶段的操作类型，见 Progress.Op。
+const (
+	OpUpload = sync_lib.OpUpload
+	OpDelete = sync_lib.OpDelete
+	OpRename = sync_lib.OpRename
+)
+
+// ErrBusy 表示同一个 Engine 上已有同步在运行。
+var ErrBusy = errors.New("已有同步在运行")
+
+// Options 是创建 Engine 所需的配置。零值字段使用与 Web UI 相同的默认值。
+type Options struct {
+	NodeImageAPIKey string // 增量同步使用的 API Key
+	NodeImageCookie string // 全量同步使用的 Cookie
+	NodeImageAPIURL string // 可选，NodeImage 全量同步的接口地址
+
+	WebdavURL      string // 可选，默认为坚果云 https://dav.jianguoyun.com/dav
+	WebdavUsername string
+	WebdavPassword string
+	WebdavFolder   string // WebDAV 上的同步根目录
+
+	Concurrency     int           // 上传的并发上限，默认 5
+	DeleteWorkers   int           // 删除、清理旧版本和重命名的并发上限，独立于上传，默认与 Concurrency 相同
+	AutoConcurrency bool          // 遇到限流或超时时自动降低并发，恢复后再逐步提高
+	Retries         int           // 单个上传/删除失败后的重试次数，默认 2；小于 0 表示不重试
+	OpTimeout       time.Duration // 单个文件的下载+上传或删除的超时时间，0 表示不限制
+	SkipVerify      bool          // 不在上传后确认文件已以预期大小存在于 WebDAV 上
+	QuotaAction     string        // WebDAV 剩余空间不足时的处理方式：QuotaAbort（默认）、QuotaTrim 或 QuotaOff
+	MinFreeSpace    int64         // 上传后 WebDAV 上至少需要保留的剩余空间（字节）
+	DeleteMode      string        // 全量同步的删除模式：DeleteModeDelete（默认）或 DeleteModeVersion
+	DeleteScope     string        // 全量同步的删除范围：DeleteScopeAll（默认）或 DeleteScopeManaged（只删除本工具管理的文件）
+	MatchBy         string        // 文件对应方式：MatchByName（默认）或 MatchByID（NodeImage 上改名的图片在 WebDAV 上重命名，而不是删除后重新上传）
+	UnicodeNorm     string        // 对比前文件名的 Unicode 规范化方式：UnicodeNFC（默认）或 UnicodeOff
+	Scope           string        // 可选，链接列表、文章导出或站点地图的路径/地址，只同步其中引用的图片
+	KeepVersions    int           // 每个文件最多保留的旧版本数，0 表示不限
+	VersionMaxAge   time.Duration // 旧版本的最长保留时间，0 表示不限
+	Snapshots       bool          // 快照模式：不删除任何文件，每次全量同步结束后写入一份清单
+	DryRun          bool          // 演练模式：只输出计划，不执行任何上传或删除
+	IndexFile       string        // 可选，本地索引文件的路径，增量同步时代替 WebDAV 文件列表；为空则不使用
+	RetryFile       string        // 可选，重试队列文件的路径，记录重试耗尽后仍上传失败的文件；为空则不记录（与 Web UI 不同，引擎不会默认写入本地文件）
+
+	HTTPClient *http.Client     // 可选，默认为 http.DefaultClient
+	Logger     logger.Logger    // 可选，默认不输出日志
+	OnProgress ProgressCallback // 可选，每完成一个上传或删除操作时被调用
+	Hooks      []Hook           // 可选，在计划执行前后以及每个文件完成时被调用的扩展
+}
+
+// Progress 描述执行阶段的实时进度。
+type Progress struct {
+	Op      string // 操作类型：OpUpload、OpDelete 或 OpRename
+	File    string // 本次完成的文件名
+	Success bool   // 本次操作是否成功
+	Done    int    // 已完成（含失败）的操作数
+	Total   int    // 计划中的操作总数
+}
+
+// ProgressCallback 接收进度报告。它会被串行调用，无需自行加锁。
+type ProgressCallback func(Progress)
+
+// Result 是一次同步的结果。
+type Result struct {
+	Uploaded      int           // 成功上传的文件数
+	Deleted       int           // 成功删除（或保留为旧版本）的文件数
+	Renamed       int           // 成功重命名的文件数
+	Failed        int           // 失败的上传和删除操作数
+	UploadedBytes int64         // 计划上传的总字节数
+	Duration      time.Duration // 总耗时
+	Message       string        // 可读的摘要
+	SourceFiles   int           // NodeImage 上的文件数
+	SourceBytes   int64         // NodeImage 上的文件总大小
+	TargetFiles   int           // WebDAV 上的文件数
+	TargetBytes   int64         // WebDAV 上的文件总大小
+}
+
+// Engine 执行 NodeImage 到 WebDAV 的同步。它可以被多个协程共享，但同一时刻只运行一个同步。
+type Engine struct {
+	config     sync_lib.Config
+	log        logger.Logger
+	httpClient *http.Client
+	runner     sync_lib.Runner
+}
+
+// New 根据 opts 创建 Engine。
+func New(opts Options) (*Engine, error) {
+	if opts.WebdavUsername == "" || opts.WebdavPassword == "" {
+		return nil, errors.New("WebDAV 用户名和密码不能为空")
+	}
+	if opts.NodeImageAPIKey == "" && opts.NodeImageCookie == "" {
+		return nil, errors.New("NodeImage API Key 和 Cookie 至少需要设置一个")
+	}
+
+	e := &Engine{log: opts.Logger, httpClient: opts.HTTPClient}
+	if e.log == nil {
+		e.log = logger.New(logger.ERROR, io.Discard)
+	}
+	if e.httpClient == nil {
+		e.httpClient = http.DefaultClient
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5
+	}
+	switch {
+	case opts.Retries == 0:
+		opts.Retries = 2
+	case opts.Retries < 0:
+		opts.Retries = 0
+	}
+
+	e.config = sync_lib.Config{
+		NodeImageCookie: opts.NodeImageCookie,
+		NodeImageAPIKey: opts.NodeImageAPIKey,
+		NodeImageAPIURL: opts.NodeImageAPIURL,
+		WebdavURL:       opts.WebdavURL,
+		WebdavUsername:  opts.WebdavUsername,
+		WebdavPassword:  opts.WebdavPassword,
+		WebdavBasePath:  opts.WebdavFolder,
+		SyncConcurrency: opts.Concurrency,
+		DeleteWorkers:   opts.DeleteWorkers,
+		AutoConcurrency: opts.AutoConcurrency,
+		SyncRetries:     opts.Retries,
+		OpTimeout:       opts.OpTimeout,
+		VerifyUploads:   !opts.SkipVerify,
+		QuotaAction:     opts.QuotaAction,
+		MinFreeSpace:    opts.MinFreeSpace,
+		DeleteMode:      opts.DeleteMode,
+		DeleteScope:     opts.DeleteScope,
+		KeepVersions:    opts.KeepVersions,
+		VersionMaxAge:   opts.VersionMaxAge,
+		Snapshots:       opts.Snapshots,
+		DryRun:          opts.DryRun,
+		MatchBy:         opts.MatchBy,
+		UnicodeNorm:     opts.UnicodeNorm,
+		Scope:           opts.Scope,
+		IndexFile:       opts.IndexFile,
+		RetryFile:       opts.RetryFile,
+	}
+	for _, h := range opts.Hooks {
+		e.config.Hooks = append(e.config.Hooks, hookAdapter{h})
+	}
+	if opts.OnProgress != nil {
+		callback := opts.OnProgress
+		e.config.OnProgress = func(p sync_lib.Progress) {
+			callback(Progress{Op: p.Op, File: p.File, Success: p.Success, Done: p.Done, Total: p.Total})
+		}
+	}
+	return e, nil
+}
+
+// Sync 扫描两侧的文件并执行一次同步。同步失败（包括部分文件失败）时返回的错误非空，Result 中仍包含已完成的统计。
+// 已有同步在运行时返回 ErrBusy。
+func (e *Engine) Sync(ctx context.Context, mode Mode) (Result, error) {
+	return e.do(func() sync_lib.Result {
+		return sync_lib.RunSync(ctx, e.log, e.config, mode == Full, e.httpClient)
+	})
+}
+
+// Plan 扫描两侧的文件并生成同步计划，但不执行任何写操作。
+func (e *Engine) Plan(ctx context.Context, mode Mode) (*Plan, error) {
+	plan, err := sync_lib.BuildPlan(ctx, e.log, e.config, mode == Full, e.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return newPlan(plan), nil
+}
+
+// Execute 执行由 Plan 生成（可能经过修改）的计划。来自外部的计划应先调用 plan.Validate 检查。
+// 已有同步在运行时返回 ErrBusy。
+func (e *Engine) Execute(ctx context.Context, plan *Plan) (Result, error) {
+	return e.do(func() sync_lib.Result {
+		return sync_lib.ExecutePlan(ctx, e.log, e.config, plan.toInternal(), e.httpClient)
+	})
+}
+
+// do 在持有同步锁的情况下执行 fn，并转换其结果。
+func (e *Engine) do(fn func() sync_lib.Result) (Result, error) {
+	r, ran := e.runner.TryDo(e.log, fn)
+	if !ran {
+		return Result{}, ErrBusy
+	}
+	result := newResult(r)
+	if r.Success {
+		return result, nil
+	}
+	if r.Error != nil {
+		return result, r.Error
+	}
+	return result, errors.New(r.Message)
+}
+
+// newResult 将引擎内部的结果转换为 Result。
+func newResult(r sync_lib.Result) Result {
+	return Result{
+		Uploaded:      r.Uploaded,
+		Deleted:       r.Deleted,
+		Renamed:       r.Renamed,
+		Failed:        r.Failed,
+		UploadedBytes: r.UploadSize,
+		Duration:      r.Duration,
+		Message:       r.Message,
+		SourceFiles:   r.TotalNodeImageFiles,
+		SourceBytes:   r.TotalNodeImageSize,
+		TargetFiles:   r.TotalWebDAVFiles,
+		TargetBytes:   r.TotalWebDAVSize,
+	}
+}
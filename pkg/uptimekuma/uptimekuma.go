@@ -0,0 +1,68 @@
+// package uptimekuma 实现了向 Uptime Kuma 的 Push 监控报告状态的最小客户端。
+// 每次同步结束后推送 up 或 down、一条简短消息和本次运行的耗时，
+// 使备份状态与其它服务显示在同一个 Kuma 面板中。
+package uptimekuma
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 推送的状态。
+const (
+	Up   = "up"
+	Down = "down"
+)
+
+// pushResponse 是 Uptime Kuma Push 接口的响应。
+type pushResponse struct {
+	OK  bool   `json:"ok"`
+	Msg string `json:"msg"`
+}
+
+// Push 向 pushURL 报告一次运行的状态。
+// pushURL 是 Kuma 中 Push 监控显示的地址（如 https://kuma.example.com/api/push/<token>），
+// 其中自带的 status、msg 和 ping 参数会被替换。ping 以毫秒为单位显示为响应时间。
+func Push(ctx context.Context, httpClient *http.Client, pushURL, status, msg string, ping time.Duration) error {
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		return fmt.Errorf("无法解析 Uptime Kuma 地址: %w", err)
+	}
+	q := u.Query()
+	q.Set("status", status)
+	q.Set("msg", msg)
+	q.Set("ping", fmt.Sprint(ping.Milliseconds()))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("创建 Uptime Kuma 请求失败: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 错误信息中不包含推送地址，避免泄露其中的 token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("推送到 Uptime Kuma 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body pushResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err != nil {
+		return fmt.Errorf("Uptime Kuma 返回了无法解析的响应 (状态码 %d): %w", resp.StatusCode, err)
+	}
+	// token 错误或监控已暂停时，Kuma 返回 404 和 {"ok":false,"msg":"..."}
+	if !body.OK {
+		return fmt.Errorf("Uptime Kuma 拒绝了推送 (状态码 %d): %s", resp.StatusCode, body.Msg)
+	}
+	return nil
+}
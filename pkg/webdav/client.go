@@ -7,17 +7,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
-	"nodeimage_webdav_webui/pkg/logger"
-	"nodeimage_webdav_webui/pkg/stats"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
 )
 
 // Client 封装了与 WebDAV 服务器交互所需的状态和方法。
@@ -36,6 +38,15 @@ type FileInfo struct {
 	Size int64  // 文件大小（字节）
 }
 
+// StatusError 表示服务器返回了非预期的 HTTP 状态码，调用方可以据此区分限流 (429) 等错误。
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("状态码: %d", e.StatusCode)
+}
+
 // NewClient 创建并返回一个新的 WebDAV 客户端实例。
 func NewClient(url, username, password string, stats *stats.Stats, log logger.Logger, httpClient *http.Client) *Client {
 	return &Client{
@@ -78,20 +89,21 @@ func (c *Client) Connect(ctx context.Context, basePath string) error {
 		defer mkcolResp.Body.Close()
 		// 201 Created 是成功创建的标准状态码
 		if mkcolResp.StatusCode != http.StatusCreated {
-			return fmt.Errorf("创建 WebDAV 基础目录 '%s' 失败，状态码: %d", basePath, mkcolResp.StatusCode)
+			return fmt.Errorf("创建 WebDAV 基础目录 '%s' 失败，%w", basePath, &StatusError{mkcolResp.StatusCode})
 		}
 		return nil
 	}
 
 	// 207 Multi-Status 或 200 OK 都表示路径存在
 	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("检查 WebDAV 路径 '%s' 失败，状态码: %d", basePath, resp.StatusCode)
+		return fmt.Errorf("检查 WebDAV 路径 '%s' 失败，%w", basePath, &StatusError{resp.StatusCode})
 	}
 
 	return nil
 }
 
 // ListFiles 列出指定路径下的所有文件，只返回文件路径列表。
+// 如果目录不存在，返回的错误满足 errors.Is(err, os.ErrNotExist)。
 func (c *Client) ListFiles(ctx context.Context, p string) ([]string, error) {
 	infos, err := c.listFilesInternal(ctx, p)
 	if err != nil {
@@ -109,6 +121,50 @@ func (c *Client) ListFilesWithStats(ctx context.Context, p string) ([]FileInfo,
 	return c.listFilesInternal(ctx, p)
 }
 
+// Stat 使用 PROPFIND (Depth: 0) 获取单个文件的信息。
+// 如果文件不存在，返回的错误满足 errors.Is(err, os.ErrNotExist)。
+func (c *Client) Stat(ctx context.Context, p string) (FileInfo, error) {
+	body := `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:getcontentlength/>
+  </d:prop>
+</d:propfind>`
+
+	req, err := c.newRequest(ctx, "PROPFIND", p, strings.NewReader(body))
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("创建 PROPFIND 请求失败: %w", err)
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("获取文件 '%s' 信息失败: %w", p, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return FileInfo{}, fmt.Errorf("获取文件 '%s' 信息失败: %w", p, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return FileInfo{}, fmt.Errorf("获取文件 '%s' 信息失败，%w", p, &StatusError{resp.StatusCode})
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return FileInfo{}, fmt.Errorf("解析文件 '%s' 的 XML 响应失败: %w", p, err)
+	}
+	if len(ms.Responses) == 0 || ms.Responses[0].Propstat.Prop.GetContentLength == "" {
+		return FileInfo{}, fmt.Errorf("获取文件 '%s' 信息失败: 响应中没有文件大小", p)
+	}
+	size, err := strconv.ParseInt(ms.Responses[0].Propstat.Prop.GetContentLength, 10, 64)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("获取文件 '%s' 信息失败: 无效的文件大小 %q", p, ms.Responses[0].Propstat.Prop.GetContentLength)
+	}
+	return FileInfo{Path: p, Size: size}, nil
+}
+
 // UploadFile 使用 PUT 方法将数据上传到指定路径。
 func (c *Client) UploadFile(ctx context.Context, p string, data []byte) error {
 	c.stats.AddUpload(int64(len(data)))
@@ -124,7 +180,7 @@ func (c *Client) UploadFile(ctx context.Context, p string, data []byte) error {
 
 	// 201 Created, 200 OK, 或 204 No Content 都可视为成功
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("上传文件 '%s' 失败，状态码: %d", p, resp.StatusCode)
+		return fmt.Errorf("上传文件 '%s' 失败，%w", p, &StatusError{resp.StatusCode})
 	}
 	return nil
 }
@@ -151,7 +207,7 @@ func (c *Client) UploadFileStream(ctx context.Context, p string, data io.Reader,
 
 	// 201 Created, 200 OK, 或 204 No Content 都可视为成功
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("上传文件 '%s' 失败，状态码: %d", p, resp.StatusCode)
+		return fmt.Errorf("上传文件 '%s' 失败，%w", p, &StatusError{resp.StatusCode})
 	}
 	return nil
 }
@@ -171,7 +227,81 @@ func (c *Client) DeleteFile(ctx context.Context, p string) error {
 
 	// 204 No Content 或 200 OK 都可视为成功
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("删除文件 '%s' 失败，状态码: %d", p, resp.StatusCode)
+		return fmt.Errorf("删除文件 '%s' 失败，%w", p, &StatusError{resp.StatusCode})
+	}
+	return nil
+}
+
+// MoveFile 使用 MOVE 方法将文件从 src 移动（重命名）到 dst。
+// 如果 dst 已存在，则不会覆盖，而是返回错误。
+func (c *Client) MoveFile(ctx context.Context, src, dst string) error {
+	destination, err := c.resolveURL(dst)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, "MOVE", src, nil)
+	if err != nil {
+		return fmt.Errorf("创建 MOVE 请求失败: %w", err)
+	}
+	req.Header.Set("Destination", destination)
+	req.Header.Set("Overwrite", "F")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("移动文件 '%s' 失败: %w", src, err)
+	}
+	defer resp.Body.Close()
+
+	// 201 Created 表示目标是新建的，204 No Content 表示覆盖了已有资源
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("移动文件 '%s' 到 '%s' 失败，%w", src, dst, &StatusError{resp.StatusCode})
+	}
+	return nil
+}
+
+// ReadFile 使用 GET 方法读取指定路径的文件内容。
+// 如果文件不存在，返回的错误满足 errors.Is(err, os.ErrNotExist)。
+func (c *Client) ReadFile(ctx context.Context, p string) ([]byte, error) {
+	req, err := c.newRequest(ctx, "GET", p, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 GET 请求失败: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件 '%s' 失败: %w", p, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("读取文件 '%s' 失败: %w", p, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("读取文件 '%s' 失败，%w", p, &StatusError{resp.StatusCode})
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件 '%s' 内容失败: %w", p, err)
+	}
+	c.stats.AddDownload(int64(len(data)))
+	return data, nil
+}
+
+// MakeDir 使用 MKCOL 方法创建目录。如果目录已存在，则视为成功。
+func (c *Client) MakeDir(ctx context.Context, p string) error {
+	req, err := c.newRequest(ctx, "MKCOL", p, nil)
+	if err != nil {
+		return fmt.Errorf("创建 MKCOL 请求失败: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("创建目录 '%s' 失败: %w", p, err)
+	}
+	defer resp.Body.Close()
+
+	// 201 Created 表示创建成功；405 Method Not Allowed 表示目录已存在
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("创建目录 '%s' 失败，%w", p, &StatusError{resp.StatusCode})
 	}
 	return nil
 }
@@ -181,24 +311,9 @@ func (c *Client) DeleteFile(ctx context.Context, p string) error {
 // newRequest 是一个创建 HTTP 请求的辅助函数。
 // 它能智能处理相对路径和绝对 URL（用于分页）。
 func (c *Client) newRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
-	parsedP, err := url.Parse(p)
+	targetURL, err := c.resolveURL(p)
 	if err != nil {
-		return nil, fmt.Errorf("无法解析路径 '%s': %w", p, err)
-	}
-
-	var targetURL string
-	// 如果 p 是一个完整的 URL (例如，来自 Link 头)，则直接使用它
-	if parsedP.IsAbs() {
-		targetURL = parsedP.String()
-	} else {
-		// 否则，将其与 baseURL 拼接
-		u, err := url.Parse(c.baseURL)
-		if err != nil {
-			return nil, err
-		}
-		u.Path = path.Join(u.Path, parsedP.Path)
-		u.RawQuery = parsedP.RawQuery
-		targetURL = u.String()
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
@@ -210,22 +325,114 @@ func (c *Client) newRequest(ctx context.Context, method, p string, body io.Reade
 	return req, nil
 }
 
+// resolveURL 将路径解析为完整的 URL。
+// 如果 p 是一个完整的 URL (例如，来自 Link 头)，则直接使用它；否则将其与 baseURL 拼接。
+func (c *Client) resolveURL(p string) (string, error) {
+	parsedP, err := url.Parse(p)
+	if err != nil {
+		return "", fmt.Errorf("无法解析路径 '%s': %w", p, err)
+	}
+	if parsedP.IsAbs() {
+		return parsedP.String(), nil
+	}
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, parsedP.Path)
+	u.RawQuery = parsedP.RawQuery
+	return u.String(), nil
+}
+
 // do 是执行 HTTP 请求的简单封装。
 func (c *Client) do(req *http.Request) (*http.Response, error) {
 	return c.httpClient.Do(req)
 }
 
-// linkNextRegex 用于从 Link 响应头中提取下一页的 URL。
-var linkNextRegex = regexp.MustCompile(`<(.+?)>; rel="next"`)
-
-// listFilesInternal 是实现文件列表获取的核心逻辑，支持分页。
+// listFilesInternal 是实现文件列表获取的核心逻辑，支持通过 Link 头分页。
+// 如果服务器同时提供 rel="last"，并且页码可以从前两页的链接推断出来，
+// 剩余的页面会以有限的并发同时获取，大幅缩短数万个文件的目录的列表时间；否则逐页获取。
 func (c *Client) listFilesInternal(ctx context.Context, p string) ([]FileInfo, error) {
-	var allFileInfos []FileInfo
-	nextPagePath := p // 初始路径用于第一个请求
+	allFileInfos, links, err := c.listPage(ctx, p, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// 第二页总是顺序获取，用它和它给出的下一页链接推断页码的步长
+	next := links["next"]
+	for first := true; next != ""; first = false {
+		var infos []FileInfo
+		infos, links, err = c.listPage(ctx, p, next)
+		if err != nil {
+			return nil, err
+		}
+		allFileInfos = append(allFileInfos, infos...)
+
+		if first {
+			if pages := pageSequence(next, links["next"], links["last"]); pages != nil {
+				rest, err := c.listPages(ctx, p, pages)
+				if err != nil {
+					return nil, err
+				}
+				return append(allFileInfos, rest...), nil
+			}
+		}
+		next = links["next"]
+	}
+
+	return allFileInfos, nil
+}
+
+// listPages 以最多 listConcurrency 的并发获取多个分页，并按页的顺序合并结果。
+func (c *Client) listPages(ctx context.Context, p string, pages []string) ([]FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]FileInfo, len(pages))
+	errs := make([]error, len(pages))
+	var wg sync.WaitGroup
+	guard := make(chan struct{}, listConcurrency)
+	for i, page := range pages {
+		wg.Add(1)
+		go func(i int, page string) {
+			defer wg.Done()
+			guard <- struct{}{}
+			defer func() { <-guard }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], _, errs[i] = c.listPage(ctx, p, page)
+			if errs[i] != nil {
+				cancel() // 任意一页失败，列表就不完整，其余请求不必再继续
+			}
+		}(i, page)
+	}
+	wg.Wait()
+
+	// 返回第一个真正的错误，而不是其余页面因取消而产生的错误
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	var all []FileInfo
+	for _, infos := range results {
+		all = append(all, infos...)
+	}
+	return all, nil
+}
 
-	for {
-		// PROPFIND 请求体，只请求必要的信息以节省流量
-		body := `<?xml version="1.0"?>
+// listPage 获取一页目录列表，返回其中的文件和响应的 Link 头中的链接（按 rel 索引）。
+// p 是被列出的目录，pagePath 是这一页的路径或完整 URL。
+func (c *Client) listPage(ctx context.Context, p, pagePath string) ([]FileInfo, map[string]string, error) {
+	// PROPFIND 请求体，只请求必要的信息以节省流量
+	body := `<?xml version="1.0"?>
 <d:propfind xmlns:d="DAV:">
   <d:prop>
     <d:displayname/>
@@ -233,64 +440,56 @@ func (c *Client) listFilesInternal(ctx context.Context, p string) ([]FileInfo, e
   </d:prop>
 </d:propfind>`
 
-		req, err := c.newRequest(ctx, "PROPFIND", nextPagePath, strings.NewReader(body))
-		if err != nil {
-			return nil, fmt.Errorf("创建 PROPFIND 请求失败: %w", err)
-		}
-		req.Header.Set("Depth", "1") // Depth: 1 表示获取当前目录及其直接子级
-		req.Header.Set("Content-Type", "application/xml")
-
-		resp, err := c.do(req)
-		if err != nil {
-			return nil, fmt.Errorf("读取目录 '%s' 失败: %w", nextPagePath, err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusMultiStatus {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("读取目录 '%s' 失败，状态码: %d, 响应: %s", nextPagePath, resp.StatusCode, string(bodyBytes))
-		}
+	req, err := c.newRequest(ctx, "PROPFIND", pagePath, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建 PROPFIND 请求失败: %w", err)
+	}
+	req.Header.Set("Depth", "1") // Depth: 1 表示获取当前目录及其直接子级
+	req.Header.Set("Content-Type", "application/xml")
 
-		var ms multistatus
-		if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
-			return nil, fmt.Errorf("解析目录 '%s' 的 XML 响应失败: %w", nextPagePath, err)
-		}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取目录 '%s' 失败: %w", pagePath, err)
+	}
+	defer resp.Body.Close()
 
-		for _, r := range ms.Responses {
-			href, err := url.PathUnescape(r.Href)
-			if err != nil {
-				continue
-			}
-			// 跳过目录自身，因为 PROPFIND 会把它也包含进来
-			currentReqURL, _ := url.Parse(req.URL.String())
-			if strings.HasSuffix(strings.TrimRight(href, "/"), strings.TrimRight(currentReqURL.Path, "/")) {
-				continue
-			}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("读取目录 '%s' 失败: %w", pagePath, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("读取目录 '%s' 失败，%w, 响应: %s", pagePath, &StatusError{resp.StatusCode}, string(bodyBytes))
+	}
 
-			// 跳过目录（通常目录没有 getcontentlength 属性）
-			if r.Propstat.Prop.GetContentLength == "" {
-				continue
-			}
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, nil, fmt.Errorf("解析目录 '%s' 的 XML 响应失败: %w", pagePath, err)
+	}
 
-			size, _ := strconv.ParseInt(r.Propstat.Prop.GetContentLength, 10, 64)
-			allFileInfos = append(allFileInfos, FileInfo{
-				Path: path.Join(p, path.Base(href)), // 路径始终基于初始请求路径 p
-				Size: size,
-			})
+	var infos []FileInfo
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			continue
+		}
+		// 跳过目录自身，因为 PROPFIND 会把它也包含进来
+		if strings.HasSuffix(strings.TrimRight(href, "/"), strings.TrimRight(req.URL.Path, "/")) {
+			continue
 		}
 
-		// 检查 Link 头以处理分页
-		linkHeader := resp.Header.Get("Link")
-		matches := linkNextRegex.FindStringSubmatch(linkHeader)
-		if len(matches) > 1 {
-			// Link 头提供的是完整的 URL，直接用于下一次请求
-			nextPagePath = matches[1]
-		} else {
-			break // 没有下一页了，退出循环
+		// 跳过目录（通常目录没有 getcontentlength 属性）
+		if r.Propstat.Prop.GetContentLength == "" {
+			continue
 		}
+
+		size, _ := strconv.ParseInt(r.Propstat.Prop.GetContentLength, 10, 64)
+		infos = append(infos, FileInfo{
+			Path: path.Join(p, path.Base(href)), // 路径始终基于初始请求路径 p
+			Size: size,
+		})
 	}
 
-	return allFileInfos, nil
+	return infos, parseLinks(req.URL, resp.Header.Values("Link")), nil
 }
 
 // --- XML 解析结构体 ---
@@ -0,0 +1,139 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/stats"
+)
+
+func newTestClient(url string) *Client {
+	return NewClient(url, "user", "pass", stats.New(), logger.New(logger.ERROR, io.Discard), http.DefaultClient)
+}
+
+func TestListFilesPaginated(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    int
+		pageSize int
+		last     bool
+		relative bool
+		wantPage int // 期望请求的页数
+	}{
+		{name: "不分页", files: 5, wantPage: 1},
+		{name: "逐页获取", files: 10, pageSize: 3, wantPage: 4},
+		{name: "按 last 链接并发获取", files: 25, pageSize: 2, last: true, wantPage: 13},
+		{name: "只有两页", files: 4, pageSize: 3, last: true, wantPage: 2},
+		{name: "相对链接", files: 10, pageSize: 3, last: true, relative: true, wantPage: 4},
+		{name: "相对链接逐页获取", files: 10, pageSize: 3, relative: true, wantPage: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newFakeDir(tt.files, tt.pageSize)
+			defer dir.Close()
+			dir.last, dir.relative = tt.last, tt.relative
+
+			infos, err := newTestClient(dir.URL()).ListFilesWithStats(context.Background(), "photos")
+			if err != nil {
+				t.Fatalf("ListFilesWithStats() 错误 = %v", err)
+			}
+			var got, want []string
+			for _, info := range infos {
+				got = append(got, info.Path)
+			}
+			for _, name := range dir.files {
+				want = append(want, "photos/"+name)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("ListFilesWithStats() = %v，期望按页的顺序返回 %v", got, want)
+			}
+
+			// 每一页恰好请求一次
+			var wantPages []int
+			for i := 1; i <= tt.wantPage; i++ {
+				wantPages = append(wantPages, i)
+			}
+			if pages := dir.Requests(); !slices.Equal(pages, wantPages) {
+				t.Errorf("请求的页码 = %v，期望 %v", pages, wantPages)
+			}
+		})
+	}
+}
+
+// TestListPagesFirstError 检查并发获取分页时，一页失败会取消其余请求，
+// 且返回的是这一页的真实错误，而不是排在它前面的页面因取消而产生的错误。
+func TestListPagesFirstError(t *testing.T) {
+	dir := newFakeDir(20, 2)
+	defer dir.Close()
+	dir.last = true
+	dir.block[3] = true // 第三页一直等待，只有被取消才会结束
+	dir.status[4] = http.StatusTooManyRequests
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := newTestClient(dir.URL()).ListFilesWithStats(ctx, "photos")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("ListFilesWithStats() 错误 = %v，期望状态码 429", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("失败后其余分页的请求没有被取消")
+	}
+}
+
+func TestListFilesPageError(t *testing.T) {
+	dir := newFakeDir(10, 3)
+	defer dir.Close()
+	dir.status[2] = http.StatusTooManyRequests
+
+	_, err := newTestClient(dir.URL()).ListFilesWithStats(context.Background(), "photos")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("ListFilesWithStats() 错误 = %v，期望状态码 429", err)
+	}
+}
+
+func TestMakeDir(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int // 期望错误中的状态码，0 表示期望成功
+	}{
+		{name: "创建成功", status: http.StatusCreated},
+		{name: "目录已存在", status: http.StatusMethodNotAllowed},
+		{name: "限流", status: http.StatusTooManyRequests, wantStatus: http.StatusTooManyRequests},
+		{name: "父目录不存在", status: http.StatusConflict, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "MKCOL" {
+					http.Error(w, fmt.Sprintf("非预期的方法 %s", r.Method), http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := newTestClient(server.URL).MakeDir(context.Background(), "photos")
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("MakeDir() 错误 = %v，期望成功", err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Errorf("MakeDir() 错误 = %v，期望状态码 %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// fakeDirPath 是模拟服务器上被列出的目录，客户端的基础 URL 为服务器地址加上 /dav。
+const fakeDirPath = "/dav/photos"
+
+// fakeDir 是一个只包含一个目录的模拟 WebDAV 服务器，只实现 Depth: 1 的 PROPFIND，
+// 像部分网盘一样通过 Link 头分页返回目录中的文件，用于测试目录列表的分页逻辑。
+// 各配置字段应在发出请求之前设置。
+type fakeDir struct {
+	Server *httptest.Server
+
+	files    []string     // 目录中的文件名，按顺序分页
+	pageSize int          // 每页的文件数，0 表示不分页
+	last     bool         // 是否提供 rel="last" 链接，客户端据此并发获取剩余页面
+	relative bool         // Link 头是否使用相对链接
+	status   map[int]int  // 页码 -> 该页返回的状态码
+	block    map[int]bool // 页码 -> 该页是否一直等待到请求被取消
+
+	mutex    sync.Mutex
+	requests []int // 收到请求的页码
+}
+
+// newFakeDir 启动一个包含 n 个文件、每页 pageSize 个文件的模拟服务器。调用方负责调用 Close。
+func newFakeDir(n, pageSize int) *fakeDir {
+	d := &fakeDir{
+		pageSize: pageSize,
+		status:   make(map[int]int),
+		block:    make(map[int]bool),
+	}
+	for i := 1; i <= n; i++ {
+		d.files = append(d.files, fmt.Sprintf("%03d.jpg", i))
+	}
+	d.Server = httptest.NewServer(d)
+	return d
+}
+
+// Close 关闭服务器。
+func (d *fakeDir) Close() { d.Server.Close() }
+
+// URL 返回用作客户端基础 URL 的地址。
+func (d *fakeDir) URL() string { return d.Server.URL + "/dav" }
+
+// Requests 按页码顺序返回收到请求的页码。
+func (d *fakeDir) Requests() []int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	pages := append([]int(nil), d.requests...)
+	sort.Ints(pages)
+	return pages
+}
+
+func (d *fakeDir) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PROPFIND" || r.URL.Path != fakeDirPath || r.Header.Get("Depth") != "1" {
+		http.NotFound(w, r)
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	d.mutex.Lock()
+	d.requests = append(d.requests, page)
+	d.mutex.Unlock()
+
+	if d.block[page] {
+		<-r.Context().Done()
+		return
+	}
+	if status := d.status[page]; status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	files := d.files
+	if d.pageSize > 0 {
+		last := max((len(files)+d.pageSize-1)/d.pageSize, 1)
+		if page < last {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, d.pageURL(page+1)))
+			if d.last {
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="last"`, d.pageURL(last)))
+			}
+		}
+		files = files[min((page-1)*d.pageSize, len(files)):min(page*d.pageSize, len(files))]
+	}
+
+	responses := []davResponse{{Href: fakeDirPath + "/"}}
+	for _, name := range files {
+		resp := davResponse{Href: fakeDirPath + "/" + name}
+		resp.Propstat.Prop.ContentLength = strconv.Itoa(len(name))
+		responses = append(responses, resp)
+	}
+	for i := range responses {
+		responses[i].Propstat.Status = "HTTP/1.1 200 OK"
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(davMultistatus{XMLNS: "DAV:", Responses: responses})
+}
+
+// pageURL 返回第 n 页的链接。
+func (d *fakeDir) pageURL(n int) string {
+	link := fmt.Sprintf("%s?page=%d", fakeDirPath, n)
+	if d.relative {
+		return link
+	}
+	return d.Server.URL + link
+}
+
+// --- XML 结构体 ---
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"d:multistatus"`
+	XMLNS     string        `xml:"xmlns:d,attr"`
+	Responses []davResponse `xml:"d:response"`
+}
+
+type davResponse struct {
+	Href     string `xml:"d:href"`
+	Propstat struct {
+		Prop struct {
+			ContentLength string `xml:"d:getcontentlength,omitempty"`
+		} `xml:"d:prop"`
+		Status string `xml:"d:status"`
+	} `xml:"d:propstat"`
+}
@@ -0,0 +1,87 @@
+package webdav
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// listConcurrency 是并发获取目录分页时的最大并发数。
+const listConcurrency = 4
+
+// maxListPages 是按推断的页码并发获取的最大页数，防止异常的 rel="last" 链接导致大量请求。
+const maxListPages = 10000
+
+// linkRegex 用于从 Link 响应头中提取每个链接的 URL 和 rel。
+var linkRegex = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?([^",;]+)"?`)
+
+// parseLinks 解析 Link 响应头，返回按 rel 索引的链接。
+// 相对链接按请求地址 base 解析为完整 URL，否则会被当作相对于 WebDAV 根目录的路径。
+func parseLinks(base *url.URL, headers []string) map[string]string {
+	links := make(map[string]string)
+	for _, header := range headers {
+		for _, m := range linkRegex.FindAllStringSubmatch(header, -1) {
+			link, err := base.Parse(m[1])
+			if err != nil {
+				continue
+			}
+			links[m[2]] = link.String()
+		}
+	}
+	return links
+}
+
+// pageSequence 根据第二页 (page)、第三页 (next) 和最后一页 (last) 的链接推断出从第三页到最后一页的所有链接。
+// 三个链接必须只在同一个整数查询参数上不同（例如 page=2、page=3 或 offset=1000、offset=2000），
+// 且最后一页恰好落在推断出的步长上。无法推断时返回 nil，调用方应退回逐页获取。
+func pageSequence(page, next, last string) []string {
+	if next == "" || last == "" {
+		return nil
+	}
+	pageURL, err1 := url.Parse(page)
+	nextURL, err2 := url.Parse(next)
+	lastURL, err3 := url.Parse(last)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil
+	}
+	if pageURL.Scheme != nextURL.Scheme || pageURL.Host != nextURL.Host || pageURL.Path != nextURL.Path ||
+		nextURL.Scheme != lastURL.Scheme || nextURL.Host != lastURL.Host || nextURL.Path != lastURL.Path {
+		return nil
+	}
+
+	pageQuery, nextQuery, lastQuery := pageURL.Query(), nextURL.Query(), lastURL.Query()
+	if len(pageQuery) != len(nextQuery) || len(nextQuery) != len(lastQuery) {
+		return nil
+	}
+	key := ""
+	for k := range nextQuery {
+		if nextQuery.Get(k) == pageQuery.Get(k) && nextQuery.Get(k) == lastQuery.Get(k) {
+			continue
+		}
+		if key != "" {
+			return nil // 不止一个参数不同，无法确定哪个是页码
+		}
+		key = k
+	}
+	if key == "" {
+		return nil
+	}
+
+	from, err1 := strconv.Atoi(pageQuery.Get(key))
+	to, err2 := strconv.Atoi(nextQuery.Get(key))
+	end, err3 := strconv.Atoi(lastQuery.Get(key))
+	step := to - from
+	if err1 != nil || err2 != nil || err3 != nil || step <= 0 || end < to || (end-to)%step != 0 || (end-to)/step >= maxListPages {
+		return nil
+	}
+
+	pages := []string{next}
+	for v := to + step; v <= end; v += step {
+		u := *nextURL
+		query := nextURL.Query()
+		query.Set(key, strconv.Itoa(v))
+		u.RawQuery = query.Encode()
+		pages = append(pages, u.String())
+	}
+	return pages
+}
@@ -0,0 +1,147 @@
+package webdav
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestPageSequence(t *testing.T) {
+	const dir = "https://dav.example.com/dav/photos"
+	tests := []struct {
+		name             string
+		page, next, last string
+		want             []string
+	}{
+		{
+			name: "页码参数",
+			page: dir + "?page=2", next: dir + "?page=3", last: dir + "?page=5",
+			want: []string{dir + "?page=3", dir + "?page=4", dir + "?page=5"},
+		},
+		{
+			name: "从偏移量推断步长",
+			page: dir + "?offset=1000", next: dir + "?offset=2000", last: dir + "?offset=4000",
+			want: []string{dir + "?offset=2000", dir + "?offset=3000", dir + "?offset=4000"},
+		},
+		{
+			name: "保留其他查询参数",
+			page: dir + "?page=2&size=100", next: dir + "?page=3&size=100", last: dir + "?page=4&size=100",
+			want: []string{dir + "?page=3&size=100", dir + "?page=4&size=100"},
+		},
+		{
+			name: "下一页就是最后一页",
+			page: dir + "?page=2", next: dir + "?page=3", last: dir + "?page=3",
+			want: []string{dir + "?page=3"},
+		},
+		{
+			name: "相对链接",
+			page: "/dav/photos?page=2", next: "/dav/photos?page=3", last: "/dav/photos?page=4",
+			want: []string{"/dav/photos?page=3", "/dav/photos?page=4"},
+		},
+		{
+			name: "缺少最后一页",
+			page: dir + "?page=2", next: dir + "?page=3",
+		},
+		{
+			name: "缺少下一页",
+			page: dir + "?page=2", last: dir + "?page=5",
+		},
+		{
+			name: "最后一页不在步长上",
+			page: dir + "?offset=1000", next: dir + "?offset=2000", last: dir + "?offset=4500",
+		},
+		{
+			name: "最后一页在下一页之前",
+			page: dir + "?page=2", next: dir + "?page=3", last: dir + "?page=2",
+		},
+		{
+			name: "页码递减",
+			page: dir + "?page=3", next: dir + "?page=2", last: dir + "?page=1",
+		},
+		{
+			name: "多个参数不同",
+			page: dir + "?page=2&cursor=a", next: dir + "?page=3&cursor=b", last: dir + "?page=4&cursor=c",
+		},
+		{
+			name: "参数个数不同",
+			page: dir + "?page=2", next: dir + "?page=3&size=100", last: dir + "?page=4&size=100",
+		},
+		{
+			name: "页码不是整数",
+			page: dir + "?cursor=abc", next: dir + "?cursor=abd", last: dir + "?cursor=abz",
+		},
+		{
+			name: "路径不同",
+			page: dir + "?page=2", next: "https://dav.example.com/dav/other?page=3", last: dir + "?page=4",
+		},
+		{
+			name: "主机不同",
+			page: dir + "?page=2", next: dir + "?page=3", last: "https://mirror.example.com/dav/photos?page=4",
+		},
+		{
+			name: "相对链接与完整链接混用",
+			page: "/dav/photos?page=2", next: dir + "?page=3", last: dir + "?page=4",
+		},
+		{
+			name: "页数超过上限",
+			page: dir + "?page=1", next: dir + "?page=2", last: dir + "?page=" + strconv.Itoa(maxListPages+2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pageSequence(tt.page, tt.next, tt.last)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("pageSequence() = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinks(t *testing.T) {
+	base, _ := url.Parse("https://dav.example.com/dav/photos")
+	tests := []struct {
+		name    string
+		headers []string
+		want    map[string]string
+	}{
+		{
+			name:    "完整链接",
+			headers: []string{`<https://dav.example.com/dav/photos?page=2>; rel="next"`},
+			want:    map[string]string{"next": "https://dav.example.com/dav/photos?page=2"},
+		},
+		{
+			name:    "同一个头中的多个链接",
+			headers: []string{`<https://dav.example.com/dav/photos?page=2>; rel="next", <https://dav.example.com/dav/photos?page=9>; rel=last`},
+			want: map[string]string{
+				"next": "https://dav.example.com/dav/photos?page=2",
+				"last": "https://dav.example.com/dav/photos?page=9",
+			},
+		},
+		{
+			name:    "相对链接按请求地址解析",
+			headers: []string{`</dav/photos?page=2>; rel="next"`, `<?page=9>; rel="last"`},
+			want: map[string]string{
+				"next": "https://dav.example.com/dav/photos?page=2",
+				"last": "https://dav.example.com/dav/photos?page=9",
+			},
+		},
+		{
+			name: "没有 Link 头",
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLinks(base, tt.headers)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseLinks() = %v，期望 %v", got, tt.want)
+			}
+			for rel, link := range tt.want {
+				if got[rel] != link {
+					t.Errorf("parseLinks()[%q] = %q，期望 %q", rel, got[rel], link)
+				}
+			}
+		})
+	}
+}
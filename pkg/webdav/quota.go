@@ -0,0 +1,85 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Quota 是 WebDAV 服务器报告的存储配额 (RFC 4331)。
+type Quota struct {
+	Available int64 // 剩余可用空间（字节）
+	Used      int64 // 已用空间（字节），服务器未报告时为 -1
+}
+
+// GetQuota 使用 PROPFIND (Depth: 0) 查询路径 p 所在存储的配额。
+// 并非所有服务器都支持配额属性，服务器未报告可用空间时 ok 为 false。
+func (c *Client) GetQuota(ctx context.Context, p string) (quota Quota, ok bool, err error) {
+	body := `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:quota-available-bytes/>
+    <d:quota-used-bytes/>
+  </d:prop>
+</d:propfind>`
+
+	req, err := c.newRequest(ctx, "PROPFIND", p, strings.NewReader(body))
+	if err != nil {
+		return Quota{}, false, fmt.Errorf("创建 PROPFIND 请求失败: %w", err)
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return Quota{}, false, fmt.Errorf("查询 '%s' 的存储配额失败: %w", p, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return Quota{}, false, fmt.Errorf("查询 '%s' 的存储配额失败，%w", p, &StatusError{resp.StatusCode})
+	}
+
+	var ms quotaMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return Quota{}, false, fmt.Errorf("解析 '%s' 的存储配额响应失败: %w", p, err)
+	}
+
+	// 不支持的属性会出现在状态为 404 的 propstat 中，因此需要逐个检查
+	// Depth: 0 时只有路径自身的一个响应
+	quota = Quota{Available: -1, Used: -1}
+	if len(ms.Responses) == 0 {
+		return Quota{}, false, nil
+	}
+	for _, ps := range ms.Responses[0].Propstats {
+		if v, err := strconv.ParseInt(strings.TrimSpace(ps.Prop.Available), 10, 64); err == nil && v >= 0 {
+			quota.Available = v
+		}
+		if v, err := strconv.ParseInt(strings.TrimSpace(ps.Prop.Used), 10, 64); err == nil && v >= 0 {
+			quota.Used = v
+		}
+	}
+	if quota.Available < 0 {
+		return Quota{}, false, nil
+	}
+	return quota, true, nil
+}
+
+type quotaMultistatus struct {
+	XMLName   xml.Name        `xml:"DAV: multistatus"`
+	Responses []quotaResponse `xml:"response"`
+}
+
+type quotaResponse struct {
+	Propstats []quotaPropstat `xml:"propstat"`
+}
+
+type quotaPropstat struct {
+	Prop struct {
+		Available string `xml:"quota-available-bytes"`
+		Used      string `xml:"quota-used-bytes"`
+	} `xml:"prop"`
+}
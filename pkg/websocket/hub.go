@@ -34,31 +34,41 @@ var upgrader = websocket.Upgrader{
 type Message struct {
 	Type    string `json:"type"`
 	Content string `json:"content"`
+	Topic   string `json:"topic,omitempty"` // 消息所属的主题（例如同步任务 ID），为空表示发给所有客户端
+}
+
+// envelope 是在 Hub 内部传递的已编码消息及其主题。
+type envelope struct {
+	topic string
+	data  []byte
 }
 
 // Client 是 Hub 和 websocket 连接之间的中间人。
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub   *Hub
+	conn  *websocket.Conn
+	send  chan []byte
+	topic string // 只接收该主题的消息，为空表示接收所有消息
 }
 
 // Hub 负责管理所有的 WebSocket 客户端连接。
 type Hub struct {
 	clients    map[*Client]bool // 存储所有活跃的客户端连接
-	broadcast  chan []byte      // 用于广播消息的通道
+	broadcast  chan envelope    // 用于广播消息的通道
 	register   chan *Client     // 注册新连接的通道
 	unregister chan *Client     // 注销断开连接的通道
 	mutex      sync.Mutex       // 保护对 clients map 的并发访问
+	replay     *replayBuffer    // 最近广播的消息，供长轮询使用
 }
 
 // NewHub 创建并返回一个新的 Hub 实例。
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan envelope),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		replay:     newReplayBuffer(),
 	}
 }
 
@@ -130,8 +140,11 @@ func (h *Hub) Run() {
 		case message := <-h.broadcast:
 			h.mutex.Lock()
 			for client := range h.clients {
+				if !matches(client.topic, message.topic) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					close(client.send)
 					delete(h.clients, client)
@@ -142,24 +155,26 @@ func (h *Hub) Run() {
 	}
 }
 
-// Broadcast 广播消息。
+// Broadcast 广播消息，并将其加入回放缓冲区。
 func (h *Hub) Broadcast(message Message) {
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Failed to marshal broadcast message: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.replay.add(message)
+	h.broadcast <- envelope{topic: message.Topic, data: data}
 }
 
 // ServeWs 处理来自对端的 websocket 请求。
+// 查询参数 topic 可用于只订阅某一主题的消息（以及不属于任何主题的消息）。
 func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), topic: r.URL.Query().Get("topic")}
 	client.hub.register <- client
 
 	go client.writePump()
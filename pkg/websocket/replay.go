@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+)
+
+// replaySize 是回放缓冲区保留的最近消息数。
+const replaySize = 1000
+
+// Event 是回放缓冲区中带序号的消息，序号从 1 开始连续递增。
+type Event struct {
+	Seq uint64 `json:"seq"`
+	Message
+}
+
+// replayBuffer 保存最近广播的消息，供无法建立 WebSocket 连接的客户端通过长轮询获取。
+type replayBuffer struct {
+	mutex   sync.Mutex
+	events  []Event       // 按序号排列，最多 replaySize 条
+	seq     uint64        // 最后一条消息的序号
+	changed chan struct{} // 有新消息时关闭并替换
+}
+
+func newReplayBuffer() *replayBuffer {
+	return &replayBuffer{changed: make(chan struct{})}
+}
+
+// add 追加一条消息并唤醒所有等待中的轮询。
+func (b *replayBuffer) add(message Message) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.seq++
+	b.events = append(b.events, Event{Seq: b.seq, Message: message})
+	if len(b.events) > replaySize {
+		b.events = append([]Event(nil), b.events[len(b.events)-replaySize:]...)
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// since 返回序号大于 cursor、属于 topic 的消息，以及当前最后一条消息的序号。
+// cursor 不为 0 且其后的部分消息已被丢弃时 lost 为 true。调用方必须持有 b.mutex。
+func (b *replayBuffer) since(cursor uint64, topic string) (events []Event, last uint64, lost bool) {
+	events = []Event{}
+	for _, e := range b.events {
+		if e.Seq > cursor && matches(topic, e.Topic) {
+			events = append(events, e)
+		}
+	}
+	lost = cursor > 0 && len(b.events) > 0 && b.events[0].Seq > cursor+1
+	return events, b.seq, lost
+}
+
+// Since 返回序号大于 cursor 的、属于 topic 的消息（topic 为空表示所有消息），以及应作为下一次 cursor 的序号。
+// cursor 为 0 时返回缓冲区中的全部消息，即最近的日志和状态。没有新消息时等待，直到有新消息、ctx 被取消或超时后返回空列表。
+// cursor 之后的部分消息已从缓冲区中丢弃（客户端太久没有轮询）时 lost 为 true。
+func (h *Hub) Since(ctx context.Context, cursor uint64, topic string) (events []Event, next uint64, lost bool) {
+	b := h.replay
+	for {
+		b.mutex.Lock()
+		if cursor > b.seq {
+			// 服务重启后序号从头开始，旧的 cursor 已无意义
+			cursor, lost = 0, true
+		}
+		events, next, dropped := b.since(cursor, topic)
+		changed := b.changed
+		b.mutex.Unlock()
+		if len(events) > 0 || dropped {
+			return events, next, lost || dropped
+		}
+		select {
+		case <-changed:
+			cursor = next
+		case <-ctx.Done():
+			return events, next, lost
+		}
+	}
+}
+
+// matches 判断订阅 topic 的客户端是否应收到主题为 messageTopic 的消息。
+func matches(topic, messageTopic string) bool {
+	return topic == "" || messageTopic == "" || topic == messageTopic
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/jobs"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/schedule"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	// processEnv 是进程启动时已存在的环境变量。它们优先于 .env 文件，重新加载时不会被覆盖。
+	processEnv = make(map[string]bool)
+	// dotenvKeys 是最近一次从 .env 文件设置的环境变量，重新加载时从文件中删除的变量会被清除。
+	dotenvKeys = make(map[string]bool)
+	// loadedConfig 是最近一次从环境变量加载的配置，不包含运行时通过 API 修改的 Cookie。
+	loadedConfig config.Config
+	// reloadMutex 串行化 /api/reload，保护 dotenvKeys 和 loadedConfig。
+	reloadMutex sync.Mutex
+)
+
+// restartFields 是只在启动时读取、重新加载后仍需重启才能生效的配置项。
+var restartFields = []string{"Port", "LogLevel", "HistoryFile", "HistoryLimit", "SessionSecret", "SessionKeyFile"}
+
+// reloadReport 是 /api/reload 的响应。
+type reloadReport struct {
+	Changed         []string          `json:"changed"`         // 取值发生变化的配置项
+	RestartRequired []string          `json:"restartRequired"` // 其中需要重启才能生效的配置项
+	Jobs            jobs.ReloadReport `json:"jobs"`
+}
+
+// reloadEnv 重新读取 .env 文件：更新其中的变量，清除已从文件中删除的变量。
+// 进程启动时已存在的环境变量优先，不会被修改。文件不存在时视为空文件。
+func reloadEnv() error {
+	values, err := godotenv.Read()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for key := range dotenvKeys {
+		if _, ok := values[key]; !ok {
+			os.Unsetenv(key)
+		}
+	}
+	dotenvKeys = make(map[string]bool, len(values))
+	for key, value := range values {
+		if processEnv[key] {
+			continue
+		}
+		os.Setenv(key, value)
+		dotenvKeys[key] = true
+	}
+	return nil
+}
+
+// reloadHandler 重新读取 .env 文件、环境变量和任务文件，使修改后的配置无需重启即可生效，并返回发生了哪些变化。
+// 新配置无效（例如同步时间窗口格式错误、任务文件无法解析）时不做任何修改并返回 400。
+// 每次同步都会按当前配置重新创建 NodeImage 和 WebDAV 客户端，因此凭据和地址的修改从下一次同步开始生效；
+// 正在进行的同步不受影响。通过 /api/config 设置的 Cookie 在配置文件中的 Cookie 未变化时保留。
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只允许 POST 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	if err := reloadEnv(); err != nil {
+		log.Error("重新加载 .env 文件失败: %v", err)
+		http.Error(w, "读取 .env 文件失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated := config.LoadConfig()
+	if _, err := schedule.New(0, 0, updated.SyncWindow, updated.SyncTimezone); err != nil {
+		log.Error("重新加载失败，同步时间窗口配置无效: %v", err)
+		http.Error(w, "同步时间窗口配置无效: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	specs, err := jobs.LoadSpecs(updated)
+	if err != nil {
+		log.Error("重新加载失败: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := reloadReport{Changed: config.Changed(loadedConfig, *updated), RestartRequired: []string{}}
+	for _, field := range report.Changed {
+		if slices.Contains(restartFields, field) {
+			report.RestartRequired = append(report.RestartRequired, field)
+		}
+	}
+
+	configMutex.Lock()
+	// loadedConfig 只保存文件和环境变量中的值，运行时设置的 Cookie 只放入 appConfig，否则下一次重新加载会误判 Cookie 已变化
+	cookieChanged := updated.NodeImageCookie != loadedConfig.NodeImageCookie
+	loadedConfig = *updated
+	if !cookieChanged {
+		updated.NodeImageCookie = appConfig.NodeImageCookie
+	}
+	// Port 等只在启动时读取的配置保持原值，使 appConfig 与实际运行状态一致
+	updated.Port, updated.LogLevel, updated.HistoryFile, updated.HistoryLimit = appConfig.Port, appConfig.LogLevel, appConfig.HistoryFile, appConfig.HistoryLimit
+	updated.SessionSecret, updated.SessionKeyFile = appConfig.SessionSecret, appConfig.SessionKeyFile
+	*appConfig = *updated
+	if appConfig.Password != "" {
+		// 会话有效期可能已改变；签名密钥（sessionKey）不变，已登录的会话仍然有效
+		store = newSessionStore(*appConfig)
+	}
+	configMutex.Unlock()
+
+	report.Jobs = manager.Reload(updated.JobsFile, specs)
+	log.Info("配置已重新加载，变化的配置项: %v", report.Changed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/jobs"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/logger"
+	"github.com/zouzonghao/nodeimage_webdav_vercel/pkg/websocket"
+)
+
+// setupReload 在临时目录中写入 .env，并按 setup 的方式初始化重新加载所需的全局状态。
+func setupReload(t *testing.T, env string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	// t.Setenv 在测试结束后恢复这些变量，reloadEnv 对它们的修改不会影响其他测试
+	for _, key := range []string{"NODEIMAGE_COOKIE", "SYNC_INTERVAL", "JOBS_FILE", "SYNC_HISTORY_FILE", "PASSWORD"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	writeEnv(t, env)
+
+	processEnv = make(map[string]bool)
+	dotenvKeys = make(map[string]bool)
+	if err := reloadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	appConfig = config.LoadConfig()
+	loadedConfig = *appConfig
+	log = logger.New(logger.ERROR, io.Discard)
+	hub = websocket.NewHub()
+	go hub.Run()
+	specs, err := jobs.LoadSpecs(appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager = jobs.NewManager(specs, appConfig.JobsFile, mustHistory(t), currentConfig, hub, log, http.DefaultClient)
+}
+
+func writeEnv(t *testing.T, env string) {
+	t.Helper()
+	if err := os.WriteFile(".env", []byte(env), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reload 调用 /api/reload 并返回变化的配置项。
+func reload(t *testing.T) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reloadHandler(rec, httptest.NewRequest(http.MethodPost, "/api/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("重新加载返回 %d: %s", rec.Code, rec.Body.String())
+	}
+	var report reloadReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return report.Changed
+}
+
+func TestReloadKeepsRuntimeCookie(t *testing.T) {
+	setupReload(t, "NODEIMAGE_COOKIE=file-cookie\nSYNC_INTERVAL=0\n")
+
+	rec := httptest.NewRecorder()
+	configHandler(rec, httptest.NewRequest(http.MethodPost, "/api/config", strings.NewReader(`{"cookie":"runtime-cookie"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("设置 Cookie 返回 %d", rec.Code)
+	}
+
+	// 连续两次重新加载，文件中的 Cookie 未变化，运行时设置的 Cookie 都应保留
+	for i := 1; i <= 2; i++ {
+		changed := reload(t)
+		if slices.Contains(changed, "NodeImageCookie") {
+			t.Errorf("第 %d 次重新加载误判 Cookie 已变化: %v", i, changed)
+		}
+		if got := currentConfig().NodeImageCookie; got != "runtime-cookie" {
+			t.Errorf("第 %d 次重新加载后 Cookie = %q，期望 runtime-cookie", i, got)
+		}
+	}
+	if loadedConfig.NodeImageCookie != "file-cookie" {
+		t.Errorf("loadedConfig 中的 Cookie = %q，期望文件中的 file-cookie", loadedConfig.NodeImageCookie)
+	}
+
+	// 文件中的 Cookie 改变后以文件为准
+	writeEnv(t, "NODEIMAGE_COOKIE=new-file-cookie\nSYNC_INTERVAL=0\n")
+	if changed := reload(t); !slices.Contains(changed, "NodeImageCookie") {
+		t.Errorf("修改文件中的 Cookie 后变化的配置项 = %v，期望包含 NodeImageCookie", changed)
+	}
+	if got := currentConfig().NodeImageCookie; got != "new-file-cookie" {
+		t.Errorf("修改文件后 Cookie = %q，期望 new-file-cookie", got)
+	}
+}
+
+func mustHistory(t *testing.T) *jobs.History {
+	t.Helper()
+	history, err := jobs.LoadHistory("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return history
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// handleService 只在 Windows 上管理和运行服务，其他平台上总是返回 false。
+func handleService(args []string) bool {
+	return false
+}
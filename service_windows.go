@@ -0,0 +1,255 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	serviceName        = "NodeImageSync"
+	serviceDisplayName = "NodeImage WebDAV Sync"
+	serviceDescription = "将 NodeImage 图床的图片同步到 WebDAV，并提供 Web UI。"
+
+	// serviceLogFile 是以服务运行时的日志文件，位于程序所在目录。
+	serviceLogFile = "nodeimage-sync.log"
+)
+
+// handleService 处理 Windows 服务相关的启动方式：
+// 由服务控制管理器启动时以服务方式运行；命令行参数为 install/uninstall/start/stop 时管理服务。
+// 返回 true 表示已处理，调用方应直接退出。
+func handleService(args []string) bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法判断是否以 Windows 服务运行: %v\n", err)
+		os.Exit(1)
+	}
+	if isService {
+		runService()
+		return true
+	}
+	if len(args) == 0 {
+		return false
+	}
+
+	var action func() error
+	switch args[0] {
+	case "install":
+		action = installService
+	case "uninstall":
+		action = uninstallService
+	case "start":
+		action = startService
+	case "stop":
+		action = stopService
+	default:
+		return false
+	}
+	if err := action(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s 服务失败: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// runService 以 Windows 服务方式运行 Web UI。
+// 服务的工作目录默认为 System32，因此先切换到程序所在目录，使 .env、public 和缓存文件的相对路径照常生效；
+// 服务没有控制台，日志写入程序目录下的 nodeimage-sync.log，启动、停止和失败同时记录到 Windows 事件日志。
+func runService() {
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		os.Exit(1)
+	}
+	defer elog.Close()
+
+	dir, err := executableDir()
+	if err == nil {
+		err = os.Chdir(dir)
+	}
+	if err != nil {
+		elog.Error(1, fmt.Sprintf("切换到程序目录失败: %v", err))
+		os.Exit(1)
+	}
+
+	logFile, err := os.OpenFile(serviceLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		elog.Error(1, fmt.Sprintf("打开日志文件 %s 失败: %v", serviceLogFile, err))
+		os.Exit(1)
+	}
+	defer logFile.Close()
+	logOutput = logFile
+
+	elog.Info(1, fmt.Sprintf("%s 服务正在启动，日志写入 %s", serviceName, filepath.Join(dir, serviceLogFile)))
+	if err := svc.Run(serviceName, &windowsService{elog: elog}); err != nil {
+		elog.Error(1, fmt.Sprintf("%s 服务运行失败: %v", serviceName, err))
+		os.Exit(1)
+	}
+	elog.Info(1, fmt.Sprintf("%s 服务已停止", serviceName))
+}
+
+// windowsService 响应服务控制管理器的请求：收到停止或关机请求时取消 serve 的 context，等待其正常退出。
+type windowsService struct {
+	elog *eventlog.Log
+}
+
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx) }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			// serve 自行退出说明启动或运行失败，返回非零退出码，使服务的恢复策略生效
+			if err != nil {
+				s.elog.Error(1, fmt.Sprintf("%s 服务异常退出: %v", serviceName, err))
+				return true, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				if err := <-done; err != nil {
+					s.elog.Warning(1, fmt.Sprintf("%s 服务停止时出错: %v", serviceName, err))
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// installService 将当前程序注册为开机自动启动的服务，失败后自动重启，并注册事件日志来源。
+func installService() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要以管理员身份运行）: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("服务 %s 已存在", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// 异常退出后 1 分钟重启，一天后重置失败计数
+	actions := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Minute}}
+	if err := s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return fmt.Errorf("设置服务恢复策略失败: %w", err)
+	}
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("注册事件日志来源失败: %w", err)
+	}
+	fmt.Printf("已安装服务 %s，配置文件和日志位于 %s\n", serviceName, filepath.Dir(exe))
+	return nil
+}
+
+// uninstallService 删除服务及其事件日志来源。
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要以管理员身份运行）: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", serviceName)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	if err := eventlog.Remove(serviceName); err != nil {
+		return fmt.Errorf("删除事件日志来源失败: %w", err)
+	}
+	fmt.Printf("已卸载服务 %s\n", serviceName)
+	return nil
+}
+
+// startService 启动已安装的服务。
+func startService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要以管理员身份运行）: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", serviceName)
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return err
+	}
+	fmt.Printf("已启动服务 %s\n", serviceName)
+	return nil
+}
+
+// stopService 停止服务，并等待其完成退出（最多 30 秒）。
+func stopService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要以管理员身份运行）: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", serviceName)
+	}
+	defer s.Close()
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待服务停止超时")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("已停止服务 %s\n", serviceName)
+	return nil
+}
+
+// executableDir 返回程序所在的目录。
+func executableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zouzonghao/nodeimage_webdav_vercel/internal/config"
+
+	"github.com/gorilla/sessions"
+)
+
+// sessionKeySize 是随机生成的会话签名密钥的字节数。
+const sessionKeySize = 64
+
+// sessionKey 是会话 Cookie 的签名密钥，第一次创建会话存储时加载，之后保持不变，重新加载配置不会使已登录的会话失效。
+var sessionKey []byte
+
+// newSessionStore 创建保存登录状态的 Cookie Store。
+// Cookie 签名的有效期取普通会话和“记住我”会话中较长的一个，每个会话实际的过期时间由登录时写入的 expiresAt 决定。
+// 过期时间保存在 Cookie 中，只有签名密钥不可猜测时才可信，因此密钥来自 SESSION_SECRET 或随机生成。
+func newSessionStore(cfg config.Config) *sessions.CookieStore {
+	if sessionKey == nil {
+		sessionKey = loadSessionKey(cfg)
+	}
+	s := sessions.NewCookieStore(sessionKey)
+	s.MaxAge(int(max(sessionTTL(cfg, false), sessionTTL(cfg, true)).Seconds()))
+	return s
+}
+
+// loadSessionKey 返回会话签名密钥：优先使用 SESSION_SECRET；否则读取 SESSION_KEY_FILE，文件不存在时随机生成并保存，
+// 使“记住我”的会话在重启后仍然有效。文件无法读写时使用只在本次运行中有效的随机密钥，重启后需要重新登录。
+func loadSessionKey(cfg config.Config) []byte {
+	if cfg.SessionSecret != "" {
+		return []byte(cfg.SessionSecret)
+	}
+	if cfg.SessionKeyFile != "" {
+		key, err := readSessionKey(cfg.SessionKeyFile)
+		if err == nil {
+			return key
+		}
+		log.Warn("无法使用会话密钥文件，重启后需要重新登录: %v", err)
+	}
+	key := make([]byte, sessionKeySize)
+	rand.Read(key)
+	return key
+}
+
+// readSessionKey 读取 file 中以十六进制保存的密钥，文件不存在时生成一个新的随机密钥并以仅所有者可读写的权限保存。
+func readSessionKey(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("会话密钥文件 %s 的内容无效", file)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取会话密钥文件失败: %w", err)
+	}
+
+	key := make([]byte, sessionKeySize)
+	rand.Read(key)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("创建会话密钥文件失败: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		os.Remove(file)
+		return nil, fmt.Errorf("保存会话密钥失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(file)
+		return nil, fmt.Errorf("保存会话密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// currentStore 返回当前的会话存储。/api/reload 可能同时替换它，因此与 appConfig 一样在 configMutex 下读取。
+func currentStore() *sessions.CookieStore {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return store
+}
+
+// sessionTTL 返回登录会话的有效时间。remember 为 true 且启用了“记住我”时为 REMEMBER_ME_DAYS 天，否则为 SESSION_HOURS 小时。
+func sessionTTL(cfg config.Config, remember bool) time.Duration {
+	if remember && cfg.RememberDays > 0 {
+		return time.Duration(cfg.RememberDays) * 24 * time.Hour
+	}
+	hours := cfg.SessionHours
+	if hours <= 0 {
+		hours = 12
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// authenticated 判断请求是否携带已登录且未过期的会话。
+// 过期时间保存在签名的会话中，即使浏览器没有按时删除 Cookie（例如恢复上次打开的页面），过期的会话也不再有效。
+func authenticated(r *http.Request) bool {
+	session, _ := currentStore().Get(r, "session-name")
+	auth, _ := session.Values["authenticated"].(bool)
+	expiresAt, _ := session.Values["expiresAt"].(int64)
+	return auth && time.Now().Unix() < expiresAt
+}